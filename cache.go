@@ -0,0 +1,634 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/phoban01/go-graphql-client/internal/jsonutil"
+	"github.com/phoban01/go-graphql-client/internal/language"
+)
+
+// CachePolicy is an option of Client.Query and the other query methods that
+// sets how the query uses the cache of the client, see Client.WithCache.
+// It has no effect without cache.
+type CachePolicy string
+
+const (
+	// CacheFirst answers queries from the cache when it holds all their
+	// fields, and sends them to the server otherwise. It's the default.
+	CacheFirst CachePolicy = "cache-first"
+	// NetworkOnly always sends queries to the server, and caches their
+	// results.
+	NetworkOnly CachePolicy = "network-only"
+	// CacheAndNetwork answers queries from the cache like CacheFirst, and
+	// also sends them to the server in the background to refresh the cache
+	// for the next queries.
+	CacheAndNetwork CachePolicy = "cache-and-network"
+)
+
+func (p CachePolicy) Type() OptionType {
+	return optionTypeCachePolicy
+}
+
+func (p CachePolicy) String() string {
+	return string(p)
+}
+
+// cachePolicyOption returns the last CachePolicy of options, or CacheFirst.
+func cachePolicyOption(options []Option) CachePolicy {
+	policy := CacheFirst
+	for _, option := range options {
+		if option.Type() == optionTypeCachePolicy {
+			policy = CachePolicy(option.String())
+		}
+	}
+	return policy
+}
+
+// Cache is a normalized cache of the results of queries and mutations, like
+// the InMemoryCache of Apollo Client. It's used by Client.WithCache.
+//
+// Objects of the results with a __typename and an id are entities, stored
+// once by their __typename and id, e.g. "Repository:MDEw", so that the
+// results of all the operations that hold an entity update it. Other
+// objects are stored within their parent. The fields are stored by name and
+// arguments, so that a query is answered from the cache when all its fields
+// are, whatever their aliases and the operations they came from.
+//
+// The __typename of each object is added to the queries sent with a cache,
+// and skipped when decoding them if the query structs don't hold it. The raw
+// data returned by QueryRaw and MutateRaw holds it.
+//
+// A Cache is safe for concurrent use.
+type Cache struct {
+	mu      sync.RWMutex
+	records map[string]cacheRecord
+	// possibleTypes maps the interface and union types to their possible
+	// types, see SetPossibleTypes. It's nil if they're unknown.
+	possibleTypes map[string]map[string]bool
+}
+
+// NewCache returns an empty Cache.
+func NewCache() *Cache {
+	return &Cache{records: make(map[string]cacheRecord)}
+}
+
+// SetPossibleTypes sets the possible types of the interface and union types
+// of the schema by name, e.g. {"Character": {"Human", "Droid"}}, as returned
+// by introspection.Schema.PossibleTypes. Fragments on other types than the
+// __typename of an object apply to it if it's one of their possible types.
+// Without possible types, the cache can't tell whether such fragments apply:
+// their fields aren't stored, and queries with them are sent to the server.
+// Type conditions that aren't in possibleTypes are taken as object types.
+func (c *Cache) SetPossibleTypes(possibleTypes map[string][]string) {
+	m := make(map[string]map[string]bool, len(possibleTypes))
+	for name, types := range possibleTypes {
+		m[name] = make(map[string]bool, len(types))
+		for _, t := range types {
+			m[name][t] = true
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.possibleTypes = m
+}
+
+// Evict removes the entity with the given __typename and id from the cache,
+// e.g. after a mutation deleted it. Queries whose results held it are sent
+// to the server again.
+func (c *Cache) Evict(typename, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.records, typename+":"+id)
+}
+
+// Reset removes all the results from the cache.
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = make(map[string]cacheRecord)
+}
+
+// Keys of the records of the root fields of queries and mutations.
+const (
+	rootQueryKey    = "ROOT_QUERY"
+	rootMutationKey = "ROOT_MUTATION"
+)
+
+// cacheRecord holds the fields of a cached object by store name, see
+// cacheOperation.storeName. Values are the JSON values decoded with numbers
+// as json.Number, with objects replaced by a cacheRef if they're entities,
+// or by a cacheRecord otherwise.
+type cacheRecord map[string]interface{}
+
+// cacheRef is the key of the record of an entity, e.g. "Repository:MDEw".
+type cacheRef string
+
+// record returns the record of key, creating it if needed.
+func (c *Cache) record(key string) cacheRecord {
+	rec, ok := c.records[key]
+	if !ok {
+		rec = make(cacheRecord)
+		c.records[key] = rec
+	}
+	return rec
+}
+
+// entityKey returns the key of the record of an object with typename and id,
+// and reports whether the object is an entity.
+func entityKey(typename string, id interface{}) (string, bool) {
+	if typename == "" {
+		return "", false
+	}
+	switch id := id.(type) {
+	case string:
+		return typename + ":" + id, true
+	case json.Number:
+		return typename + ":" + id.String(), true
+	}
+	return "", false
+}
+
+// cacheOperation is an operation whose results are cached.
+type cacheOperation struct {
+	doc *language.Document
+	op  *language.OperationDefinition
+	// query is the operation document sent to the server, with the
+	// __typename of each object.
+	query string
+	// variables are the JSON values of the variables, including the
+	// default values of those left out.
+	variables map[string]interface{}
+}
+
+// newCacheOperation returns the cached operation of query, the document of
+// a single operation, with the variables of payload.
+func newCacheOperation(query string, payload map[string]interface{}) (*cacheOperation, error) {
+	doc, err := language.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Operations) != 1 {
+		return nil, fmt.Errorf("cached documents must have a single operation, got %d", len(doc.Operations))
+	}
+	op := &cacheOperation{doc: doc, op: doc.Operations[0], variables: make(map[string]interface{})}
+	for _, f := range doc.Fragments {
+		addTypename(f.SelectionSet, false)
+	}
+	addTypename(op.op.SelectionSet, true)
+	op.query = language.Print(doc, "")
+
+	// Decode the variables like the results, with numbers as json.Number.
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&op.variables); err != nil {
+		return nil, err
+	}
+	if op.variables == nil {
+		op.variables = make(map[string]interface{})
+	}
+	for _, def := range op.op.VariableDefinitions {
+		if _, ok := op.variables[def.Name]; !ok && def.DefaultValue != nil {
+			op.variables[def.Name] = op.value(def.DefaultValue)
+		}
+	}
+	return op, nil
+}
+
+// addTypename adds the __typename field to the selection set of each object
+// field in selections, and to selections unless root is true.
+func addTypename(selections []language.Selection, root bool) []language.Selection {
+	if !root && !hasTypename(selections) {
+		selections = append(selections, &language.Field{Name: "__typename"})
+	}
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *language.Field:
+			if len(sel.SelectionSet) > 0 {
+				sel.SelectionSet = addTypename(sel.SelectionSet, false)
+			}
+		case *language.InlineFragment:
+			// The enclosing selection set has the __typename.
+			sel.SelectionSet = addTypename(sel.SelectionSet, true)
+		}
+	}
+	return selections
+}
+
+func hasTypename(selections []language.Selection) bool {
+	for _, sel := range selections {
+		if f, ok := sel.(*language.Field); ok && f.Alias == "" && f.Name == "__typename" {
+			return true
+		}
+	}
+	return false
+}
+
+// storeName returns the name f is stored by in records: its name, followed
+// by its arguments if any, e.g. `issues({"first":10,"states":["OPEN"]})`.
+func (op *cacheOperation) storeName(f *language.Field) string {
+	if len(f.Arguments) == 0 {
+		return f.Name
+	}
+	args := make(map[string]interface{}, len(f.Arguments))
+	for _, arg := range f.Arguments {
+		args[arg.Name] = op.value(arg.Value)
+	}
+	// Maps are encoded with sorted keys, so that the name doesn't depend on
+	// the order of the arguments.
+	b, _ := json.Marshal(args)
+	return f.Name + "(" + string(b) + ")"
+}
+
+// value returns the JSON value of v, with the values of the variables.
+func (op *cacheOperation) value(v *language.Value) interface{} {
+	switch v.Kind {
+	case language.VariableValue:
+		return op.variables[v.Raw]
+	case language.IntValue, language.FloatValue:
+		return json.Number(v.Raw)
+	case language.BooleanValue:
+		return v.Raw == "true"
+	case language.NullValue:
+		return nil
+	case language.ListValue:
+		list := make([]interface{}, len(v.List))
+		for i, item := range v.List {
+			list[i] = op.value(item)
+		}
+		return list
+	case language.ObjectValue:
+		obj := make(map[string]interface{}, len(v.Fields))
+		for _, f := range v.Fields {
+			obj[f.Name] = op.value(f.Value)
+		}
+		return obj
+	default:
+		// Strings and enum values.
+		return v.Raw
+	}
+}
+
+// included reports whether the @skip and @include directives of a selection
+// include it.
+func (op *cacheOperation) included(directives []*language.Directive) bool {
+	for _, d := range directives {
+		for _, arg := range d.Arguments {
+			if arg.Name != "if" {
+				continue
+			}
+			value := op.value(arg.Value) == true
+			if (d.Name == "skip" && value) || (d.Name == "include" && !value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fragment returns the type condition and the selections of sel, an inline
+// fragment or a fragment spread, and reports whether it's included.
+func (op *cacheOperation) fragment(sel language.Selection) (string, []language.Selection, bool) {
+	switch sel := sel.(type) {
+	case *language.InlineFragment:
+		return sel.TypeCondition, sel.SelectionSet, op.included(sel.Directives)
+	case *language.FragmentSpread:
+		def := op.doc.Fragment(sel.Name)
+		if def == nil {
+			return "", nil, false
+		}
+		return def.TypeCondition, def.SelectionSet, op.included(sel.Directives)
+	}
+	return "", nil, false
+}
+
+// write stores data, the result of op, in the cache.
+func (c *Cache) write(op *cacheOperation, data json.RawMessage) error {
+	var obj map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil || obj == nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := rootQueryKey
+	if op.op.Operation == "mutation" {
+		key = rootMutationKey
+	}
+	c.writeSelections(op, c.record(key), "", op.op.SelectionSet, obj)
+	return nil
+}
+
+// writeSelections stores the fields of obj, an object of type typename,
+// selected by selections into rec.
+func (c *Cache) writeSelections(op *cacheOperation, rec cacheRecord, typename string, selections []language.Selection, obj map[string]interface{}) {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *language.Field:
+			value, ok := obj[sel.ResponseKey()]
+			if !ok || !op.included(sel.Directives) {
+				continue
+			}
+			name := op.storeName(sel)
+			rec[name] = c.writeValue(op, sel.SelectionSet, value, rec[name])
+		case *language.InlineFragment, *language.FragmentSpread:
+			typeCondition, fragment, ok := op.fragment(sel)
+			if !ok {
+				continue
+			}
+			// The fields of fragments that aren't known to apply aren't
+			// stored, since they may have been aliased to fields of others.
+			if applies, _ := c.fragmentApplies(typeCondition, typename); applies {
+				c.writeSelections(op, rec, typename, fragment, obj)
+			}
+		}
+	}
+}
+
+// writeValue returns the value stored for value, the value of a field with
+// selections. Objects that aren't entities are merged into old, the value
+// previously stored.
+func (c *Cache) writeValue(op *cacheOperation, selections []language.Selection, value interface{}, old interface{}) interface{} {
+	if len(selections) == 0 {
+		return value
+	}
+	switch value := value.(type) {
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = c.writeValue(op, selections, item, nil)
+		}
+		return list
+	case map[string]interface{}:
+		typename, _ := value["__typename"].(string)
+		if key, ok := entityKey(typename, value["id"]); ok {
+			c.writeSelections(op, c.record(key), typename, selections, value)
+			return cacheRef(key)
+		}
+		rec := make(cacheRecord)
+		if old, ok := old.(cacheRecord); ok {
+			for k, v := range old {
+				rec[k] = v
+			}
+		}
+		c.writeSelections(op, rec, typename, selections, value)
+		return rec
+	}
+	return value
+}
+
+// fragmentApplies reports whether a fragment with typeCondition applies to
+// objects of type typename, and whether that's known.
+func (c *Cache) fragmentApplies(typeCondition, typename string) (applies, known bool) {
+	if typeCondition == "" || typename == "" || typeCondition == typename {
+		return true, true
+	}
+	if c.possibleTypes == nil {
+		return false, false
+	}
+	return c.possibleTypes[typeCondition][typename], true
+}
+
+// read returns the result of op from the cache, and reports whether the
+// cache holds all its fields.
+func (c *Cache) read(op *cacheOperation) (json.RawMessage, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	root, ok := c.records[rootQueryKey]
+	if !ok {
+		return nil, false
+	}
+	var obj cacheObject
+	if !c.readSelections(op, &obj, root, "", op.op.SelectionSet) {
+		return nil, false
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// readSelections adds the fields of rec, the record of an object of type
+// typename, selected by selections to obj. It reports false if a field is
+// missing.
+func (c *Cache) readSelections(op *cacheOperation, obj *cacheObject, rec cacheRecord, typename string, selections []language.Selection) bool {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *language.Field:
+			if !op.included(sel.Directives) {
+				continue
+			}
+			stored, ok := rec[op.storeName(sel)]
+			if !ok {
+				return false
+			}
+			value, ok := c.readValue(op, sel.SelectionSet, stored)
+			if !ok {
+				return false
+			}
+			obj.set(sel.ResponseKey(), value)
+		case *language.InlineFragment, *language.FragmentSpread:
+			typeCondition, fragment, ok := op.fragment(sel)
+			if !ok {
+				continue
+			}
+			applies, known := c.fragmentApplies(typeCondition, typename)
+			if !known {
+				return false
+			}
+			if applies && !c.readSelections(op, obj, rec, typename, fragment) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readValue returns the value of a field with selections from its stored
+// value, and reports false if a field is missing.
+func (c *Cache) readValue(op *cacheOperation, selections []language.Selection, stored interface{}) (interface{}, bool) {
+	if len(selections) == 0 {
+		return stored, true
+	}
+	var rec cacheRecord
+	switch stored := stored.(type) {
+	case []interface{}:
+		list := make([]interface{}, len(stored))
+		for i, item := range stored {
+			value, ok := c.readValue(op, selections, item)
+			if !ok {
+				return nil, false
+			}
+			list[i] = value
+		}
+		return list, true
+	case cacheRef:
+		var ok bool
+		if rec, ok = c.records[string(stored)]; !ok {
+			return nil, false
+		}
+	case cacheRecord:
+		rec = stored
+	default:
+		return stored, true
+	}
+	typename, _ := rec["__typename"].(string)
+	var obj cacheObject
+	if !c.readSelections(op, &obj, rec, typename, selections) {
+		return nil, false
+	}
+	return obj, true
+}
+
+// cacheObject is a JSON object read from the cache, with its fields in the
+// order of the selections.
+type cacheObject []cacheField
+
+type cacheField struct {
+	key   string
+	value interface{}
+}
+
+// set sets the field key of o to value, merging it into the previous value
+// of the field if they're both objects, since a field may be selected more
+// than once, e.g. in several fragments.
+func (o *cacheObject) set(key string, value interface{}) {
+	for i := range *o {
+		if (*o)[i].key == key {
+			(*o)[i].value = mergeCacheValues((*o)[i].value, value)
+			return
+		}
+	}
+	*o = append(*o, cacheField{key: key, value: value})
+}
+
+func mergeCacheValues(a, b interface{}) interface{} {
+	switch a := a.(type) {
+	case cacheObject:
+		if b, ok := b.(cacheObject); ok {
+			merged := append(cacheObject(nil), a...)
+			for _, f := range b {
+				merged.set(f.key, f.value)
+			}
+			return merged
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok && len(a) == len(b) {
+			merged := make([]interface{}, len(a))
+			for i := range a {
+				merged[i] = mergeCacheValues(a[i], b[i])
+			}
+			return merged
+		}
+	}
+	return b
+}
+
+func (o cacheObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// cachedRequest executes the query or mutation query with the cache of the
+// client, according to policy, and decodes its result into target if it's
+// non-nil.
+func (c *Client) cachedRequest(ctx context.Context, query string, variables interface{}, target interface{}, scalars *ScalarRegistry, possibleTypes *PossibleTypeRegistry, policy CachePolicy) (*Response, *http.Response) {
+	payload, err := variablesPayload(variables, scalars)
+	if err != nil {
+		return errorResponse(newError(ErrGraphQLEncode, err)), nil
+	}
+	op, err := newCacheOperation(query, payload)
+	if err != nil {
+		return errorResponse(newError(ErrGraphQLEncode, err)), nil
+	}
+
+	if op.op.Operation == "query" && policy != NetworkOnly {
+		if data, ok := c.cache.read(op); ok {
+			if policy == CacheAndNetwork {
+				go c.networkRequest(withoutCancel(ctx), op, variables, scalars)
+			}
+			raw := json.RawMessage(data)
+			out := &Response{Data: &raw}
+			c.decodeData(out, target, scalars, possibleTypes)
+			return out, nil
+		}
+	}
+
+	out, resp := c.networkRequest(ctx, op, variables, scalars)
+	c.decodeData(out, target, scalars, possibleTypes)
+	return out, resp
+}
+
+// networkRequest sends the cached operation op to the server, and stores its
+// result in the cache, unless the response has errors.
+func (c *Client) networkRequest(ctx context.Context, op *cacheOperation, variables interface{}, scalars *ScalarRegistry) (*Response, *http.Response) {
+	out, resp := c.request(ctx, op.query, variables, nil, scalars, nil)
+	if len(out.Errors) == 0 && out.Data != nil {
+		if err := c.cache.write(op, *out.Data); err != nil {
+			out.Errors = append(out.Errors, newError(ErrJsonDecode, err))
+		}
+	}
+	return out, resp
+}
+
+// decodeData decodes the data of out into target, if it's non-nil, and
+// reports the failure to do so in the errors of out.
+func (c *Client) decodeData(out *Response, target interface{}, scalars *ScalarRegistry, possibleTypes *PossibleTypeRegistry) {
+	if target == nil || out.Data == nil {
+		return
+	}
+	opts := append(c.decoderOptions(scalars, possibleTypes), jsonutil.IgnoreTypename())
+	if err := jsonutil.UnmarshalGraphQL(*out.Data, target, opts...); err != nil {
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			decodeErr.Path = dataPath(decodeErr.Path)
+		}
+		out.Errors = append(out.Errors, newError(ErrGraphQLDecode, err))
+	}
+}
+
+// withoutCancel returns a context with the values of ctx, which isn't done
+// when ctx is, like context.WithoutCancel of Go 1.21.
+func withoutCancel(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+type detachedContext struct{ parent context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,162 @@
+package graphql
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache()
+	write := func(query, data string, variables map[string]interface{}) {
+		t.Helper()
+		op, err := newCacheOperation(query, variables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.write(op, json.RawMessage(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(query string, variables map[string]interface{}) (string, bool) {
+		t.Helper()
+		op, err := newCacheOperation(query, variables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, ok := cache.read(op)
+		return string(data), ok
+	}
+
+	write(`query ($first: Int = 2) {
+		viewer {
+			login
+			repositories(first: $first, orderBy: {field: NAME, direction: ASC}) {
+				nodes { id name ... on Repository { stars } }
+				totalCount
+			}
+			...UserFields
+		}
+	}
+	fragment UserFields on User { avatar: avatarUrl(size: 40) }`, `{"viewer": {"__typename": "User", "login": "octocat",
+		"repositories": {"__typename": "RepositoryConnection", "totalCount": 2, "nodes": [
+			{"__typename": "Repository", "id": "R1", "name": "hello", "stars": 10},
+			{"__typename": "Repository", "id": "R2", "name": "world", "stars": 20}
+		]},
+		"avatar": "https://example.com/octocat.png"}}`, nil)
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      string
+	}{
+		{
+			name:  "aliases, arguments and fragments",
+			query: `{viewer{...on User{login}r:repositories(orderBy:{direction:ASC,field:NAME},first:2){nodes{name stars}}avatarUrl(size:40)}}`,
+			want:  `{"viewer":{"login":"octocat","r":{"nodes":[{"name":"hello","stars":10,"__typename":"Repository"},{"name":"world","stars":20,"__typename":"Repository"}],"__typename":"RepositoryConnection"},"avatarUrl":"https://example.com/octocat.png","__typename":"User"}}`,
+		},
+		{
+			name:      "variables",
+			query:     `query ($n: Int!, $avatar: Boolean!) {viewer{repositories(first: $n, orderBy: {field: NAME, direction: ASC}){totalCount} avatarUrl(size: 40) @include(if: $avatar)}}`,
+			variables: map[string]interface{}{"n": 2, "avatar": false},
+			want:      `{"viewer":{"repositories":{"totalCount":2,"__typename":"RepositoryConnection"},"__typename":"User"}}`,
+		},
+		{
+			name:  "missing field",
+			query: `{viewer{login email}}`,
+		},
+		{
+			name:  "other arguments",
+			query: `{viewer{repositories(first:3, orderBy: {field: NAME, direction: ASC}){totalCount}}}`,
+		},
+	}
+	for _, tc := range tests {
+		got, ok := read(tc.query, tc.variables)
+		if ok != (tc.want != "") || got != tc.want {
+			t.Errorf("%s: got %s, %v, want %s", tc.name, got, ok, tc.want)
+		}
+	}
+
+	// The entities are updated by the results of other operations, and
+	// evicted.
+	write(`mutation {addStar(id: "R2"){starrable{id stars}}}`, `{"addStar": {"__typename": "AddStarPayload", "starrable": {"__typename": "Repository", "id": "R2", "stars": 21}}}`, nil)
+	query := `{viewer{repositories(first: 2, orderBy: {field: NAME, direction: ASC}){nodes{stars}}}}`
+	if got, _ := read(query, nil); got != `{"viewer":{"repositories":{"nodes":[{"stars":10,"__typename":"Repository"},{"stars":21,"__typename":"Repository"}],"__typename":"RepositoryConnection"},"__typename":"User"}}` {
+		t.Errorf("got %s after the mutation", got)
+	}
+	cache.Evict("Repository", "R1")
+	if got, ok := read(query, nil); ok {
+		t.Errorf("got %s after eviction, want a cache miss", got)
+	}
+	cache.Reset()
+	if got, ok := read(`{viewer{login}}`, nil); ok {
+		t.Errorf("got %s after reset, want a cache miss", got)
+	}
+}
+
+func TestCache_possibleTypes(t *testing.T) {
+	op, err := newCacheOperation(`{search{...on Starrable{id stars}...on Issue{title}}}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := json.RawMessage(`{"search": [
+		{"__typename": "Repository", "id": "R1", "stars": 10},
+		{"__typename": "Issue", "id": "I1", "title": "bug"}
+	]}`)
+
+	// Without the possible types, the cache can't tell whether the fragment
+	// on Starrable applies.
+	cache := NewCache()
+	if err := cache.write(op, data); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cache.read(op); ok {
+		t.Errorf("got %s without possible types, want a cache miss", got)
+	}
+
+	cache = NewCache()
+	cache.SetPossibleTypes(map[string][]string{
+		"SearchResult": {"Repository", "Issue"},
+		"Starrable":    {"Repository"},
+	})
+	if err := cache.write(op, data); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := cache.read(op)
+	if want := `{"search":[{"id":"R1","stars":10,"__typename":"Repository"},{"title":"bug","__typename":"Issue"}]}`; !ok || string(got) != want {
+		t.Errorf("got %s, %v, want %s", got, ok, want)
+	}
+}
+
+func TestCache_aliasedFragments(t *testing.T) {
+	write, err := newCacheOperation(`{hero{...on Human{x:homePlanet}...on Droid{x:primaryFunction}}}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := newCacheOperation(`{hero{...on Human{homePlanet}}}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := json.RawMessage(`{"hero": {"__typename": "Droid", "x": "Astromech"}}`)
+
+	cache := NewCache()
+	if err := cache.write(write, data); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cache.read(read); ok {
+		t.Errorf("got %s without possible types, want a cache miss", got)
+	}
+
+	cache = NewCache()
+	cache.SetPossibleTypes(map[string][]string{"Character": {"Human", "Droid"}})
+	if err := cache.write(write, data); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cache.read(read); !ok || string(got) != `{"hero":{"__typename":"Droid"}}` {
+		t.Errorf("got %s, %v, want the Droid without homePlanet", got, ok)
+	}
+	got, ok := cache.read(write)
+	if want := `{"hero":{"x":"Astromech","__typename":"Droid"}}`; !ok || string(got) != want {
+		t.Errorf("got %s, %v, want %s", got, ok, want)
+	}
+}
@@ -0,0 +1,24 @@
+module github.com/phoban01/go-graphql-client/cmd
+
+go 1.25.0
+
+require (
+	github.com/graph-gophers/graphql-go v1.2.0
+	github.com/phoban01/go-graphql-client v0.0.0-00010101000000-000000000000
+	github.com/phoban01/go-graphql-client/graphqlcheck v0.0.0-00010101000000-000000000000
+	golang.org/x/tools v0.44.0
+	nhooyr.io/websocket v1.8.7
+)
+
+require (
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/klauspost/compress v1.10.3 // indirect
+	github.com/opentracing/opentracing-go v1.1.0 // indirect
+	golang.org/x/mod v0.35.0 // indirect
+	golang.org/x/sync v0.20.0 // indirect
+)
+
+replace (
+	github.com/phoban01/go-graphql-client => ../
+	github.com/phoban01/go-graphql-client/graphqlcheck => ../graphqlcheck
+)
@@ -0,0 +1,379 @@
+// gqlc runs a GraphQL query, mutation or subscription read from a file or
+// stdin, and prints the response as indented JSON, including its errors and
+// extensions. It sends requests with graphql.Client, and subscribes with
+// graphql.SubscriptionClient, so that endpoints can be debugged with the
+// transport of the library.
+//
+// Usage:
+//
+//	gqlc [flags] url [file]
+//
+// The operation is read from file, or from stdin if file is omitted or "-".
+// For example:
+//
+//	gqlc -H "Authorization: bearer $TOKEN" -var owner=octocat https://api.github.com/graphql repository.graphql
+//	echo '{ hero { name } }' | gqlc http://localhost:8080/query
+//	gqlc -variables @variables.json -operation AddReview http://localhost:8080/query operations.graphql
+//	gqlc -n 10 http://localhost:8080/query subscription.graphql
+//
+// Subscriptions connect to the websocket URL given by -ws, which defaults to
+// url with its scheme changed to ws or wss. They print each message as it's
+// received, until -n messages have been received, the server closes the
+// connection, or gqlc is interrupted.
+//
+// The exit status is 1 if the response, or any subscription message, has
+// errors.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	graphql "github.com/phoban01/go-graphql-client"
+	"github.com/phoban01/go-graphql-client/internal/language"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "gqlc:", err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// subscriptionTimeout is the time limit for reading or writing a
+// subscription message, which is a minute by default. Subscriptions run
+// by gqlc may stay quiet for longer, and are limited by -timeout instead.
+const subscriptionTimeout = 24 * time.Hour
+
+// headers is a flag.Value collecting "Name: value" HTTP headers.
+type headers http.Header
+
+func (h headers) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h headers) Set(s string) error {
+	i := strings.Index(s, ":")
+	if i == -1 {
+		return fmt.Errorf("invalid header %q, want \"Name: value\"", s)
+	}
+	http.Header(h).Add(strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]))
+	return nil
+}
+
+// variables is a flag.Value collecting "name=value" variables. Values are
+// parsed as JSON if they're valid JSON, and used as strings otherwise.
+type variables map[string]interface{}
+
+func (v variables) String() string {
+	return fmt.Sprint(map[string]interface{}(v))
+}
+
+func (v variables) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i == -1 {
+		return fmt.Errorf("invalid variable %q, want name=value", s)
+	}
+	name, value := s[:i], s[i+1:]
+	var parsed interface{}
+	if err := decodeJSON([]byte(value), &parsed); err != nil {
+		parsed = value
+	}
+	v[name] = parsed
+	return nil
+}
+
+// headerTransport adds headers to the requests it sends, for both the
+// HTTP requests and the websocket handshakes of the clients.
+type headerTransport struct {
+	header headers
+	// gzip reports whether to ask for gzip-compressed responses.
+	gzip bool
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.header {
+		req.Header[name] = append(req.Header[name], values...)
+	}
+	if t.gzip {
+		// Asking for gzip explicitly disables the transparent decompression
+		// of the transport, leaving it to the client.
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("gqlc", flag.ContinueOnError)
+	header := headers{}
+	fs.Var(header, "H", "HTTP header to send, e.g. \"Authorization: bearer token\" (may be repeated)")
+	vars := variables{}
+	fs.Var(vars, "var", "variable as name=value, where value is JSON or a string (may be repeated)")
+	variablesJSON := fs.String("variables", "", "variables as a JSON object, or @file to read them from file")
+	operationName := fs.String("operation", "", "name of the operation to run, if the document has several")
+	gzip := fs.Bool("gzip", false, "ask for gzip-compressed responses")
+	debug := fs.Bool("debug", false, "include the request and response in the extensions of errors, and log subscription messages to stderr")
+	timeout := fs.Duration("timeout", 0, "time limit of the operation (default none)")
+	wsURL := fs.String("ws", "", "websocket URL for subscriptions (default url with a ws or wss scheme)")
+	initJSON := fs.String("init", "", "connection_init payload of subscriptions, as a JSON object")
+	count := fs.Int("n", 0, "stop a subscription after n messages (default 0, no limit)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gqlc [flags] url [file]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 && fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected url and file arguments, got %d arguments", fs.NArg())
+	}
+
+	var src []byte
+	var err error
+	if file := fs.Arg(1); file == "" || file == "-" {
+		src, err = ioutil.ReadAll(stdin)
+	} else {
+		src, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	query, operation, err := selectOperation(string(src), *operationName)
+	if err != nil {
+		return err
+	}
+
+	payload := make(map[string]interface{})
+	if *variablesJSON != "" {
+		data := []byte(*variablesJSON)
+		if strings.HasPrefix(*variablesJSON, "@") {
+			if data, err = ioutil.ReadFile(strings.TrimPrefix(*variablesJSON, "@")); err != nil {
+				return err
+			}
+		}
+		if err := decodeJSON(data, &payload); err != nil {
+			return fmt.Errorf("invalid -variables: %w", err)
+		}
+	}
+	for name, value := range vars {
+		payload[name] = value
+	}
+	if len(payload) == 0 {
+		payload = nil
+	}
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	httpClient := &http.Client{Transport: headerTransport{header: header, gzip: *gzip}}
+
+	if operation != "subscription" {
+		client := graphql.NewClient(fs.Arg(0), httpClient).WithDebug(*debug)
+		resp, err := client.ExecResponse(ctx, query, payload)
+		if err := writeJSON(stdout, resp); err != nil {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("response has %d error(s)", len(resp.Errors))
+		}
+		return nil
+	}
+
+	if *wsURL == "" {
+		if *wsURL, err = websocketURL(fs.Arg(0)); err != nil {
+			return err
+		}
+	}
+	var params map[string]interface{}
+	if *initJSON != "" {
+		if err := decodeJSON([]byte(*initJSON), &params); err != nil {
+			return fmt.Errorf("invalid -init: %w", err)
+		}
+	}
+	sc := graphql.NewSubscriptionClient(*wsURL).
+		WithWebSocketOptions(graphql.WebsocketOptions{HTTPClient: httpClient}).
+		WithConnectionParams(params).
+		WithTimeout(subscriptionTimeout)
+	if *debug {
+		sc = sc.WithLog(func(args ...interface{}) {
+			fmt.Fprintln(os.Stderr, args...)
+		})
+	}
+	return subscribe(ctx, sc, query, payload, *count, stdout)
+}
+
+// subscribe runs the subscription query, and writes its messages to w until
+// count messages have been received, if count is positive, or until the
+// subscription client stops.
+func subscribe(ctx context.Context, sc *graphql.SubscriptionClient, query string, variables map[string]interface{}, count int, w io.Writer) error {
+	var mu sync.Mutex
+	var received, failed int
+	var writeErr error
+	done := make(chan struct{})
+	handler := func(message *json.RawMessage, err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if count > 0 && received >= count {
+			return nil
+		}
+		received++
+		resp := &graphql.Response{Data: message}
+		if err != nil {
+			failed++
+			var errs graphql.Errors
+			if !errors.As(err, &errs) {
+				errs = graphql.Errors{{Message: err.Error()}}
+			}
+			resp.Errors = errs
+		}
+		if err := writeJSON(w, resp); err != nil && writeErr == nil {
+			writeErr = err
+		}
+		if received == count {
+			close(done)
+		}
+		return nil
+	}
+	if _, err := sc.SubscribeRaw(query, variables, handler); err != nil {
+		return err
+	}
+
+	stopped := make(chan error, 1)
+	sc.OnError(func(sc *graphql.SubscriptionClient, err error) error {
+		// Stop at the first connection error, rather than retrying.
+		return err
+	})
+	go func() {
+		stopped <- sc.Run()
+	}()
+
+	var err error
+	select {
+	case err = <-stopped:
+	case <-done:
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			err = ctx.Err()
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	switch {
+	case err != nil:
+		return err
+	case writeErr != nil:
+		return writeErr
+	case failed > 0:
+		return fmt.Errorf("%d of %d message(s) have errors", failed, received)
+	}
+	return nil
+}
+
+// selectOperation returns the document to send to run the named operation
+// of the document src, or its only operation if name is empty, and the type
+// of that operation. If the document has other operations, they're left
+// out, along with the fragments that only they use.
+func selectOperation(src string, name string) (query string, operation string, err error) {
+	doc, err := language.Parse(src)
+	if err != nil {
+		return "", "", err
+	}
+	var op *language.OperationDefinition
+	for _, o := range doc.Operations {
+		if o.Name == name || name == "" && len(doc.Operations) == 1 {
+			op = o
+			break
+		}
+	}
+	switch {
+	case op == nil && name != "":
+		return "", "", fmt.Errorf("operation %q not found", name)
+	case op == nil:
+		return "", "", fmt.Errorf("document has %d operations, choose one with -operation", len(doc.Operations))
+	case len(doc.Operations) == 1:
+		return src, op.Operation, nil
+	}
+
+	selected := &language.Document{Operations: []*language.OperationDefinition{op}}
+	used := make(map[string]bool)
+	var spread func(selections []language.Selection)
+	spread = func(selections []language.Selection) {
+		for _, sel := range selections {
+			switch sel := sel.(type) {
+			case *language.Field:
+				spread(sel.SelectionSet)
+			case *language.InlineFragment:
+				spread(sel.SelectionSet)
+			case *language.FragmentSpread:
+				f := doc.Fragment(sel.Name)
+				if f == nil || used[sel.Name] {
+					continue
+				}
+				used[sel.Name] = true
+				selected.Fragments = append(selected.Fragments, f)
+				spread(f.SelectionSet)
+			}
+		}
+	}
+	spread(op.SelectionSet)
+	return language.Print(selected, "  "), op.Operation, nil
+}
+
+// websocketURL returns the URL of the GraphQL endpoint at rawURL, with the
+// websocket scheme matching its HTTP scheme.
+func websocketURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	return u.String(), nil
+}
+
+// decodeJSON decodes data into v, keeping numbers as json.Number so that
+// integers are sent as written.
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
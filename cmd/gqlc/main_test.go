@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	graphqlserver "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/example/starwars"
+	"github.com/graph-gophers/graphql-go/relay"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+func newServer(t *testing.T, handler http.Handler) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "bearer token"; got != want {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newStarwarsServer(t *testing.T) *httptest.Server {
+	s, err := graphqlserver.ParseSchema(starwars.Schema, &starwars.Resolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newServer(t, &relay.Handler{Schema: s})
+}
+
+const operations = `
+query Hero($episode: Episode, $withFriends: Boolean!) {
+	hero(episode: $episode) {
+		...CharacterName
+		friends @include(if: $withFriends) { name }
+	}
+}
+
+query Droid($id: ID!) {
+	droid(id: $id) { ...DroidFields }
+}
+
+fragment CharacterName on Character { name }
+fragment DroidFields on Droid { name, primaryFunction }
+`
+
+func TestRun_query(t *testing.T) {
+	server := newStarwarsServer(t)
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{
+		"-H", "Authorization: bearer token",
+		"-operation", "Hero",
+		"-variables", `{"episode": "EMPIRE", "withFriends": true}`,
+		"-var", "withFriends=false",
+		server.URL,
+	}, strings.NewReader(operations), &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "data": {
+    "hero": {
+      "name": "Luke Skywalker"
+    }
+  }
+}
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRun_stringVariable(t *testing.T) {
+	server := newStarwarsServer(t)
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{
+		"-H", "Authorization: bearer token",
+		"-operation", "Droid",
+		"-var", `id="2001"`,
+		server.URL, "-",
+	}, strings.NewReader(operations), &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), `"primaryFunction": "Astromech"`; !strings.Contains(got, want) {
+		t.Errorf("output doesn't contain %s:\n%s", want, got)
+	}
+}
+
+func TestRun_errorsAndExtensions(t *testing.T) {
+	server := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"viewer": null}, "errors": [{"message": "forbidden", "locations": [{"line": 1, "column": 2}]}], "extensions": {"cost": 1}}`))
+	}))
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"-H", "Authorization: bearer token", server.URL}, strings.NewReader("{viewer{login}}"), &stdout)
+	if err == nil || err.Error() != "response has 1 error(s)" {
+		t.Errorf("got error %v, want the number of errors of the response", err)
+	}
+	var resp struct {
+		Data   map[string]interface{}
+		Errors []struct {
+			Message   string
+			Locations []struct{ Line, Column int }
+		}
+		Extensions map[string]interface{}
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resp.Data["viewer"]; !ok {
+		t.Errorf("got data %v, want the viewer field", resp.Data)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Message != "forbidden" || resp.Errors[0].Locations[0].Column != 2 {
+		t.Errorf("got errors %+v", resp.Errors)
+	}
+	if got, want := resp.Extensions["cost"], float64(1); got != want {
+		t.Errorf("got cost extension %v, want %v", got, want)
+	}
+}
+
+func TestRun_gzip(t *testing.T) {
+	server := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Accept-Encoding"), "gzip"; got != want {
+			t.Errorf("got Accept-Encoding %q, want %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		gw.Write([]byte(`{"data": {"viewer": {"login": "gopher"}}}`))
+		gw.Close()
+	}))
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"-gzip", "-H", "Authorization: bearer token", server.URL}, strings.NewReader("{viewer{login}}"), &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), `"login": "gopher"`; !strings.Contains(got, want) {
+		t.Errorf("output doesn't contain %s:\n%s", want, got)
+	}
+}
+
+func TestRun_subscription(t *testing.T) {
+	const query = `subscription { reviewAdded { stars } }`
+	server := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"graphql-ws"}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close(websocket.StatusNormalClosure, "")
+		ctx := r.Context()
+
+		var msg struct {
+			ID      string          `json:"id"`
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := wsjson.Read(ctx, conn, &msg); err != nil || msg.Type != "connection_init" {
+			t.Errorf("got message %+v, error %v, want connection_init", msg, err)
+			return
+		}
+		if got, want := string(msg.Payload), `{"token":"secret"}`; got != want {
+			t.Errorf("got connection_init payload %s, want %s", got, want)
+		}
+		wsjson.Write(ctx, conn, map[string]string{"type": "connection_ack"})
+		if err := wsjson.Read(ctx, conn, &msg); err != nil || msg.Type != "start" {
+			t.Errorf("got message %+v, error %v, want start", msg, err)
+			return
+		}
+		if got, want := string(msg.Payload), `{"query":"subscription { reviewAdded { stars } }","variables":{"episode":"JEDI"}}`; got != want {
+			t.Errorf("got start payload %s, want %s", got, want)
+		}
+		wsjson.Write(ctx, conn, map[string]interface{}{"id": msg.ID, "type": "data", "payload": map[string]interface{}{
+			"data": map[string]interface{}{"reviewAdded": map[string]interface{}{"stars": 5}},
+		}})
+		wsjson.Write(ctx, conn, map[string]interface{}{"id": msg.ID, "type": "data", "payload": map[string]interface{}{
+			"errors": []map[string]interface{}{{"message": "review deleted"}},
+		}})
+		// Wait for the client to go away.
+		wsjson.Read(ctx, conn, &msg)
+	}))
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{
+		"-H", "Authorization: bearer token",
+		"-init", `{"token": "secret"}`,
+		"-var", "episode=JEDI",
+		"-n", "2",
+		server.URL,
+	}, strings.NewReader(query), &stdout)
+	if err == nil || err.Error() != "1 of 2 message(s) have errors" {
+		t.Errorf("got error %v, want the number of messages with errors", err)
+	}
+	got := stdout.String()
+	for _, want := range []string{`"stars": 5`, `"message": "review deleted"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output doesn't contain %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestSelectOperation(t *testing.T) {
+	query, operation, err := selectOperation(operations, "Droid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `query Droid($id: ID!) {
+  droid(id: $id) {
+    ...DroidFields
+  }
+}
+
+fragment DroidFields on Droid {
+  name
+  primaryFunction
+}`
+	if query != want || operation != "query" {
+		t.Errorf("got %s:\n%s\nwant query:\n%s", operation, query, want)
+	}
+
+	for _, tc := range []struct {
+		name string
+		want string
+	}{
+		{"", "document has 2 operations, choose one with -operation"},
+		{"Villain", `operation "Villain" not found`},
+	} {
+		if _, _, err := selectOperation(operations, tc.name); err == nil || err.Error() != tc.want {
+			t.Errorf("got error %v, want %s", err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/phoban01/go-graphql-client/ident"
+	"github.com/phoban01/go-graphql-client/internal/language"
+	"github.com/phoban01/go-graphql-client/introspection"
+	"github.com/phoban01/go-graphql-client/schema"
+)
+
+// builtinScalars maps the built-in GraphQL scalars to Go types.
+var builtinScalars = map[string]string{
+	"Int":     "graphql.Int",
+	"Float":   "graphql.Float",
+	"String":  "graphql.String",
+	"Boolean": "graphql.Boolean",
+	"ID":      "graphql.ID",
+}
+
+// knownScalars maps the custom scalars of package scalars to their Go types,
+// as import path and type name.
+var knownScalars = map[string]string{
+	"DateTime": scalarsPackage + ".DateTime",
+	"Date":     scalarsPackage + ".Date",
+	"Time":     scalarsPackage + ".Time",
+	"BigInt":   scalarsPackage + ".BigInt",
+	"Decimal":  scalarsPackage + ".Decimal",
+	"Long":     scalarsPackage + ".Long",
+	"URI":      scalarsPackage + ".URI",
+	"JSON":     scalarsPackage + ".JSON",
+	"UUID":     scalarsPackage + ".UUID",
+}
+
+const (
+	graphqlPackage = "github.com/phoban01/go-graphql-client"
+	scalarsPackage = graphqlPackage + "/scalars"
+)
+
+// generator generates Go code for the operations and fragments of a document.
+type generator struct {
+	schema *schema.Schema
+	doc    *language.Document
+	buf    bytes.Buffer
+
+	// Named types of the schema used by the generated code.
+	enums   map[string]bool
+	inputs  map[string]bool
+	scalars map[string]bool
+
+	// scalarTypes maps custom scalars to Go types, overriding knownScalars,
+	// see scalarType.
+	scalarTypes map[string]string
+	// imports are the import paths of the Go types of custom scalars.
+	imports map[string]bool
+}
+
+// generate returns the formatted Go source of package pkg, with types for
+// the operations and fragment definitions in doc. If allEnums is true, types
+// are generated for all the enums of the schema, rather than those used by
+// doc. scalarTypes maps custom scalars to Go types, see scalarType.
+func generate(s *schema.Schema, doc *language.Document, pkg string, allEnums bool, scalarTypes map[string]string) ([]byte, error) {
+	g := &generator{
+		schema:      s,
+		doc:         doc,
+		enums:       make(map[string]bool),
+		inputs:      make(map[string]bool),
+		scalars:     make(map[string]bool),
+		scalarTypes: scalarTypes,
+		imports:     make(map[string]bool),
+	}
+	if allEnums {
+		for _, t := range s.Types {
+			if t.Kind == introspection.Enum && !strings.HasPrefix(t.Name, "__") {
+				g.enums[t.Name] = true
+			}
+		}
+	}
+	var body bytes.Buffer
+	for _, op := range doc.Operations {
+		if op.Name == "" {
+			return nil, fmt.Errorf("%d:%d: operations must be named", op.Position.Line, op.Position.Column)
+		}
+		if err := g.operation(op); err != nil {
+			return nil, err
+		}
+	}
+	fragments := append([]*language.FragmentDefinition(nil), doc.Fragments...)
+	sort.Slice(fragments, func(i, j int) bool { return fragments[i].Name < fragments[j].Name })
+	for _, f := range fragments {
+		if err := g.fragment(f); err != nil {
+			return nil, err
+		}
+	}
+	g.namedTypes()
+
+	fmt.Fprintf(&body, "// Code generated by graphql-gen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	// Standard library imports come first.
+	var std, other []string
+	if hasHelpers(doc) {
+		std = append(std, `"context"`)
+	}
+	if bytes.Contains(g.buf.Bytes(), []byte("graphql.")) {
+		other = append(other, fmt.Sprintf("graphql %q", graphqlPackage))
+	}
+	for _, imp := range sortedKeys(g.imports) {
+		if strings.Contains(strings.Split(imp, "/")[0], ".") {
+			other = append(other, strconv.Quote(imp))
+		} else {
+			std = append(std, strconv.Quote(imp))
+		}
+	}
+	sort.Strings(std)
+	body.WriteString("import (\n")
+	for _, imp := range std {
+		body.WriteString("\t" + imp + "\n")
+	}
+	if len(std) > 0 && len(other) > 0 {
+		body.WriteString("\n")
+	}
+	for _, imp := range other {
+		body.WriteString("\t" + imp + "\n")
+	}
+	body.WriteString(")\n\n")
+	body.Write(g.buf.Bytes())
+
+	src, err := format.Source(body.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+func hasHelpers(doc *language.Document) bool {
+	for _, op := range doc.Operations {
+		if op.Operation != "subscription" {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) operation(op *language.OperationDefinition) error {
+	var root *introspection.TypeName
+	var suffix string
+	switch op.Operation {
+	case "query":
+		root, suffix = g.schema.QueryType, "Query"
+	case "mutation":
+		root, suffix = g.schema.MutationType, "Mutation"
+	case "subscription":
+		root, suffix = g.schema.SubscriptionType, "Subscription"
+	}
+	rootType := g.schema.Type(root.Name)
+	if len(op.Directives) > 0 {
+		return fmt.Errorf("%s %s: operation directives are not supported", op.Operation, op.Name)
+	}
+
+	typeName := exportedName(op.Name) + suffix
+	structType, err := g.structType(rootType, op.SelectionSet)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", op.Operation, op.Name, err)
+	}
+	g.printf("// %s is the %s %s.\n", typeName, op.Operation, op.Name)
+	g.printf("type %s %s\n\n", typeName, structType)
+
+	variablesName := ""
+	if len(op.VariableDefinitions) > 0 {
+		variablesName = exportedName(op.Name) + "Variables"
+		g.printf("// %s are the variables of the %s %s.\n", variablesName, op.Operation, op.Name)
+		g.printf("type %s struct {\n", variablesName)
+		for _, def := range op.VariableDefinitions {
+			tag := fmt.Sprintf("graphql:%q", def.Name)
+			if def.DefaultValue != nil {
+				tag += fmt.Sprintf(" default:%q", language.ValueString(def.DefaultValue))
+			}
+			if len(def.Directives) > 0 {
+				tag += fmt.Sprintf(" directives:%q", language.DirectivesString(def.Directives))
+			}
+			g.printf("%s %s `%s`\n", exportedName(def.Name), g.variableType(def.Type), tag)
+		}
+		g.printf("}\n\n")
+	}
+
+	var method string
+	switch op.Operation {
+	case "query":
+		method = "Query"
+	case "mutation":
+		method = "Mutate"
+	default:
+		// Subscriptions are run with a SubscriptionClient, whose handler
+		// decodes the messages.
+		return nil
+	}
+	funcName := exportedName(op.Name)
+	params, variables := "", "nil"
+	if variablesName != "" {
+		params, variables = ", variables "+variablesName, "variables"
+	}
+	g.printf("// %s executes the %s %s.\n", funcName, op.Operation, op.Name)
+	g.printf("func %s(ctx context.Context, client *graphql.Client%s) (*%s, error) {\n", funcName, params, typeName)
+	g.printf("var %s %s\n", strings.ToLower(suffix[:1]), typeName)
+	g.printf("_, err := client.%s(ctx, &%s, %s, graphql.OperationName(%q))\n", method, strings.ToLower(suffix[:1]), variables, op.Name)
+	g.printf("return &%s, err\n}\n\n", strings.ToLower(suffix[:1]))
+	return nil
+}
+
+func (g *generator) fragment(f *language.FragmentDefinition) error {
+	if len(f.Directives) > 0 {
+		return fmt.Errorf("fragment %s: fragment directives are not supported", f.Name)
+	}
+	typeName := exportedName(f.Name)
+	structType, err := g.structType(g.schema.Type(f.TypeCondition), f.SelectionSet)
+	if err != nil {
+		return fmt.Errorf("fragment %s: %w", f.Name, err)
+	}
+	g.printf("// %s is the fragment %s on %s.\n", typeName, f.Name, f.TypeCondition)
+	g.printf("type %s %s\n\n", typeName, structType)
+	g.printf("func (%s) FragmentName() string { return %q }\n", typeName, f.Name)
+	g.printf("func (%s) TypeCondition() string { return %q }\n\n", typeName, f.TypeCondition)
+	return nil
+}
+
+// structType returns the Go struct type of the selections on the parent type.
+func (g *generator) structType(parent *introspection.Type, selections []language.Selection) (string, error) {
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	names := make(map[string]bool)
+	for _, sel := range selections {
+		var name, typ, key string
+		switch sel := sel.(type) {
+		case *language.Field:
+			name = exportedName(sel.ResponseKey())
+			key = language.FieldKey(sel)
+			if sel.Name == "__typename" {
+				typ = "graphql.String"
+				break
+			}
+			def := parent.Field(sel.Name)
+			var err error
+			if typ, err = g.outputType(def.Type, sel.SelectionSet, false); err != nil {
+				return "", fmt.Errorf("%s: %w", sel.ResponseKey(), err)
+			}
+		case *language.InlineFragment:
+			t := parent
+			name = "Fragment"
+			if sel.TypeCondition != "" {
+				t = g.schema.Type(sel.TypeCondition)
+				name = "On" + exportedName(sel.TypeCondition)
+			}
+			key = language.InlineFragmentKey(sel)
+			var err error
+			if typ, err = g.structType(t, sel.SelectionSet); err != nil {
+				return "", fmt.Errorf("... on %s: %w", sel.TypeCondition, err)
+			}
+		case *language.FragmentSpread:
+			if len(sel.Directives) > 0 {
+				return "", fmt.Errorf("...%s: directives on fragment spreads are not supported", sel.Name)
+			}
+			// Embedded named fragments are spread by name.
+			name = exportedName(sel.Name)
+			if names[name] {
+				return "", fmt.Errorf("duplicate Go field %s", name)
+			}
+			names[name] = true
+			b.WriteString(name + "\n")
+			continue
+		}
+		if names[name] {
+			return "", fmt.Errorf("duplicate Go field %s, use an alias", name)
+		}
+		names[name] = true
+		b.WriteString(name + " " + typ)
+		if ident.ParseMixedCaps(name).ToLowerCamelCase() != key {
+			fmt.Fprintf(&b, " `graphql:%q`", key)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}")
+	return b.String(), nil
+}
+
+// outputType returns the Go type of a field of type ref with the given
+// selections. Nullable fields are pointers, except lists.
+func (g *generator) outputType(ref *introspection.TypeRef, selections []language.Selection, nonNull bool) (string, error) {
+	switch ref.Kind {
+	case introspection.NonNull:
+		return g.outputType(ref.OfType, selections, true)
+	case introspection.List:
+		elem, err := g.outputType(ref.OfType, selections, false)
+		return "[]" + elem, err
+	}
+	t := g.schema.Type(ref.Name)
+	var typ string
+	if t.IsLeaf() {
+		typ = g.leafType(t)
+	} else if spread, ok := onlySpread(selections); ok {
+		typ = exportedName(spread)
+	} else {
+		var err error
+		if typ, err = g.structType(t, selections); err != nil {
+			return "", err
+		}
+	}
+	if !nonNull {
+		typ = "*" + typ
+	}
+	return typ, nil
+}
+
+// onlySpread returns the name of the fragment if selections are a single
+// fragment spread, which is then used as the type of the field.
+func onlySpread(selections []language.Selection) (string, bool) {
+	if len(selections) != 1 {
+		return "", false
+	}
+	spread, ok := selections[0].(*language.FragmentSpread)
+	if !ok || len(spread.Directives) > 0 {
+		return "", false
+	}
+	return spread.Name, true
+}
+
+// leafType returns the Go type of scalar or enum type t.
+func (g *generator) leafType(t *introspection.Type) string {
+	if typ, ok := builtinScalars[t.Name]; ok {
+		return typ
+	}
+	if t.Kind != introspection.Enum {
+		return g.scalarType(t.Name)
+	}
+	g.enums[t.Name] = true
+	return exportedName(t.Name)
+}
+
+// scalarType returns the Go type of the custom scalar name. The Go types of
+// scalarTypes and knownScalars are either types of other packages, as
+// import path and type name, e.g. "encoding/json.RawMessage", used as is,
+// or predeclared types, e.g. "int64", the underlying type of a type named
+// after the scalar. Other scalars are strings.
+func (g *generator) scalarType(name string) string {
+	typ, ok := g.scalarTypes[name]
+	if !ok {
+		typ, ok = knownScalars[name]
+	}
+	if i := strings.LastIndex(typ, "."); ok && i >= 0 {
+		g.imports[typ[:i]] = true
+		return path.Base(typ[:i]) + typ[i:]
+	}
+	g.scalars[name] = true
+	return exportedName(name)
+}
+
+// variableType returns the Go type of a variable of type t. Nullable types
+// are pointers, so that the GraphQL type derived from the Go type matches t,
+// and so that variables with a default value are left out when nil.
+func (g *generator) variableType(t *language.Type) string {
+	var typ string
+	if t.Elem != nil {
+		typ = "[]" + g.variableType(t.Elem)
+	} else {
+		typ = g.inputType(t.Name)
+	}
+	if !t.NonNull {
+		typ = "*" + typ
+	}
+	return typ
+}
+
+// inputTypeRef returns the Go type of an input field of type ref.
+func (g *generator) inputTypeRef(ref *introspection.TypeRef, nonNull bool) string {
+	switch ref.Kind {
+	case introspection.NonNull:
+		return g.inputTypeRef(ref.OfType, true)
+	case introspection.List:
+		typ := "[]" + g.inputTypeRef(ref.OfType, false)
+		if !nonNull {
+			typ = "*" + typ
+		}
+		return typ
+	}
+	typ := g.inputType(ref.Name)
+	if !nonNull {
+		typ = "*" + typ
+	}
+	return typ
+}
+
+// inputType returns the Go type of the named input type.
+func (g *generator) inputType(name string) string {
+	if typ, ok := builtinScalars[name]; ok {
+		return typ
+	}
+	switch t := g.schema.Type(name); t.Kind {
+	case introspection.Enum:
+		g.enums[name] = true
+	case introspection.InputObject:
+		if !g.inputs[name] {
+			g.inputs[name] = true
+			// Collect the types of the input fields.
+			for _, f := range t.InputFields {
+				g.inputTypeRef(f.Type, false)
+			}
+		}
+	default:
+		return g.scalarType(name)
+	}
+	return exportedName(name)
+}
+
+// namedTypes writes the enum, input object and custom scalar types used by
+// the generated code. Go types are named after GraphQL types, so that the
+// types of variables are derived correctly.
+func (g *generator) namedTypes() {
+	for _, name := range sortedKeys(g.inputs) {
+		t := g.schema.Type(name)
+		typeName := exportedName(name)
+		g.printf("// %s is the input object %s.\n", typeName, name)
+		g.printf("type %s struct {\n", typeName)
+		for _, f := range t.InputFields {
+			g.printf("%s %s `graphql:%q`\n", exportedName(f.Name), g.inputTypeRef(f.Type, false), f.Name)
+		}
+		g.printf("}\n\n")
+	}
+	for _, name := range sortedKeys(g.enums) {
+		t := g.schema.Type(name)
+		typeName := exportedName(name)
+		g.printf("// %s is the enum %s.\n", typeName, name)
+		g.printf("type %s string\n\n", typeName)
+		g.printf("// Values of %s.\n", typeName)
+		g.printf("const (\n")
+		var values []string
+		for _, v := range t.EnumValues {
+			value := typeName + ident.ParseScreamingSnakeCase(v.Name).ToMixedCaps()
+			g.printf("%s %s = %q\n", value, typeName, v.Name)
+			values = append(values, "string("+value+")")
+		}
+		g.printf(")\n\n")
+		g.printf("// GetGraphQLType returns the name of the enum, implementing graphql.Enum.\n")
+		g.printf("func (%s) GetGraphQLType() string { return %q }\n\n", typeName, name)
+		g.printf("// EnumValues returns the values of the enum, implementing graphql.Enum.\n")
+		g.printf("func (%s) EnumValues() []string {\n", typeName)
+		g.printf("return []string{%s}\n", strings.Join(values, ", "))
+		g.printf("}\n\n")
+	}
+	for _, name := range sortedKeys(g.scalars) {
+		typeName := exportedName(name)
+		typ, ok := g.scalarTypes[name]
+		if !ok {
+			g.printf("// %s is the custom scalar %s, decoded from its JSON string.\n", typeName, name)
+			typ = "string"
+		} else {
+			g.printf("// %s is the custom scalar %s.\n", typeName, name)
+		}
+		g.printf("type %s %s\n\n", typeName, typ)
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// exportedName returns the exported Go name of a GraphQL name, e.g.
+// "avatarUrl" -> "AvatarURL" and "__typename" -> "Typename".
+func exportedName(name string) string {
+	name = strings.TrimLeft(name, "_")
+	return ident.ParseLowerCamelCase(name).ToMixedCaps()
+}
@@ -0,0 +1,139 @@
+// graphql-gen generates Go query structs from GraphQL operations.
+//
+// It reads a schema, as SDL or introspection JSON, and documents holding
+// named operations and fragment definitions, and writes a Go file with:
+//
+//   - a struct type for each operation, e.g. GetIssueQuery for "query GetIssue",
+//     with graphql tags for aliases, arguments and directives;
+//   - a variables struct for each operation with variables, e.g. GetIssueVariables;
+//   - a function executing each query and mutation with a *graphql.Client;
+//   - a type implementing graphql.NamedFragment for each fragment definition;
+//   - types for the enums, input objects and custom scalars they use, or for
+//     all the enums of the schema with -enums. Enum types have a constant for
+//     each value and implement graphql.Enum.
+//
+// Custom scalars of package scalars, such as DateTime or BigInt, get its Go
+// types, and other custom scalars get string types of the same name. The
+// -scalar flag, which can be repeated, sets the Go type of a scalar, either a
+// type of another package, as import path and type name, or a predeclared
+// type, the underlying type of a type named after the scalar:
+//
+//	graphql-gen -scalar JSON=encoding/json.RawMessage -scalar Cents=int64 ...
+//
+// The package name of an imported type must be the last element of its
+// import path. Imported types used by variables must implement
+// graphql.GraphQLType, unless they're named after their scalar.
+//
+// The documents are validated against the schema first.
+//
+// Usage:
+//
+//	graphql-gen -schema schema.graphql [-package name] [-o output.go] [-enums] [-scalar name=type]... file.graphql...
+//
+// It's meant to be run with go generate:
+//
+//	//go:generate graphql-gen -schema schema.graphql -o queries_gen.go queries.graphql
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/phoban01/go-graphql-client/internal/language"
+	"github.com/phoban01/go-graphql-client/schema"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "graphql-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("graphql-gen", flag.ContinueOnError)
+	schemaFile := fs.String("schema", "", "schema file, as SDL or introspection JSON (required)")
+	pkg := fs.String("package", os.Getenv("GOPACKAGE"), "package name of the generated file (default $GOPACKAGE)")
+	output := fs.String("o", "", "output file (default stdout)")
+	allEnums := fs.Bool("enums", false, "generate types for all the enums of the schema, rather than those used by the documents")
+	scalarTypes := make(scalarFlag)
+	fs.Var(scalarTypes, "scalar", "the Go type of a custom scalar, as `name=type`, e.g. JSON=encoding/json.RawMessage or Cents=int64 (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: graphql-gen -schema schema.graphql [-package name] [-o output.go] [-enums] [-scalar name=type]... file.graphql...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *schemaFile == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("a schema and at least one document are required")
+	}
+	if *pkg == "" {
+		return fmt.Errorf("no package name, use -package")
+	}
+
+	s, err := schema.LoadFile(*schemaFile)
+	if err != nil {
+		return err
+	}
+
+	// Check the syntax of each file, so that errors refer to the right file,
+	// then validate them together since fragments may be shared.
+	var src bytes.Buffer
+	for _, name := range fs.Args() {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := language.Parse(string(b)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		src.Write(b)
+		src.WriteString("\n")
+	}
+	if err := s.ValidateDefinitions(src.String()); err != nil {
+		return fmt.Errorf("invalid documents:\n%w", err)
+	}
+	doc, err := language.Parse(src.String())
+	if err != nil {
+		return err
+	}
+
+	out, err := generate(s, doc, *pkg, *allEnums, scalarTypes)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = stdout.Write(out)
+		return err
+	}
+	return ioutil.WriteFile(*output, out, 0644)
+}
+
+// scalarFlag is the -scalar flag, mapping custom scalars to Go types.
+type scalarFlag map[string]string
+
+func (f scalarFlag) String() string {
+	var pairs []string
+	for name, typ := range f {
+		pairs = append(pairs, name+"="+typ)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f scalarFlag) Set(value string) error {
+	name, typ, ok := strings.Cut(value, "=")
+	if !ok || name == "" || typ == "" || strings.HasSuffix(typ, ".") {
+		return fmt.Errorf("%q isn't name=type", value)
+	}
+	f[name] = typ
+	return nil
+}
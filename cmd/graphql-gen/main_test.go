@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	graphqlserver "github.com/graph-gophers/graphql-go"
+	starwarsserver "github.com/graph-gophers/graphql-go/example/starwars"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/phoban01/go-graphql-client"
+	"github.com/phoban01/go-graphql-client/example/codegen/starwars"
+	"github.com/phoban01/go-graphql-client/internal/language"
+)
+
+const exampleDir = "../../example/codegen/starwars"
+
+// TestRun_example checks that the generated code of the codegen example is up to date.
+func TestRun_example(t *testing.T) {
+	var out bytes.Buffer
+	err := run([]string{
+		"-schema", filepath.Join(exampleDir, "schema.graphql"),
+		"-package", "starwars",
+		filepath.Join(exampleDir, "queries.graphql"),
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile(filepath.Join(exampleDir, "queries_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != string(want) {
+		t.Errorf("queries_gen.go is out of date, run go generate ./example/codegen/...\ngot:\n%s", got)
+	}
+}
+
+// TestGenerated checks that the generated structs render the operations of queries.graphql.
+func TestGenerated(t *testing.T) {
+	src, err := ioutil.ReadFile(filepath.Join(exampleDir, "queries.graphql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := language.Parse(string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		construct func(v interface{}, variables interface{}, options ...graphql.Option) (string, error)
+		v         interface{}
+		variables interface{}
+	}{
+		{"Hero", graphql.ConstructQuery, &starwars.HeroQuery{}, starwars.HeroVariables{}},
+		{"Character", graphql.ConstructQuery, &starwars.CharacterQuery{}, starwars.CharacterVariables{}},
+		{"CreateReview", graphql.ConstructMutation, &starwars.CreateReviewMutation{}, starwars.CreateReviewVariables{}},
+	}
+	for _, tc := range tests {
+		got, err := tc.construct(tc.v, tc.variables, graphql.OperationName(tc.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err = graphql.NormalizeQuery(got); err != nil {
+			t.Fatal(err)
+		}
+		want := operationDocument(doc, tc.name)
+		if got != want {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", tc.name, got, want)
+		}
+	}
+}
+
+// operationDocument returns the normalized document of the named operation
+// and the fragments it uses.
+func operationDocument(doc *language.Document, name string) string {
+	var op *language.OperationDefinition
+	for _, o := range doc.Operations {
+		if o.Name == name {
+			op = o
+		}
+	}
+	query := language.Print(&language.Document{Operations: []*language.OperationDefinition{op}}, "")
+	if strings.Contains(query, "...CharacterFields") {
+		query += language.Print(&language.Document{Fragments: []*language.FragmentDefinition{doc.Fragment("CharacterFields")}}, "")
+	}
+	normalized, err := graphql.NormalizeQuery(query)
+	if err != nil {
+		panic(err)
+	}
+	return normalized
+}
+
+func TestGenerated_client(t *testing.T) {
+	s, err := graphqlserver.ParseSchema(starwarsserver.Schema, &starwarsserver.Resolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(&relay.Handler{Schema: s})
+	defer server.Close()
+	client := graphql.NewClient(server.URL, nil)
+
+	hero, err := starwars.Hero(context.Background(), client, starwars.HeroVariables{WithFriends: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hero.Hero.Name, graphql.String("Luke Skywalker"); got != want {
+		t.Errorf("got hero name %q, want %q", got, want)
+	}
+	if got, want := hero.Hero.OnHuman.HeightInFeet, graphql.Float(5.6430448); got != want {
+		t.Errorf("got hero height %v, want %v", got, want)
+	}
+	if got, want := len(hero.Hero.Friends), 4; got != want {
+		t.Errorf("got %d friends, want %d", got, want)
+	}
+
+	review, err := starwars.CreateReview(context.Background(), client, starwars.CreateReviewVariables{
+		Episode: starwars.EpisodeJedi,
+		Review:  starwars.ReviewInput{Stars: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := review.CreateReview.Stars, graphql.Int(5); got != want {
+		t.Errorf("got %d stars, want %d", got, want)
+	}
+}
+
+func TestRun_customTypes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "schema.graphql"), `
+		scalar DateTime
+		enum State { OPEN CLOSED_BY_AUTHOR }
+		input IssueFilter { states: [State!], since: DateTime }
+		type Issue { title: String!, createdAt: DateTime!, state: State! }
+		type Query { issues(filter: IssueFilter): [Issue!]! }
+		type Subscription { issueCreated: Issue! }
+	`)
+	writeFile(t, filepath.Join(dir, "issues.graphql"), `
+		query Issues($filter: IssueFilter) { issues(filter: $filter) { title createdAt state } }
+		subscription IssueCreated { issueCreated { title } }
+	`)
+	var out bytes.Buffer
+	err := run([]string{"-schema", filepath.Join(dir, "schema.graphql"), "-package", "issues", filepath.Join(dir, "issues.graphql")}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"type IssuesVariables struct {\n\tFilter *IssueFilter `graphql:\"filter\"`\n}",
+		"type IssueFilter struct {\n\tStates *[]State          `graphql:\"states\"`\n\tSince  *scalars.DateTime `graphql:\"since\"`\n}",
+		"\tStateClosedByAuthor State = \"CLOSED_BY_AUTHOR\"\n",
+		"func (State) EnumValues() []string {\n\treturn []string{string(StateOpen), string(StateClosedByAuthor)}\n}",
+		"\t\"github.com/phoban01/go-graphql-client/scalars\"\n",
+		"type IssueCreatedSubscription struct {\n\tIssueCreated struct {\n\t\tTitle graphql.String\n\t}\n}",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("generated code doesn't contain:\n%s\ngot:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "func IssueCreated(") {
+		t.Error("got a helper function for a subscription")
+	}
+}
+
+func TestRun_scalars(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "schema.graphql"), `
+		scalar BigInt
+		scalar JSON
+		scalar Cents
+		scalar Cursor
+		type Query { total: BigInt!, metadata: JSON, price(in: Cents!): Cents!, cursor: Cursor }
+	`)
+	writeFile(t, filepath.Join(dir, "prices.graphql"), `query Prices($in: Cents!) { total metadata price(in: $in) cursor }`)
+	var out bytes.Buffer
+	err := run([]string{
+		"-schema", filepath.Join(dir, "schema.graphql"),
+		"-package", "prices",
+		"-scalar", "JSON=encoding/json.RawMessage",
+		"-scalar", "Cents=int64",
+		filepath.Join(dir, "prices.graphql"),
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\t\"context\"\n\t\"encoding/json\"\n\n\tgraphql \"github.com/phoban01/go-graphql-client\"\n\t\"github.com/phoban01/go-graphql-client/scalars\"\n",
+		"\tTotal    scalars.BigInt\n\tMetadata *json.RawMessage\n",
+		"\tPrice    Cents `graphql:\"price(in: $in)\"`\n",
+		"\tIn Cents `graphql:\"in\"`\n",
+		"type Cents int64\n",
+		"type Cursor string\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("generated code doesn't contain:\n%s\ngot:\n%s", want, out.String())
+		}
+	}
+
+	err = run([]string{"-scalar", "JSON", "-schema", filepath.Join(dir, "schema.graphql"), filepath.Join(dir, "prices.graphql")}, ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), `"JSON" isn't name=type`) {
+		t.Errorf("got error %v for a -scalar without type", err)
+	}
+}
+
+func TestRun_allEnums(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "schema.graphql"), `
+		enum State { OPEN CLOSED }
+		enum Unused { A }
+		type Query { state: State! }
+	`)
+	writeFile(t, filepath.Join(dir, "state.graphql"), `query State { state }`)
+	for _, allEnums := range []bool{false, true} {
+		args := []string{"-schema", filepath.Join(dir, "schema.graphql"), "-package", "state", filepath.Join(dir, "state.graphql")}
+		if allEnums {
+			args = append([]string{"-enums"}, args...)
+		}
+		var out bytes.Buffer
+		if err := run(args, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), "func (State) GetGraphQLType() string { return \"State\" }") {
+			t.Errorf("-enums=%v: no State enum in:\n%s", allEnums, out.String())
+		}
+		if got := strings.Contains(out.String(), "\tUnusedA Unused = \"A\"\n"); got != allEnums {
+			t.Errorf("-enums=%v: got the Unused enum %v, want %v", allEnums, got, allEnums)
+		}
+		if strings.Contains(out.String(), "__TypeKind") {
+			t.Errorf("-enums=%v: got an introspection enum", allEnums)
+		}
+	}
+}
+
+func TestRun_errors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "schema.graphql"), `type Query { hello(name: String!): String! }`)
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{`query Hello { hello }`, `hello: missing required argument "name" of type "String!" on field "hello"`},
+		{`{ hello(name: "you") }`, `operations must be named`},
+		{`query Hello { hello(name: "you") `, `syntax error`},
+	}
+	for _, tc := range tests {
+		writeFile(t, filepath.Join(dir, "hello.graphql"), tc.doc)
+		err := run([]string{"-schema", filepath.Join(dir, "schema.graphql"), "-package", "hello", filepath.Join(dir, "hello.graphql")}, ioutil.Discard)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want %q", tc.doc, err, tc.want)
+		}
+	}
+}
+
+func writeFile(t *testing.T, name, content string) {
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
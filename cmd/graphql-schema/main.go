@@ -0,0 +1,102 @@
+// graphql-schema downloads the schema of a GraphQL server using the
+// introspection query, and writes it in the GraphQL schema definition
+// language (SDL) or as introspection JSON.
+//
+// Usage:
+//
+//	graphql-schema [flags] url
+//
+// For example:
+//
+//	graphql-schema -H "Authorization: bearer $TOKEN" -o schema.graphql https://api.github.com/graphql
+//	graphql-schema -format json -o schema.json http://localhost:8080/query
+//
+// The JSON output can be loaded by package schema, like the SDL output.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	graphql "github.com/phoban01/go-graphql-client"
+)
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "graphql-schema:", err)
+		os.Exit(1)
+	}
+}
+
+// headers is a flag.Value collecting "Name: value" HTTP headers.
+type headers http.Header
+
+func (h headers) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h headers) Set(s string) error {
+	i := strings.Index(s, ":")
+	if i == -1 {
+		return fmt.Errorf("invalid header %q, want \"Name: value\"", s)
+	}
+	http.Header(h).Add(strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]))
+	return nil
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("graphql-schema", flag.ContinueOnError)
+	format := fs.String("format", "sdl", "output format, sdl or json")
+	output := fs.String("o", "", "output file (default stdout)")
+	header := headers{}
+	fs.Var(header, "H", "HTTP header to send, e.g. \"Authorization: bearer token\" (may be repeated)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: graphql-schema [flags] url")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected 1 url argument, got %d", fs.NArg())
+	}
+	if *format != "sdl" && *format != "json" {
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+
+	client := graphql.NewClient(fs.Arg(0), nil).WithRequestModifier(func(r *http.Request) {
+		for name, values := range header {
+			r.Header[name] = append(r.Header[name], values...)
+		}
+	})
+	schema, err := client.Introspect(ctx)
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	switch *format {
+	case "sdl":
+		out = []byte(schema.SDL())
+	case "json":
+		out, err = json.MarshalIndent(map[string]interface{}{"__schema": schema}, "", "  ")
+		if err != nil {
+			return err
+		}
+		out = append(out, '\n')
+	}
+
+	if *output == "" {
+		_, err = stdout.Write(out)
+		return err
+	}
+	return ioutil.WriteFile(*output, out, 0644)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	graphqlserver "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/example/starwars"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/phoban01/go-graphql-client/schema"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	s, err := graphqlserver.ParseSchema(starwars.Schema, &starwars.Resolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/query", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "bearer token"; got != want {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		(&relay.Handler{Schema: s}).ServeHTTP(w, r)
+	}))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRun_sdl(t *testing.T) {
+	server := newServer(t)
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"-H", "Authorization: bearer token", server.URL + "/query"}, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdl := stdout.String()
+	for _, want := range []string{
+		"type Droid implements Character {\n",
+		"  friendsConnection(first: Int, after: ID): FriendsConnection!\n",
+		"union SearchResult = Human | Droid | Starship\n",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL doesn't contain %q:\n%s", want, sdl)
+		}
+	}
+	if strings.Contains(sdl, "schema {") {
+		t.Error("got a schema definition for conventional root type names")
+	}
+	if _, err := schema.Load([]byte(sdl)); err != nil {
+		t.Errorf("invalid SDL: %v", err)
+	}
+}
+
+func TestRun_json(t *testing.T) {
+	server := newServer(t)
+
+	out := filepath.Join(t.TempDir(), "schema.json")
+	err := run(context.Background(), []string{"-H", "Authorization: bearer token", "-format", "json", "-o", out, server.URL + "/query"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := schema.LoadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateDocument(`{hero{name ... on Droid{primaryFunction}}}`); err != nil {
+		t.Errorf("got error: %v", err)
+	}
+}
+
+func TestRun_errors(t *testing.T) {
+	server := newServer(t)
+
+	var stdout bytes.Buffer
+	if err := run(context.Background(), []string{server.URL + "/query"}, &stdout); err == nil {
+		t.Error("got nil error without authorization header")
+	}
+	if err := run(context.Background(), []string{"-format", "yaml", server.URL + "/query"}, &stdout); err == nil {
+		t.Error("got nil error for unsupported format")
+	}
+}
@@ -0,0 +1,27 @@
+// graphqlcheck reports mistakes in the query structs passed to the graphql
+// client, such as malformed graphql struct tags, fields that can't be
+// decoded, or map fields. See package graphqlcheck for the list of checks.
+//
+// Usage:
+//
+//	graphqlcheck [-schema file] [package...]
+//
+// For example:
+//
+//	graphqlcheck ./...
+//	graphqlcheck -schema schema.graphql ./...
+//	go vet -vettool=$(which graphqlcheck) ./...
+//
+// With -schema, the selections of the query structs are also validated
+// against the schema, in SDL or introspection JSON as written by
+// graphql-schema.
+package main
+
+import (
+	"github.com/phoban01/go-graphql-client/graphqlcheck"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(graphqlcheck.Analyzer)
+}
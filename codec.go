@@ -0,0 +1,19 @@
+package graphql
+
+import "github.com/phoban01/go-graphql-client/internal/jsonutil"
+
+// Codec encodes and decodes JSON for Client and SubscriptionClient, so that
+// JSON libraries other than encoding/json can be plugged in with WithCodec.
+// encoding/json is used by default.
+//
+// Marshal and Unmarshal must behave like json.Marshal and json.Unmarshal
+// for the values they're given. NewTokenizer returns a Tokenizer reading
+// the JSON tokens and values of a stream, like json.Decoder, with numbers
+// read as json.Number. The response data is decoded into query structs from
+// its tokens, except for the values of types that implement json.Unmarshaler
+// or encoding.TextUnmarshaler, which are decoded by their own methods.
+type Codec = jsonutil.Codec
+
+// Tokenizer reads the JSON tokens and values of a stream, like
+// json.Decoder. See Codec.
+type Tokenizer = jsonutil.Tokenizer
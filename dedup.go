@@ -0,0 +1,154 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/phoban01/go-graphql-client/internal/language"
+)
+
+// inflightGroup deduplicates the identical HTTP requests in flight, so that
+// they're sent once, like golang.org/x/sync/singleflight.
+type inflightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+// inflightCall is a request in flight. Its result is set once done is
+// closed.
+type inflightCall struct {
+	done chan struct{}
+	// callers is the number of callers waiting for the result, and cancel
+	// cancels the request once there are none left.
+	callers int
+	cancel  context.CancelFunc
+	resp    *http.Response
+	body    []byte
+	err     error
+}
+
+func newInflightGroup() *inflightGroup {
+	return &inflightGroup{calls: make(map[string]*inflightCall)}
+}
+
+// do calls fn in a new goroutine, which sends the request with key and
+// reads the body of its response, unless a call with the same key is in
+// flight, in which case it joins it. It then waits for the result of the
+// call, or for ctx to be done. The call isn't canceled with the context of
+// the caller that made it, since the others may still wait for it, but
+// once all the callers gave up: fn is given a context with the values of
+// ctx, which is done then.
+func (g *inflightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (*http.Response, []byte, error)) (*http.Response, []byte, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if ok {
+		call.callers++
+		g.mu.Unlock()
+	} else {
+		callCtx, cancel := context.WithCancel(withoutCancel(ctx))
+		call = &inflightCall{done: make(chan struct{}), callers: 1, cancel: cancel}
+		g.calls[key] = call
+		g.mu.Unlock()
+		go func() {
+			call.resp, call.body, call.err = fn(callCtx)
+			g.mu.Lock()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	select {
+	case <-call.done:
+		return call.resp, call.body, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.callers--
+		if call.callers == 0 {
+			// Nobody waits for the call anymore: cancel it, and send the
+			// next identical request anew.
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			call.cancel()
+		}
+		g.mu.Unlock()
+		return nil, nil, ctx.Err()
+	}
+}
+
+// inflightKey returns the key that identifies request, with body, among the
+// requests in flight: its method, URL, header and body.
+func inflightKey(request *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, request.Method+" "+request.URL.String()+"\n")
+	names := make([]string, 0, len(request.Header))
+	for name := range request.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range request.Header[name] {
+			io.WriteString(h, name+": "+value+"\n")
+		}
+	}
+	io.WriteString(h, "\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isQueryDocument reports whether query is a valid document of query
+// operations only, whose requests may be deduplicated.
+func isQueryDocument(query string) bool {
+	doc, err := language.Parse(query)
+	if err != nil || len(doc.Operations) == 0 {
+		return false
+	}
+	for _, op := range doc.Operations {
+		if op.Operation != "query" {
+			return false
+		}
+	}
+	return true
+}
+
+// send sends request with the HTTP client of c. If key isn't
+// empty, the request is deduplicated with the identical requests in flight
+// with the same key, which all get a copy of the same response.
+func (c *Client) send(request *http.Request, key string) (*http.Response, error) {
+	if key == "" {
+		return c.httpClient.Do(request)
+	}
+	resp, body, err := c.inflight.do(request.Context(), key, func(ctx context.Context) (*http.Response, []byte, error) {
+		// The request is shared by the callers, and sent until it's done
+		// even if the caller that sent it gives up, unless they all do.
+		resp, err := c.httpClient.Do(request.WithContext(ctx))
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+		var r io.Reader = resp.Body
+		if c.maxResponseSize > 0 {
+			// The body is limited again once decompressed.
+			r = &limitedReader{r: r, n: c.maxResponseSize, max: c.maxResponseSize}
+		}
+		body, err := io.ReadAll(r)
+		return resp, body, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	shared := *resp
+	shared.Header = resp.Header.Clone()
+	shared.Body = io.NopCloser(bytes.NewReader(body))
+	shared.Request = request
+	return &shared, nil
+}
@@ -0,0 +1,6 @@
+// Package starwars holds the query structs generated by graphql-gen from
+// queries.graphql, for the Star Wars schema of the graph-gophers/graphql-go
+// example server.
+package starwars
+
+//go:generate go run -C ../../../cmd ./graphql-gen -schema ../example/codegen/starwars/schema.graphql -o ../example/codegen/starwars/queries_gen.go ../example/codegen/starwars/queries.graphql
@@ -0,0 +1,114 @@
+// Code generated by graphql-gen. DO NOT EDIT.
+
+package starwars
+
+import (
+	"context"
+
+	graphql "github.com/phoban01/go-graphql-client"
+)
+
+// HeroQuery is the query Hero.
+type HeroQuery struct {
+	Hero *struct {
+		Typename graphql.String `graphql:"__typename"`
+		CharacterFields
+		Friends []*struct {
+			Name graphql.String
+		} `graphql:"friends @include(if: $withFriends)"`
+		OnDroid struct {
+			PrimaryFunction *graphql.String
+		} `graphql:"... on Droid"`
+		OnHuman struct {
+			HeightInFeet graphql.Float `graphql:"heightInFeet: height(unit: FOOT)"`
+		} `graphql:"... on Human"`
+	} `graphql:"hero(episode: $episode)"`
+}
+
+// HeroVariables are the variables of the query Hero.
+type HeroVariables struct {
+	Episode     *Episode        `graphql:"episode" default:"EMPIRE"`
+	WithFriends graphql.Boolean `graphql:"withFriends"`
+}
+
+// Hero executes the query Hero.
+func Hero(ctx context.Context, client *graphql.Client, variables HeroVariables) (*HeroQuery, error) {
+	var q HeroQuery
+	_, err := client.Query(ctx, &q, variables, graphql.OperationName("Hero"))
+	return &q, err
+}
+
+// CharacterQuery is the query Character.
+type CharacterQuery struct {
+	Character *CharacterFields `graphql:"character(id: $id)"`
+}
+
+// CharacterVariables are the variables of the query Character.
+type CharacterVariables struct {
+	ID graphql.ID `graphql:"id"`
+}
+
+// Character executes the query Character.
+func Character(ctx context.Context, client *graphql.Client, variables CharacterVariables) (*CharacterQuery, error) {
+	var q CharacterQuery
+	_, err := client.Query(ctx, &q, variables, graphql.OperationName("Character"))
+	return &q, err
+}
+
+// CreateReviewMutation is the mutation CreateReview.
+type CreateReviewMutation struct {
+	CreateReview *struct {
+		Stars      graphql.Int
+		Commentary *graphql.String
+	} `graphql:"createReview(episode: $episode, review: $review)"`
+}
+
+// CreateReviewVariables are the variables of the mutation CreateReview.
+type CreateReviewVariables struct {
+	Episode Episode     `graphql:"episode"`
+	Review  ReviewInput `graphql:"review"`
+}
+
+// CreateReview executes the mutation CreateReview.
+func CreateReview(ctx context.Context, client *graphql.Client, variables CreateReviewVariables) (*CreateReviewMutation, error) {
+	var m CreateReviewMutation
+	_, err := client.Mutate(ctx, &m, variables, graphql.OperationName("CreateReview"))
+	return &m, err
+}
+
+// CharacterFields is the fragment CharacterFields on Character.
+type CharacterFields struct {
+	ID                graphql.ID
+	Name              graphql.String
+	AppearsIn         []Episode
+	FriendsConnection struct {
+		TotalCount graphql.Int
+	} `graphql:"friendsConnection(first: 2)"`
+}
+
+func (CharacterFields) FragmentName() string  { return "CharacterFields" }
+func (CharacterFields) TypeCondition() string { return "Character" }
+
+// ReviewInput is the input object ReviewInput.
+type ReviewInput struct {
+	Stars      graphql.Int     `graphql:"stars"`
+	Commentary *graphql.String `graphql:"commentary"`
+}
+
+// Episode is the enum Episode.
+type Episode string
+
+// Values of Episode.
+const (
+	EpisodeNewhope Episode = "NEWHOPE"
+	EpisodeEmpire  Episode = "EMPIRE"
+	EpisodeJedi    Episode = "JEDI"
+)
+
+// GetGraphQLType returns the name of the enum, implementing graphql.Enum.
+func (Episode) GetGraphQLType() string { return "Episode" }
+
+// EnumValues returns the values of the enum, implementing graphql.Enum.
+func (Episode) EnumValues() []string {
+	return []string{string(EpisodeNewhope), string(EpisodeEmpire), string(EpisodeJedi)}
+}
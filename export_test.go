@@ -0,0 +1,14 @@
+package graphql
+
+// InflightCallers returns the number of queries of c that wait for a
+// deduplicated request in flight. The client must have deduplication
+// enabled.
+func InflightCallers(c *Client) int {
+	c.inflight.mu.Lock()
+	defer c.inflight.mu.Unlock()
+	callers := 0
+	for _, call := range c.inflight.calls {
+		callers += call.callers
+	}
+	return callers
+}
@@ -0,0 +1,235 @@
+package graphql
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/phoban01/go-graphql-client/internal/jsonutil"
+)
+
+// NamedFragment is implemented by struct types that represent a reusable
+// named fragment. Instead of being expanded in place, such a type is
+// rendered once as a fragment definition at the end of the document and
+// spread by name wherever it is used.
+//
+// E.g., for the following type:
+//
+//	type UserFields struct {
+//		Name  String
+//		Email String
+//	}
+//
+//	func (UserFields) FragmentName() string  { return "UserFields" }
+//	func (UserFields) TypeCondition() string { return "User" }
+//
+// struct{Viewer UserFields} -> "{viewer{...UserFields}}fragment UserFields on User{name,email}".
+type NamedFragment interface {
+	// FragmentName returns the name of the fragment definition.
+	FragmentName() string
+	// TypeCondition returns the GraphQL type the fragment applies to.
+	TypeCondition() string
+}
+
+var namedFragmentType = reflect.TypeOf((*NamedFragment)(nil)).Elem()
+
+// fragmentSet collects the named fragment definitions discovered while
+// writing a query, in the order they are first spread. It also holds the
+// custom scalars of the query, whose types are leaves, and the possible types
+// of its interface types.
+type fragmentSet struct {
+	names         map[string]reflect.Type
+	types         []reflect.Type
+	values        []reflect.Value
+	scalars       *ScalarRegistry
+	possibleTypes *PossibleTypeRegistry
+}
+
+// add registers the named fragment t, with its first seen value v.
+// It panics if a different type has already been registered under the same name.
+func (fs *fragmentSet) add(name string, t reflect.Type, v reflect.Value) {
+	if fs.names == nil {
+		fs.names = make(map[string]reflect.Type)
+	}
+	if prev, ok := fs.names[name]; ok {
+		if prev != t {
+			err := fmt.Errorf("fragment %q is defined by both %v and %v", name, prev, t)
+			panic(err.Error())
+		}
+		return
+	}
+	fs.names[name] = t
+	fs.types = append(fs.types, t)
+	fs.values = append(fs.values, v)
+}
+
+// asNamedFragment returns the NamedFragment implementation of struct type t,
+// if any.
+func asNamedFragment(t reflect.Type) (NamedFragment, bool) {
+	if t.Kind() != reflect.Struct {
+		return nil, false
+	}
+	if !t.Implements(namedFragmentType) && !reflect.PtrTo(t).Implements(namedFragmentType) {
+		return nil, false
+	}
+	f := reflect.New(t).Interface().(NamedFragment)
+	// Embedding a named fragment promotes its methods to the embedding struct,
+	// which doesn't make the latter a fragment of the same name.
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).Anonymous {
+			continue
+		}
+		if ef, ok := asNamedFragment(indirectType(t.Field(i).Type)); ok && ef.FragmentName() == f.FragmentName() {
+			return nil, false
+		}
+	}
+	return f, true
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// writeFragments writes the definitions of all fragments collected in fs to w.
+// Fragments spread from within fragment definitions are collected and
+// written as well.
+func writeFragments(w io.Writer, fs *fragmentSet) {
+	for i := 0; i < len(fs.types); i++ {
+		t, v := fs.types[i], fs.values[i]
+		f, _ := asNamedFragment(t)
+		io.WriteString(w, "fragment ")
+		io.WriteString(w, f.FragmentName())
+		io.WriteString(w, " on ")
+		io.WriteString(w, f.TypeCondition())
+		io.WriteString(w, "{")
+		writeStructFields(w, t, v, fs)
+		io.WriteString(w, "}")
+	}
+}
+
+// PossibleTypeRegistry maps Go interface types to the concrete Go types that
+// their values hold, for GraphQL unions or interfaces, by the GraphQL type
+// name they're returned with in __typename. It's used by
+// Client.WithPossibleTypes, and given as an option to ConstructQuery and the
+// other operation methods.
+//
+// Fields of a registered interface type, or of pointers to or slices of it,
+// are queried with __typename and an inline fragment per type, and decoded
+// into a new value of the type matching their __typename, or left nil if no
+// type matches. A type that is a NamedFragment is spread by name instead of
+// inline.
+//
+// E.g., for the following types:
+//
+//	type SearchResult interface{}
+//
+//	type Human struct{ Height Float }
+//	type Droid struct{ PrimaryFunction String }
+//
+//	possibleTypes := graphql.NewPossibleTypeRegistry().
+//		Register((*SearchResult)(nil), map[string]interface{}{
+//			"Human": Human{},
+//			"Droid": Droid{},
+//		})
+//
+// struct{Search []SearchResult} -> "{search{__typename,... on Droid{primaryFunction},... on Human{height}}}".
+type PossibleTypeRegistry struct {
+	types map[reflect.Type]map[string]reflect.Type
+}
+
+// NewPossibleTypeRegistry returns an empty PossibleTypeRegistry.
+func NewPossibleTypeRegistry() *PossibleTypeRegistry {
+	return &PossibleTypeRegistry{types: make(map[reflect.Type]map[string]reflect.Type)}
+}
+
+// Register registers the possible types of the Go interface type iface, and
+// returns the registry. iface is a pointer to the interface type, e.g.
+// (*SearchResult)(nil), and the types are given by values, e.g. Human{}.
+//
+// It panics if iface isn't a pointer to an interface type, or if a type
+// doesn't implement it. A registry shouldn't be changed once it's in use.
+func (r *PossibleTypeRegistry) Register(iface interface{}, types map[string]interface{}) *PossibleTypeRegistry {
+	t := reflect.TypeOf(iface)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+		panic(fmt.Sprintf("PossibleTypeRegistry.Register: %T isn't a pointer to an interface type", iface))
+	}
+	t = t.Elem()
+	possible := make(map[string]reflect.Type, len(types))
+	for name, v := range types {
+		vt := reflect.TypeOf(v)
+		if vt == nil || !vt.Implements(t) {
+			panic(fmt.Sprintf("PossibleTypeRegistry.Register: type %v of %q doesn't implement %v", vt, name, t))
+		}
+		possible[name] = vt
+	}
+	r.types[t] = possible
+	return r
+}
+
+// lookup returns the possible types registered for the interface type t.
+func (r *PossibleTypeRegistry) lookup(t reflect.Type) (map[string]reflect.Type, bool) {
+	if r == nil {
+		return nil, false
+	}
+	types, ok := r.types[t]
+	return types, ok
+}
+
+// decoderOptions returns the options of jsonutil decoding the registered
+// interface types.
+func (r *PossibleTypeRegistry) decoderOptions() []jsonutil.Option {
+	if r == nil || len(r.types) == 0 {
+		return nil
+	}
+	return []jsonutil.Option{jsonutil.PossibleTypes(r.types)}
+}
+
+func (r *PossibleTypeRegistry) Type() OptionType {
+	return optionTypePossibleTypes
+}
+
+func (r *PossibleTypeRegistry) String() string {
+	return ""
+}
+
+// possibleTypesOption returns the last PossibleTypeRegistry of options, if
+// any.
+func possibleTypesOption(options []Option) *PossibleTypeRegistry {
+	var possibleTypes *PossibleTypeRegistry
+	for _, option := range options {
+		if option.Type() == optionTypePossibleTypes {
+			possibleTypes = option.(*PossibleTypeRegistry)
+		}
+	}
+	return possibleTypes
+}
+
+// writePossibleTypes writes the selection set of the interface type t with the
+// given possible types, see PossibleTypeRegistry.
+func writePossibleTypes(w io.Writer, types map[string]reflect.Type, fs *fragmentSet) {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	io.WriteString(w, "{__typename")
+	for _, name := range names {
+		t := types[name]
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		io.WriteString(w, ",")
+		if _, ok := asNamedFragment(t); ok {
+			writeQuery(w, t, reflect.Value{}, true, fs)
+			continue
+		}
+		io.WriteString(w, "... on ")
+		io.WriteString(w, name)
+		writeQuery(w, t, reflect.Value{}, false, fs)
+	}
+	io.WriteString(w, "}")
+}
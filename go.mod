@@ -1,9 +1,14 @@
 module github.com/phoban01/go-graphql-client
 
-go 1.14
+go 1.18
 
 require (
 	github.com/google/uuid v1.3.0
 	github.com/graph-gophers/graphql-go v1.2.0
 	nhooyr.io/websocket v1.8.7
 )
+
+require (
+	github.com/klauspost/compress v1.10.3 // indirect
+	github.com/opentracing/opentracing-go v1.1.0 // indirect
+)
@@ -5,13 +5,16 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/phoban01/go-graphql-client/internal/jsonutil"
+	"github.com/phoban01/go-graphql-client/introspection"
 )
 
 // This function allows you to tweak the HTTP request. It might be useful to set authentication
@@ -24,6 +27,14 @@ type Client struct {
 	httpClient      *http.Client
 	requestModifier RequestModifier
 	debug           bool
+	maxResponseSize int64
+	strict          bool
+	codec           Codec
+	scalars         *ScalarRegistry
+	possibleTypes   *PossibleTypeRegistry
+	cache           *Cache
+	responseCache   ResponseStore
+	inflight        *inflightGroup
 }
 
 // NewClient creates a GraphQL client targeting the specified GraphQL server URL.
@@ -36,34 +47,39 @@ func NewClient(url string, httpClient *http.Client) *Client {
 		url:             url,
 		httpClient:      httpClient,
 		requestModifier: nil,
+		codec:           jsonutil.StdCodec,
 	}
 }
 
 // Query executes a single GraphQL query request,
 // with a query derived from q, populating the response into it.
 // q should be a pointer to struct that corresponds to the GraphQL schema.
-func (c *Client) Query(ctx context.Context, q interface{}, variables map[string]interface{}, options ...Option) (*http.Response, error) {
+// variables is either a map[string]interface{} or a struct whose fields
+// define the operation variables, see ConstructQuery.
+func (c *Client) Query(ctx context.Context, q interface{}, variables interface{}, options ...Option) (*http.Response, error) {
 	return c.do(ctx, queryOperation, q, variables, options...)
 }
 
 // NamedQuery executes a single GraphQL query request, with operation name
 //
 // Deprecated: this is the shortcut of Query method, with NewOperationName option
-func (c *Client) NamedQuery(ctx context.Context, name string, q interface{}, variables map[string]interface{}, options ...Option) (*http.Response, error) {
+func (c *Client) NamedQuery(ctx context.Context, name string, q interface{}, variables interface{}, options ...Option) (*http.Response, error) {
 	return c.do(ctx, queryOperation, q, variables, append(options, OperationName(name))...)
 }
 
 // Mutate executes a single GraphQL mutation request,
 // with a mutation derived from m, populating the response into it.
 // m should be a pointer to struct that corresponds to the GraphQL schema.
-func (c *Client) Mutate(ctx context.Context, m interface{}, variables map[string]interface{}, options ...Option) (*http.Response, error) {
+// variables is either a map[string]interface{} or a struct whose fields
+// define the operation variables, see ConstructMutation.
+func (c *Client) Mutate(ctx context.Context, m interface{}, variables interface{}, options ...Option) (*http.Response, error) {
 	return c.do(ctx, mutationOperation, m, variables, options...)
 }
 
 // NamedMutate executes a single GraphQL mutation request, with operation name
 //
 // Deprecated: this is the shortcut of Mutate method, with NewOperationName option
-func (c *Client) NamedMutate(ctx context.Context, name string, m interface{}, variables map[string]interface{}, options ...Option) (*http.Response, error) {
+func (c *Client) NamedMutate(ctx context.Context, name string, m interface{}, variables interface{}, options ...Option) (*http.Response, error) {
 	return c.do(ctx, mutationOperation, m, variables, append(options, OperationName(name))...)
 }
 
@@ -71,13 +87,13 @@ func (c *Client) NamedMutate(ctx context.Context, name string, m interface{}, va
 // with a query derived from q, populating the response into it.
 // q should be a pointer to struct that corresponds to the GraphQL schema.
 // return raw bytes message.
-func (c *Client) QueryRaw(ctx context.Context, q interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, error) {
+func (c *Client) QueryRaw(ctx context.Context, q interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
 	return c.doRaw(ctx, queryOperation, q, variables, options...)
 }
 
 // NamedQueryRaw executes a single GraphQL query request, with operation name
 // return raw bytes message.
-func (c *Client) NamedQueryRaw(ctx context.Context, name string, q interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, error) {
+func (c *Client) NamedQueryRaw(ctx context.Context, name string, q interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
 	return c.doRaw(ctx, queryOperation, q, variables, append(options, OperationName(name))...)
 }
 
@@ -85,18 +101,30 @@ func (c *Client) NamedQueryRaw(ctx context.Context, name string, q interface{},
 // with a mutation derived from m, populating the response into it.
 // m should be a pointer to struct that corresponds to the GraphQL schema.
 // return raw bytes message.
-func (c *Client) MutateRaw(ctx context.Context, m interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, error) {
+func (c *Client) MutateRaw(ctx context.Context, m interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
 	return c.doRaw(ctx, mutationOperation, m, variables, options...)
 }
 
 // NamedMutateRaw executes a single GraphQL mutation request, with operation name
 // return raw bytes message.
-func (c *Client) NamedMutateRaw(ctx context.Context, name string, m interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, error) {
+func (c *Client) NamedMutateRaw(ctx context.Context, name string, m interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
 	return c.doRaw(ctx, mutationOperation, m, variables, append(options, OperationName(name))...)
 }
 
-// buildAndRequest the common method that builds and send graphql request
-func (c *Client) buildAndRequest(ctx context.Context, op operationType, v interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, *http.Response, io.Reader, Errors) {
+// buildAndRequest the common method that builds and send graphql request.
+// If target is non-nil, the data of the response is decoded into it.
+func (c *Client) buildAndRequest(ctx context.Context, op operationType, v interface{}, variables interface{}, target interface{}, options ...Option) (*Response, *http.Response) {
+	scalars := scalarsOption(options)
+	if scalars == nil && c.scalars != nil {
+		scalars = c.scalars
+		options = append(options, scalars)
+	}
+	possibleTypes := possibleTypesOption(options)
+	if possibleTypes == nil && c.possibleTypes != nil {
+		possibleTypes = c.possibleTypes
+		options = append(options, possibleTypes)
+	}
+
 	var query string
 	var err error
 	switch op {
@@ -107,7 +135,30 @@ func (c *Client) buildAndRequest(ctx context.Context, op operationType, v interf
 	}
 
 	if err != nil {
-		return nil, nil, nil, Errors{newError(ErrGraphQLEncode, err)}
+		return errorResponse(newError(ErrGraphQLEncode, err)), nil
+	}
+
+	if c.cache != nil {
+		return c.cachedRequest(ctx, query, variables, target, scalars, possibleTypes, cachePolicyOption(options))
+	}
+	return c.request(ctx, query, variables, target, scalars, possibleTypes)
+}
+
+// request sends the query string with variables and returns the response.
+// Failures to send the request or to read the response are reported in
+// the errors of the response.
+//
+// If target is non-nil, the data of the response is decoded into it as the
+// response body is read, rather than returned as raw JSON, and failures to
+// decode it are reported after the errors returned by the server.
+//
+// The custom scalars registered in scalars are encoded and decoded with
+// their scalar, and the interface types registered in possibleTypes are
+// decoded with their possible types.
+func (c *Client) request(ctx context.Context, query string, variables interface{}, target interface{}, scalars *ScalarRegistry, possibleTypes *PossibleTypeRegistry) (*Response, *http.Response) {
+	payload, err := variablesPayload(variables, scalars)
+	if err != nil {
+		return errorResponse(newError(ErrGraphQLEncode, err)), nil
 	}
 
 	in := struct {
@@ -115,22 +166,23 @@ func (c *Client) buildAndRequest(ctx context.Context, op operationType, v interf
 		Variables map[string]interface{} `json:"variables,omitempty"`
 	}{
 		Query:     query,
-		Variables: variables,
+		Variables: payload,
 	}
-	var buf bytes.Buffer
-	err = json.NewEncoder(&buf).Encode(in)
+	body, err := c.codec.Marshal(in)
 	if err != nil {
-		return nil, nil, nil, Errors{newError(ErrGraphQLEncode, err)}
+		return errorResponse(newError(ErrGraphQLEncode, err)), nil
 	}
+	// End the body with a newline, as json.Encoder does.
+	body = append(body, '\n')
 
-	reqReader := bytes.NewReader(buf.Bytes())
+	reqReader := bytes.NewReader(body)
 	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, reqReader)
 	if err != nil {
 		e := newError(ErrRequestError, fmt.Errorf("problem constructing request: %w", err))
 		if c.debug {
 			e = e.withRequest(request, reqReader)
 		}
-		return nil, nil, nil, Errors{e}
+		return errorResponse(e), nil
 	}
 	request.Header.Add("Content-Type", "application/json")
 
@@ -138,126 +190,319 @@ func (c *Client) buildAndRequest(ctx context.Context, op operationType, v interf
 		c.requestModifier(request)
 	}
 
-	resp, err := c.httpClient.Do(request)
-
-	if c.debug {
-		reqReader.Seek(0, io.SeekStart)
+	// Queries are answered from the response cache while their cached
+	// response is fresh, and revalidate it with its ETag once it's stale.
+	var cacheKey string
+	var cached, store *CachedResponse
+	if c.responseCache != nil {
+		if key, ok := responseCacheKey(request, query, payload); ok {
+			cacheKey = key
+			cached, _ = c.responseCache.Get(key)
+		}
 	}
 
-	if err != nil {
-		e := newError(ErrRequestError, err)
-		if c.debug {
-			e = e.withRequest(request, reqReader)
+	var resp *http.Response
+	var r io.Reader
+	if cached != nil && time.Now().Before(cached.Expires) {
+		resp, r = cachedHTTPResponse(request, cached), bytes.NewReader(cached.Body)
+	} else {
+		if etag := cachedETag(cached); etag != "" {
+			request.Header.Set("If-None-Match", etag)
 		}
-		return nil, nil, nil, Errors{e}
-	}
-	defer resp.Body.Close()
+		// Identical queries in flight are sent once, if deduplicated.
+		var inflight string
+		if c.inflight != nil && isQueryDocument(query) {
+			inflight = inflightKey(request, body)
+		}
+		resp, err = c.send(request, inflight)
 
-	r := resp.Body
+		if c.debug {
+			reqReader.Seek(0, io.SeekStart)
+		}
 
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		gr, err := gzip.NewReader(r)
 		if err != nil {
-			return nil, nil, nil, Errors{newError(ErrJsonDecode, fmt.Errorf("problem trying to create gzip reader: %w", err))}
+			e := newError(ErrRequestError, err)
+			if c.debug {
+				e = e.withRequest(request, reqReader)
+			}
+			return errorResponse(e), nil
 		}
-		defer gr.Close()
-		r = gr
-	}
+		defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err := newError(ErrRequestError, fmt.Errorf("%v; body: %q", resp.Status, body))
+		r = resp.Body
 
-		if c.debug {
-			err = err.withRequest(request, reqReader)
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			gr, err := gzip.NewReader(r)
+			if err != nil {
+				return errorResponse(newError(ErrJsonDecode, fmt.Errorf("problem trying to create gzip reader: %w", err))), nil
+			}
+			defer gr.Close()
+			r = gr
+		}
+		if c.maxResponseSize > 0 {
+			// Limit the decompressed size, which a gzip-compressed body may inflate.
+			r = &limitedReader{r: r, n: c.maxResponseSize, max: c.maxResponseSize}
 		}
-		return nil, nil, nil, Errors{err}
-	}
 
-	var out struct {
-		Data   *json.RawMessage
-		Errors Errors
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cachedETag(cached) != "":
+			cached = c.revalidateResponse(cacheKey, cached, resp.Header)
+			resp, r = cachedHTTPResponse(request, cached), bytes.NewReader(cached.Body)
+		case resp.StatusCode != http.StatusOK:
+			body, _ := ioutil.ReadAll(r)
+			err := newError(ErrRequestError, fmt.Errorf("%v; body: %q", resp.Status, body))
+
+			if c.debug {
+				err = err.withRequest(request, reqReader)
+			}
+			return errorResponse(err), nil
+		case cacheKey != "":
+			if expires, ok := responseExpires(resp.Header, time.Now()); ok {
+				body, err := ioutil.ReadAll(r)
+				if err != nil {
+					return errorResponse(newError(ErrJsonDecode, err)), nil
+				}
+				store = &CachedResponse{Body: body, Header: cachedHeader(resp.Header), Expires: expires}
+				r = bytes.NewReader(body)
+			}
+		}
 	}
 
 	// copy the response reader for debugging
 	var respReader *bytes.Reader
 	if c.debug {
-		body, err := ioutil.ReadAll(resp.Body)
+		body, err := ioutil.ReadAll(r)
 		if err != nil {
-			return nil, nil, nil, Errors{newError(ErrJsonDecode, err)}
+			return errorResponse(newError(ErrJsonDecode, err)), nil
 		}
 		respReader = bytes.NewReader(body)
-		r = io.NopCloser(respReader)
-	}
-
-	err = json.NewDecoder(r).Decode(&out)
-
-	if c.debug {
-		respReader.Seek(0, io.SeekStart)
+		r = respReader
 	}
 
+	out, dataErr, err := decodeResponse(r, target, c.codec, c.decoderOptions(scalars, possibleTypes)...)
 	if err != nil {
 		we := newError(ErrJsonDecode, err)
 		if c.debug {
+			respReader.Seek(0, io.SeekStart)
 			we = we.withRequest(request, reqReader).
 				withResponse(resp, respReader)
 		}
-		return nil, nil, nil, Errors{we}
+		return errorResponse(we), nil
+	}
+	// Responses with errors aren't cached, since the errors may be transient.
+	if store != nil && len(out.Errors) == 0 {
+		c.responseCache.Set(cacheKey, store)
 	}
 
 	if len(out.Errors) > 0 {
 		if c.debug && (out.Errors[0].Extensions == nil || out.Errors[0].Extensions["request"] == nil) {
+			respReader.Seek(0, io.SeekStart)
 			out.Errors[0] = out.Errors[0].
 				withRequest(request, reqReader).
 				withResponse(resp, respReader)
 		}
+	}
+	if dataErr != nil {
+		we := newError(ErrGraphQLDecode, dataErr)
+		if c.debug {
+			respReader.Seek(0, io.SeekStart)
+			we = we.withResponse(resp, respReader)
+		}
+		out.Errors = append(out.Errors, we)
+	}
+
+	return out, resp
+}
+
+// decoderOptions returns the options of the decoding of the data of
+// responses into query structs.
+func (c *Client) decoderOptions(scalars *ScalarRegistry, possibleTypes *PossibleTypeRegistry) []jsonutil.Option {
+	opts := []jsonutil.Option{jsonutil.WithCodec(c.codec)}
+	if c.strict {
+		opts = append(opts, jsonutil.Strict())
+	}
+	opts = append(opts, scalars.decoderOptions()...)
+	return append(opts, possibleTypes.decoderOptions()...)
+}
+
+// decodeResponse decodes the response body read from r with codec. If target
+// is non-nil, the data of the response is decoded into it, as it's read from
+// r, and the failure to do so is returned as dataErr. Otherwise the data is
+// kept in the response as raw JSON.
+func decodeResponse(r io.Reader, target interface{}, codec Codec, opts ...jsonutil.Option) (out *Response, dataErr error, err error) {
+	dec := codec.NewTokenizer(r)
+	if tok, err := dec.Token(); err != nil {
+		return nil, nil, err
+	} else if tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("invalid response %v, want a JSON object", tok)
+	}
+	out = &Response{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		// Only the data is decoded with numbers kept as json.Number, so that
+		// the errors and extensions are decoded like codec.Unmarshal does.
+		var raw json.RawMessage
+		switch tok {
+		case "data":
+			if target == nil {
+				err = dec.Decode(&out.Data)
+			} else if err := jsonutil.DecodeGraphQL(dec, target, opts...); err != nil {
+				var decodeErr *DecodeError
+				if errors.As(err, &decodeErr) {
+					decodeErr.Path = dataPath(decodeErr.Path)
+				}
+				dataErr = err
+			}
+		case "errors":
+			if err = dec.Decode(&raw); err == nil {
+				err = codec.Unmarshal(raw, &out.Errors)
+			}
+		case "extensions":
+			if err = dec.Decode(&raw); err == nil {
+				err = codec.Unmarshal(raw, &out.Extensions)
+			}
+		default:
+			err = dec.Decode(&raw)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	return out, dataErr, nil
+}
 
-		return out.Data, resp, respReader, out.Errors
+// dataPath returns the JSON path in the response of the value at path in its data.
+func dataPath(path string) string {
+	switch {
+	case path == "":
+		return "data"
+	case strings.HasPrefix(path, "["):
+		return "data" + path
 	}
+	return "data." + path
+}
+
+// limitedReader reads from r until max bytes have been read,
+// and fails once more are read.
+type limitedReader struct {
+	r      io.Reader
+	n, max int64 // n is the number of bytes left to read.
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.tooLarge()
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		// Drop the extra byte read to tell that the body is too large.
+		n--
+		err = l.tooLarge()
+	}
+	return n, err
+}
 
-	return out.Data, resp, respReader, nil
+func (l *limitedReader) tooLarge() error {
+	return fmt.Errorf("response body exceeds the maximum size of %d bytes", l.max)
 }
 
 // do executes a single GraphQL operation.
 // return raw message and error
-func (c *Client) doRaw(ctx context.Context, op operationType, v interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, error) {
-	data, _, _, err := c.buildAndRequest(ctx, op, v, variables, options...)
-	if len(err) > 0 {
-		return data, err
+func (c *Client) doRaw(ctx context.Context, op operationType, v interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
+	out, _ := c.buildAndRequest(ctx, op, v, variables, nil, options...)
+	if len(out.Errors) > 0 {
+		return out.Data, out.Errors
 	}
-	return data, nil
+	return out.Data, nil
 }
 
 // do executes a single GraphQL operation and unmarshal json.
-func (c *Client) do(ctx context.Context, op operationType, v interface{}, variables map[string]interface{}, options ...Option) (*http.Response, error) {
-	data, resp, respBuf, errs := c.buildAndRequest(ctx, op, v, variables, options...)
+func (c *Client) do(ctx context.Context, op operationType, v interface{}, variables interface{}, options ...Option) (*http.Response, error) {
+	out, resp := c.buildAndRequest(ctx, op, v, variables, v, options...)
+	if len(out.Errors) > 0 {
+		return resp, out.Errors
+	}
+	return resp, nil
+}
 
-	if data != nil {
-		err := jsonutil.UnmarshalGraphQL(*data, v)
-		if err != nil {
-			we := newError(ErrGraphQLDecode, err)
-			if c.debug {
-				we = we.withResponse(resp, respBuf)
-			}
-			errs = append(errs, we)
-		}
+// Exec executes a single GraphQL operation given as a query string,
+// populating the response into v. Unlike Query and Mutate, the query isn't
+// derived from v, which is only used to decode the response.
+// variables is either a map[string]interface{} or a variables struct.
+func (c *Client) Exec(ctx context.Context, query string, v interface{}, variables interface{}) (*http.Response, error) {
+	out, resp := c.request(ctx, query, variables, v, c.scalars, c.possibleTypes)
+	if len(out.Errors) > 0 {
+		return resp, out.Errors
 	}
+	return resp, nil
+}
 
-	if len(errs) > 0 {
-		return resp, errs
+// ExecRaw executes a single GraphQL operation given as a query string,
+// and returns the raw JSON data of the response.
+func (c *Client) ExecRaw(ctx context.Context, query string, variables interface{}) (*json.RawMessage, error) {
+	out, _ := c.request(ctx, query, variables, nil, c.scalars, c.possibleTypes)
+	if len(out.Errors) > 0 {
+		return out.Data, out.Errors
 	}
+	return out.Data, nil
+}
 
-	return resp, nil
+// ExecResponse executes a single GraphQL operation given as a query string,
+// and returns the whole response, including the extensions returned by the
+// server. The returned error, if any, holds the errors of the response,
+// which include failures to send the request or to read the response.
+func (c *Client) ExecResponse(ctx context.Context, query string, variables interface{}) (*Response, error) {
+	out, _ := c.request(ctx, query, variables, nil, c.scalars, c.possibleTypes)
+	if len(out.Errors) > 0 {
+		return out, out.Errors
+	}
+	return out, nil
+}
+
+// Introspect executes the introspection query and returns the schema of the server.
+func (c *Client) Introspect(ctx context.Context) (*introspection.Schema, error) {
+	data, err := c.ExecRaw(ctx, introspection.Query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, Errors{newError(ErrGraphQLDecode, errors.New("no data in introspection response"))}
+	}
+	schema, err := introspection.Parse(*data)
+	if err != nil {
+		return nil, Errors{newError(ErrGraphQLDecode, err)}
+	}
+	return schema, nil
 }
 
 // Returns a copy of the client with the request modifier set. This allows you to reuse the same
 // TCP connection for multiple slightly different requests to the same server
 // (i.e. different authentication headers for multitenant applications)
+//
+// The copy has neither a cache nor a response cache, since the modifier may
+// authenticate as another user: set them with WithCache and
+// WithResponseCache.
 func (c *Client) WithRequestModifier(f RequestModifier) *Client {
 	return &Client{
 		url:             c.url,
 		httpClient:      c.httpClient,
 		requestModifier: f,
+		maxResponseSize: c.maxResponseSize,
+		strict:          c.strict,
+		codec:           c.codec,
+		scalars:         c.scalars,
+		possibleTypes:   c.possibleTypes,
+		inflight:        c.inflight,
 	}
 }
 
@@ -268,9 +513,220 @@ func (c *Client) WithDebug(debug bool) *Client {
 		httpClient:      c.httpClient,
 		requestModifier: c.requestModifier,
 		debug:           debug,
+		maxResponseSize: c.maxResponseSize,
+		strict:          c.strict,
+		codec:           c.codec,
+		scalars:         c.scalars,
+		possibleTypes:   c.possibleTypes,
+		cache:           c.cache,
+		responseCache:   c.responseCache,
+		inflight:        c.inflight,
+	}
+}
+
+// WithMaxResponseSize returns a copy of the client that reads response
+// bodies of at most n bytes, after decompression. Larger responses fail with
+// an ErrJsonDecode error. A non-positive n means no limit, the default.
+func (c *Client) WithMaxResponseSize(n int64) *Client {
+	return &Client{
+		url:             c.url,
+		httpClient:      c.httpClient,
+		requestModifier: c.requestModifier,
+		debug:           c.debug,
+		maxResponseSize: n,
+		strict:          c.strict,
+		codec:           c.codec,
+		scalars:         c.scalars,
+		possibleTypes:   c.possibleTypes,
+		cache:           c.cache,
+		responseCache:   c.responseCache,
+		inflight:        c.inflight,
+	}
+}
+
+// WithStrictDecoding returns a copy of the client that, if strict is true,
+// fails to decode responses that miss a field of the query struct that isn't
+// nullable, i.e. isn't a pointer, an interface or an Optional. This catches drift between
+// query structs and the server responses, e.g. in CI. See UnmarshalGraphQLStrict.
+func (c *Client) WithStrictDecoding(strict bool) *Client {
+	return &Client{
+		url:             c.url,
+		httpClient:      c.httpClient,
+		requestModifier: c.requestModifier,
+		debug:           c.debug,
+		maxResponseSize: c.maxResponseSize,
+		strict:          strict,
+		codec:           c.codec,
+		scalars:         c.scalars,
+		possibleTypes:   c.possibleTypes,
+		cache:           c.cache,
+		responseCache:   c.responseCache,
+		inflight:        c.inflight,
+	}
+}
+
+// WithCodec returns a copy of the client that encodes requests and decodes
+// responses with codec, rather than with encoding/json. A nil codec restores
+// encoding/json.
+func (c *Client) WithCodec(codec Codec) *Client {
+	if codec == nil {
+		codec = jsonutil.StdCodec
+	}
+	return &Client{
+		url:             c.url,
+		httpClient:      c.httpClient,
+		requestModifier: c.requestModifier,
+		debug:           c.debug,
+		maxResponseSize: c.maxResponseSize,
+		strict:          c.strict,
+		codec:           codec,
+		scalars:         c.scalars,
+		possibleTypes:   c.possibleTypes,
+		cache:           c.cache,
+		responseCache:   c.responseCache,
+		inflight:        c.inflight,
+	}
+}
+
+// WithScalars returns a copy of the client that names, encodes and decodes
+// the custom scalars registered in scalars, see ScalarRegistry. A registry
+// given as an option to an operation takes precedence over it.
+func (c *Client) WithScalars(scalars *ScalarRegistry) *Client {
+	return &Client{
+		url:             c.url,
+		httpClient:      c.httpClient,
+		requestModifier: c.requestModifier,
+		debug:           c.debug,
+		maxResponseSize: c.maxResponseSize,
+		strict:          c.strict,
+		codec:           c.codec,
+		scalars:         scalars,
+		possibleTypes:   c.possibleTypes,
+		cache:           c.cache,
+		responseCache:   c.responseCache,
+		inflight:        c.inflight,
+	}
+}
+
+// WithPossibleTypes returns a copy of the client that queries and decodes
+// the interface types registered in possibleTypes with their possible types,
+// see PossibleTypeRegistry. A registry given as an option to an operation
+// takes precedence over it.
+func (c *Client) WithPossibleTypes(possibleTypes *PossibleTypeRegistry) *Client {
+	return &Client{
+		url:             c.url,
+		httpClient:      c.httpClient,
+		requestModifier: c.requestModifier,
+		debug:           c.debug,
+		maxResponseSize: c.maxResponseSize,
+		strict:          c.strict,
+		codec:           c.codec,
+		scalars:         c.scalars,
+		possibleTypes:   possibleTypes,
+		cache:           c.cache,
+		responseCache:   c.responseCache,
+		inflight:        c.inflight,
+	}
+}
+
+// WithCache returns a copy of the client that caches the results of queries
+// and mutations in cache, and answers queries from it according to their
+// CachePolicy option, see Cache. Clients may share a cache. A nil cache
+// disables caching, the default.
+//
+// A cache is per credential: the objects it holds answer queries without
+// sending them, so clients that authenticate as different users must not
+// share a cache. WithRequestModifier returns a client without a cache.
+//
+// Exec and the other methods that take a query string don't use the cache.
+func (c *Client) WithCache(cache *Cache) *Client {
+	return &Client{
+		url:             c.url,
+		httpClient:      c.httpClient,
+		requestModifier: c.requestModifier,
+		debug:           c.debug,
+		maxResponseSize: c.maxResponseSize,
+		strict:          c.strict,
+		codec:           c.codec,
+		scalars:         c.scalars,
+		possibleTypes:   c.possibleTypes,
+		cache:           cache,
+		responseCache:   c.responseCache,
+		inflight:        c.inflight,
+	}
+}
+
+// WithResponseCache returns a copy of the client that caches the whole
+// responses of queries in store, see NewLRUResponseStore, honoring their
+// HTTP cache headers: a response is stored if it has a Cache-Control max-age
+// or an ETag, unless Cache-Control has no-store or the response has errors.
+// It answers the same query, with the same variables and operation name,
+// until it's stale, and is then revalidated with If-None-Match. Mutations
+// aren't cached. A nil store disables the response cache, the default.
+//
+// Responses are stored by endpoint URL, request header, query and variables,
+// the header being the one set by the request modifier, so that clients of
+// different endpoints or users may share a store.
+func (c *Client) WithResponseCache(store ResponseStore) *Client {
+	return &Client{
+		url:             c.url,
+		httpClient:      c.httpClient,
+		requestModifier: c.requestModifier,
+		debug:           c.debug,
+		maxResponseSize: c.maxResponseSize,
+		strict:          c.strict,
+		codec:           c.codec,
+		scalars:         c.scalars,
+		possibleTypes:   c.possibleTypes,
+		cache:           c.cache,
+		responseCache:   store,
+		inflight:        c.inflight,
 	}
 }
 
+// WithDeduplication returns a copy of the client that, if dedup is true,
+// sends identical queries in flight once: a query whose request has the same
+// body and header as a request being sent waits for its response, and
+// decodes it into its own query struct. Mutations are never deduplicated.
+// The copies of the client share the requests in flight.
+//
+// The request isn't canceled with the context of the query that sent it,
+// since other queries may wait for it. Each query stops waiting when its own
+// context is done, and the request is canceled once none waits for it.
+func (c *Client) WithDeduplication(dedup bool) *Client {
+	var inflight *inflightGroup
+	if dedup {
+		inflight = newInflightGroup()
+	}
+	return &Client{
+		url:             c.url,
+		httpClient:      c.httpClient,
+		requestModifier: c.requestModifier,
+		debug:           c.debug,
+		maxResponseSize: c.maxResponseSize,
+		strict:          c.strict,
+		codec:           c.codec,
+		scalars:         c.scalars,
+		possibleTypes:   c.possibleTypes,
+		cache:           c.cache,
+		responseCache:   c.responseCache,
+		inflight:        inflight,
+	}
+}
+
+// Response is a response from a GraphQL server.
+//
+// Specification: https://spec.graphql.org/June2018/#sec-Response-Format.
+type Response struct {
+	Data       *json.RawMessage       `json:"data,omitempty"`
+	Errors     Errors                 `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func errorResponse(err Error) *Response {
+	return &Response{Errors: Errors{err}}
+}
+
 // errors represents the "errors" array in a response from a GraphQL server.
 // If returned via error interface, the slice is expected to contain at least 1 element.
 //
@@ -284,6 +740,9 @@ type Error struct {
 		Line   int `json:"line"`
 		Column int `json:"column"`
 	} `json:"locations"`
+
+	// err is the error that the client failed with, if any.
+	err error
 }
 
 // Error implements error interface.
@@ -291,6 +750,35 @@ func (e Error) Error() string {
 	return fmt.Sprintf("Message: %s, Locations: %+v", e.Message, e.Locations)
 }
 
+// Unwrap returns the error that the client failed with, such as a
+// *DecodeError, or nil if the error was returned by the server.
+func (e Error) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether any of the errors matches target, so that errors.Is
+// looks into all of them.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target, so that errors.As
+// finds the error that the client failed with, such as a *DecodeError, in any
+// of them.
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // Error implements error interface.
 func (e Errors) Error() string {
 	b := strings.Builder{}
@@ -318,6 +806,7 @@ func newError(code string, err error) Error {
 		Extensions: map[string]interface{}{
 			"code": code,
 		},
+		err: err,
 	}
 }
 
@@ -366,6 +855,61 @@ func UnmarshalGraphQL(data []byte, v interface{}) error {
 	return jsonutil.UnmarshalGraphQL(data, v)
 }
 
+// Optional holds a field of type T that may be absent from the response, be
+// null, or have a value. It's queried as a field of type T, and decoded
+// recording whether the field was present and null, which a pointer can't
+// tell apart. E.g., for the following type:
+//
+//	var q struct {
+//		Viewer struct {
+//			Login graphql.String
+//			Bio   graphql.Optional[graphql.String] `graphql:"bio @include(if: $withBio)"`
+//		}
+//	}
+//
+// q.Viewer.Bio.Present is false if $withBio is false, Null is true if the bio
+// is null, and Value holds the bio otherwise. Get returns the value and
+// whether the field had one.
+type Optional[T any] struct {
+	// Value is the value of the field, or the zero value of T if it's
+	// absent or null.
+	Value T
+	// Present reports whether the field was in the response.
+	Present bool
+	// Null reports whether the field was null.
+	Null bool
+
+	jsonutil.OptionalField
+}
+
+// Get returns the value of the field, and whether it had a value, rather than
+// being absent or null.
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Present && !o.Null
+}
+
+// DecodeError is the error of decoding a JSON value into a query struct, with
+// the JSON path of the value and the Go struct field it's decoded into. The
+// ErrGraphQLDecode errors of the client wrap it, with the path starting with
+// "data", e.g. "data.repository.issues.nodes[3].createdAt":
+//
+//	var decodeErr *graphql.DecodeError
+//	if errors.As(err, &decodeErr) {
+//		log.Printf("%s decoded into %s: %v", decodeErr.Path, decodeErr.Field, decodeErr.Err)
+//	}
+type DecodeError = jsonutil.DecodeError
+
+// UnmarshalGraphQLStrict is like UnmarshalGraphQL, but fails when a field
+// of v that isn't nullable, i.e. isn't a pointer, an interface or an
+// Optional, is missing from data. The error reports the JSON path of the field.
+//
+// Fields of inline fragments with a type condition or a directive, of named
+// fragments, and of fields with an @include or @skip directive aren't required,
+// since they may not apply to the returned data.
+func UnmarshalGraphQLStrict(data []byte, v interface{}) error {
+	return jsonutil.UnmarshalGraphQL(data, v, jsonutil.Strict())
+}
+
 type operationType uint8
 
 const (
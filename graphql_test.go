@@ -1,16 +1,26 @@
 package graphql_test
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	graphqlserver "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/example/starwars"
+	"github.com/graph-gophers/graphql-go/relay"
 	"github.com/phoban01/go-graphql-client"
+	"github.com/phoban01/go-graphql-client/introspection"
 )
 
 func TestClient_Query_partialDataWithErrorResponse(t *testing.T) {
@@ -254,6 +264,36 @@ func TestClient_Query_errorStatusCode(t *testing.T) {
 	}
 }
 
+// Test that the body of an error response is reported decompressed, and
+// limited by the maximum response size.
+func TestClient_Query_errorStatusCode_gzip(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusBadGateway)
+		gw := gzip.NewWriter(w)
+		mustWrite(gw, "upstream unavailable")
+		if err := gw.Close(); err != nil {
+			panic(err)
+		}
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Name graphql.String
+		}
+	}
+	_, err := client.Query(context.Background(), &q, nil)
+	if got, want := fmt.Sprint(err), `Message: 502 Bad Gateway; body: "upstream unavailable", Locations: []`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+	_, err = client.WithMaxResponseSize(8).Query(context.Background(), &q, nil)
+	if got, want := fmt.Sprint(err), `Message: 502 Bad Gateway; body: "upstream", Locations: []`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
+
 // Test that an empty (but non-nil) variables map is
 // handled no differently than a nil variables map.
 func TestClient_Query_emptyVariables(t *testing.T) {
@@ -315,6 +355,1154 @@ func TestClient_Query_ignoreFields(t *testing.T) {
 	}
 }
 
+func TestClient_Mutate_variablesStruct(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"mutation ($ep:Episode!$review:ReviewInput!){createReview(episode: $ep, review: $review){stars}}","variables":{"ep":"JEDI","review":{"commentary":"This is a great movie!","stars":5}}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"createReview": {"stars": 5}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	type Episode string
+	type ReviewInput struct {
+		Stars      graphql.Int
+		Commentary graphql.String `graphql:"commentary" json:"text"`
+	}
+	var m struct {
+		CreateReview struct {
+			Stars graphql.Int
+		} `graphql:"createReview(episode: $ep, review: $review)"`
+	}
+	variables := struct {
+		Episode Episode `graphql:"ep"`
+		Review  ReviewInput
+	}{
+		Episode: "JEDI",
+		Review: ReviewInput{
+			Stars:      5,
+			Commentary: "This is a great movie!",
+		},
+	}
+	_, err := client.Mutate(context.Background(), &m, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.CreateReview.Stars, graphql.Int(5); got != want {
+		t.Errorf("got m.CreateReview.Stars: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Exec(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query ($id: ID!) { user(id: $id) { name } }","variables":{"id":"1"}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Name string
+		}
+	}
+	_, err := client.Exec(context.Background(), "query ($id: ID!) { user(id: $id) { name } }", &q, map[string]interface{}{
+		"id": graphql.ID("1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Name, "Gopher"; got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_ExecResponse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{
+			"data": {"user": {"name": "Gopher", "email": null}},
+			"errors": [{"message": "forbidden", "path": ["user", "email"]}],
+			"extensions": {"cost": 2}
+		}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	resp, err := client.ExecResponse(context.Background(), "{user{name,email}}", nil)
+	if err == nil {
+		t.Fatal("got nil error, want the errors of the response")
+	}
+	if got, want := string(*resp.Data), `{"user": {"name": "Gopher", "email": null}}`; got != want {
+		t.Errorf("got data %s, want %s", got, want)
+	}
+	if got, want := resp.Errors[0].Message, "forbidden"; got != want {
+		t.Errorf("got error message %q, want %q", got, want)
+	}
+	if got, want := resp.Extensions["cost"], float64(2); got != want {
+		t.Errorf("got cost extension %v, want %v", got, want)
+	}
+}
+
+// Test that the data is decoded as the response is read, with the errors
+// of the server, which may follow the data, reported before decoding errors.
+func TestClient_Query_dataDecodeError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{
+			"data": {"user": {"name": 42, "friends": [{"name": "Luke"}]}, "viewer": {"login": "gopher"}},
+			"errors": [{"message": "forbidden"}]
+		}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		Viewer struct {
+			Login graphql.String
+		}
+		User struct {
+			Name    graphql.String
+			Friends []struct{ Name graphql.String }
+		}
+	}
+	_, err := client.Query(context.Background(), &q, nil)
+	var errs graphql.Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("got error %v, want the server error and the decoding error", err)
+	}
+	if got, want := errs[0].Message, "forbidden"; got != want {
+		t.Errorf("got error message %q, want %q", got, want)
+	}
+	if got, want := errs[1].Extensions["code"], graphql.ErrGraphQLDecode; got != want {
+		t.Errorf("got error code %v, want %v", got, want)
+	}
+	var decodeErr *graphql.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("got error %v, want a DecodeError", err)
+	}
+	if got, want := decodeErr.Path, "data.user.name"; got != want {
+		t.Errorf("got path %q, want %q", got, want)
+	}
+	if got, want := decodeErr.Field, "User.Name"; got != want {
+		t.Errorf("got field %q, want %q", got, want)
+	}
+}
+
+func TestClient_Query_invalidJSON(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Name graphql.String
+		}
+	}
+	_, err := client.Query(context.Background(), &q, nil)
+	var errs graphql.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want a single error", err)
+	}
+	if got, want := errs[0].Extensions["code"], graphql.ErrJsonDecode; got != want {
+		t.Errorf("got error code %v, want %v", got, want)
+	}
+}
+
+func TestClient_WithMaxResponseSize(t *testing.T) {
+	const body = `{"data": {"user": {"name": "Gopher"}}}`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, body)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Name graphql.String
+		}
+	}
+	if _, err := client.WithMaxResponseSize(int64(len(body))).Query(context.Background(), &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Name, graphql.String("Gopher"); got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+
+	for _, debug := range []bool{false, true} {
+		_, err := client.WithDebug(debug).WithMaxResponseSize(int64(len(body))-1).Query(context.Background(), &q, nil)
+		var errs graphql.Errors
+		if !errors.As(err, &errs) {
+			t.Fatalf("debug %v: got error %v, want graphql.Errors", debug, err)
+		}
+		if got, want := errs[0].Message, "response body exceeds the maximum size of 37 bytes"; got != want {
+			t.Errorf("debug %v: got error message %q, want %q", debug, got, want)
+		}
+		if got, want := errs[0].Extensions["code"], graphql.ErrJsonDecode; got != want {
+			t.Errorf("debug %v: got error code %v, want %v", debug, got, want)
+		}
+	}
+}
+
+func TestClient_WithStrictDecoding(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Name  graphql.String
+			Email graphql.String
+		}
+	}
+	if _, err := client.Query(context.Background(), &q, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.WithStrictDecoding(true).Query(context.Background(), &q, nil)
+	var errs graphql.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error %v, want graphql.Errors", err)
+	}
+	if got, want := errs[0].Message, `data.user.email (User.Email): non-nullable field is missing`; got != want {
+		t.Errorf("got error message %q, want %q", got, want)
+	}
+	if got, want := errs[0].Extensions["code"], graphql.ErrGraphQLDecode; got != want {
+		t.Errorf("got error code %v, want %v", got, want)
+	}
+}
+
+// countingCodec is the codec of encoding/json, counting its uses.
+type countingCodec struct {
+	marshal, unmarshal, tokenizers int
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshal++
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshal++
+	return json.Unmarshal(data, v)
+}
+
+func (c *countingCodec) NewTokenizer(r io.Reader) graphql.Tokenizer {
+	c.tokenizers++
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec
+}
+
+func TestClient_WithCodec(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := mustRead(req.Body), `{"query":"{user{name}}"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}, "errors": [{"message": "deprecated"}]}`)
+	})
+	codec := &countingCodec{}
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).WithCodec(codec)
+
+	var q struct {
+		User struct {
+			Name graphql.String
+		}
+	}
+	_, err := client.Query(context.Background(), &q, nil)
+	if err == nil || err.Error() != "Message: deprecated, Locations: []" {
+		t.Errorf("got error %v, want the error of the response", err)
+	}
+	if got, want := q.User.Name, graphql.String("Gopher"); got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+	if codec.marshal != 1 || codec.unmarshal != 1 || codec.tokenizers != 1 {
+		t.Errorf("got %+v, want the codec used once to encode the request, and to read and unmarshal the response", *codec)
+	}
+}
+
+func TestClient_WithScalars(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := mustRead(req.Body), `{"query":"query ($since:Timestamp!){events(since: $since){at}}","variables":{"since":1634515200}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"events": [{"at": 1634518800}]}}`)
+	})
+	// Timestamps are sent and received as Unix seconds.
+	scalars := graphql.NewScalarRegistry().Register(time.Time{}, graphql.Scalar{
+		Name: "Timestamp",
+		Encode: func(v interface{}) (interface{}, error) {
+			return v.(time.Time).Unix(), nil
+		},
+		Decode: func(data []byte, v interface{}) error {
+			var sec int64
+			if err := json.Unmarshal(data, &sec); err != nil {
+				return err
+			}
+			*v.(*time.Time) = time.Unix(sec, 0).UTC()
+			return nil
+		},
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).WithScalars(scalars)
+
+	var q struct {
+		Events []struct {
+			At time.Time
+		} `graphql:"events(since: $since)"`
+	}
+	variables := map[string]interface{}{
+		"since": time.Date(2021, 10, 18, 0, 0, 0, 0, time.UTC),
+	}
+	if _, err := client.Query(context.Background(), &q, variables); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2021, 10, 18, 1, 0, 0, 0, time.UTC)
+	if len(q.Events) != 1 || !q.Events[0].At.Equal(want) {
+		t.Errorf("got events: %v, want one at %v", q.Events, want)
+	}
+}
+
+type (
+	searchResult interface{}
+	searchUser   struct{ Login graphql.String }
+	searchRepo   struct{ Name graphql.String }
+)
+
+func TestClient_WithPossibleTypes(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"search": [{"__typename": "User", "login": "gopher"}, {"__typename": "Repository", "name": "go"}]}}`)
+	})
+	codec := &countingCodec{}
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).WithCodec(codec)
+	possibleTypes := graphql.NewPossibleTypeRegistry().Register((*searchResult)(nil), map[string]interface{}{
+		"User":       searchUser{},
+		"Repository": searchRepo{},
+	})
+
+	var q struct {
+		Search []searchResult
+	}
+	if _, err := client.WithPossibleTypes(possibleTypes).Query(context.Background(), &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []searchResult{searchUser{Login: "gopher"}, searchRepo{Name: "go"}}; !reflect.DeepEqual(q.Search, want) {
+		t.Errorf("got %#v, want %#v", q.Search, want)
+	}
+	// The list and the __typename of its objects are decoded with the codec.
+	if codec.unmarshal != 3 {
+		t.Errorf("got %d calls to Unmarshal, want 3", codec.unmarshal)
+	}
+}
+
+func TestClient_Paginate(t *testing.T) {
+	// The server has issues 1 to 5, in pages of the requested size.
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var in struct {
+			Query     string
+			Variables struct {
+				First  *int
+				Last   *int
+				After  *string
+				Before *string
+			}
+		}
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		queries = append(queries, in.Query)
+		start, end := 1, 6
+		if in.Variables.After != nil {
+			fmt.Sscan(*in.Variables.After, &start)
+			start++
+		}
+		if in.Variables.Before != nil {
+			fmt.Sscan(*in.Variables.Before, &end)
+		}
+		if in.Variables.First != nil && start+*in.Variables.First < end {
+			end = start + *in.Variables.First
+		}
+		if in.Variables.Last != nil && end-*in.Variables.Last > start {
+			start = end - *in.Variables.Last
+		}
+		var nodes []string
+		for i := start; i < end; i++ {
+			nodes = append(nodes, fmt.Sprintf(`{"number": %d}`, i))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(in.Query, "edges") {
+			mustWrite(w, fmt.Sprintf(`{"data": {"repository": {"issues": {
+				"edges": [{"node": %s}],
+				"pageInfo": {"startCursor": "%d", "hasPreviousPage": %t}
+			}}}}`, strings.Join(nodes, `}, {"node": `), start, start > 1))
+			return
+		}
+		mustWrite(w, fmt.Sprintf(`{"data": {"repository": {"labels": [{"name": "bug"}, {"name": "feature"}], "issues": {
+			"nodes": [%s],
+			"pageInfo": {"endCursor": "%d", "hasNextPage": %t}
+		}}}}`, strings.Join(nodes, ","), end-1, end < 6))
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	type issue struct{ Number graphql.Int }
+	type forward struct {
+		Repository struct {
+			Labels []struct{ Name graphql.String }
+			Issues struct {
+				Nodes    []issue
+				PageInfo struct {
+					EndCursor   graphql.String
+					HasNextPage graphql.Boolean
+				}
+			} `graphql:"issues(first: $first, after: $after)"`
+		}
+	}
+	type backward struct {
+		Repository struct {
+			Issues *struct {
+				Edges []struct {
+					Node *issue
+				}
+				PageInfo struct {
+					StartCursor     *graphql.String
+					HasPreviousPage graphql.Boolean
+				}
+			} `graphql:"issues(last: $last, before: $before)"`
+		}
+	}
+	tests := []struct {
+		q    interface{}
+		p    graphql.Pagination
+		want []graphql.Int
+	}{
+		{&forward{}, graphql.Pagination{Path: "repository.issues", Cursor: "after", PageSize: 2}, []graphql.Int{1, 2, 3, 4, 5}},
+		{&forward{}, graphql.Pagination{Path: "repository.issues", Cursor: "after", PageSize: 2, MaxNodes: 3}, []graphql.Int{1, 2, 3}},
+		{&backward{}, graphql.Pagination{Path: "repository.issues", Cursor: "before", PageSize: 2, Backward: true}, []graphql.Int{4, 5, 2, 3, 1}},
+	}
+	for i, tc := range tests {
+		var got []graphql.Int
+		client.Paginate(context.Background(), tc.q, nil, tc.p)(func(node interface{}, err error) bool {
+			if err != nil {
+				t.Fatalf("test case %d: %v", i, err)
+			}
+			switch node := node.(type) {
+			case issue:
+				got = append(got, node.Number)
+			case *issue:
+				got = append(got, node.Number)
+			}
+			return true
+		})
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("test case %d: got issues %v, want %v", i, got, tc.want)
+		}
+	}
+
+	// The page size has the type of the value of its variable, if any.
+	queries = nil
+	got := 0
+	variables := map[string]interface{}{"first": pageSize(0)}
+	client.Paginate(context.Background(), &forward{}, variables, graphql.Pagination{Path: "repository.issues", Cursor: "after", PageSize: 2})(func(node interface{}, err error) bool {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got++
+		return true
+	})
+	if got != 5 || len(queries) != 3 {
+		t.Errorf("got %d issues in %d queries, want 5 in 3", got, len(queries))
+	}
+	for _, query := range queries {
+		if !strings.Contains(query, "$first:PageSize!") {
+			t.Errorf("got query %q, want the page size of type PageSize!", query)
+		}
+	}
+
+	client.Paginate(context.Background(), &forward{}, nil, graphql.Pagination{Path: "repository.pullRequests", Cursor: "after"})(func(_ interface{}, err error) bool {
+		if err == nil || !strings.Contains(err.Error(), `no field for the response key "pullRequests"`) {
+			t.Errorf("got error %v, want one for the path", err)
+		}
+		return true
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var issues []graphql.Int
+	client.Paginate(ctx, &forward{}, nil, graphql.Pagination{Path: "repository.issues", Cursor: "after", PageSize: 2})(func(node interface{}, err error) bool {
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("got error %v, want context.Canceled", err)
+			}
+			return false
+		}
+		issues = append(issues, node.(issue).Number)
+		cancel()
+		return true
+	})
+	if want := []graphql.Int{1, 2}; !reflect.DeepEqual(issues, want) {
+		t.Errorf("got issues %v, want the first page %v", issues, want)
+	}
+}
+
+// pageSize is an Int of a custom GraphQL type.
+type pageSize int
+
+func (pageSize) GetGraphQLType() string { return "PageSize" }
+
+func TestClient_WithCache(t *testing.T) {
+	// The server has a repository whose stars the addStar mutation increments.
+	var (
+		mu       sync.Mutex
+		stars    = 10
+		requests int
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var in struct{ Query string }
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(in.Query, "mutation") {
+			if want := `mutation($id:ID!){addStar(starrableId:$id){starrable{id stars __typename}__typename}}`; in.Query != want {
+				t.Errorf("got query %q, want %q", in.Query, want)
+			}
+			stars++
+			mustWrite(w, fmt.Sprintf(`{"data": {"addStar": {"__typename": "AddStarPayload", "starrable": {"__typename": "Repository", "id": "R1", "stars": %d}}}}`, stars))
+			return
+		}
+		if want := `query($name:String!){repository(name:$name){id name stars __typename}}`; in.Query != want {
+			t.Errorf("got query %q, want %q", in.Query, want)
+		}
+		mustWrite(w, fmt.Sprintf(`{"data": {"repository": {"__typename": "Repository", "id": "R1", "name": "go-graphql-client", "stars": %d}}}`, stars))
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).WithCache(graphql.NewCache())
+	ctx := context.Background()
+	requested := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+
+	type repository struct {
+		Repository struct {
+			ID    graphql.ID
+			Name  graphql.String
+			Stars graphql.Int
+		} `graphql:"repository(name: $name)"`
+	}
+	query := func(variables map[string]interface{}, options ...graphql.Option) repository {
+		t.Helper()
+		var q repository
+		if _, err := client.Query(ctx, &q, variables, options...); err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+	variables := map[string]interface{}{"name": graphql.String("go-graphql-client")}
+
+	if q := query(variables); q.Repository.Stars != 10 || requested() != 1 {
+		t.Errorf("got %+v after %d requests, want 10 stars after 1", q.Repository, requested())
+	}
+	// The same query, and a query of some of its fields, are answered from
+	// the cache.
+	if q := query(variables); q.Repository.Name != "go-graphql-client" || q.Repository.Stars != 10 || requested() != 1 {
+		t.Errorf("got %+v after %d requests, want 10 stars after 1", q.Repository, requested())
+	}
+	var names struct {
+		Repo struct {
+			Name graphql.String
+		} `graphql:"repo: repository(name: $name)"`
+	}
+	if _, err := client.Query(ctx, &names, variables); err != nil {
+		t.Fatal(err)
+	}
+	if names.Repo.Name != "go-graphql-client" || requested() != 1 {
+		t.Errorf("got %+v after %d requests, want the name after 1", names.Repo, requested())
+	}
+	if query(variables, graphql.NetworkOnly); requested() != 2 {
+		t.Errorf("got %d requests, want 2 with the network-only policy", requested())
+	}
+
+	// The mutation updates the cached repository.
+	var m struct {
+		AddStar struct {
+			Starrable struct {
+				ID    graphql.ID
+				Stars graphql.Int
+			}
+		} `graphql:"addStar(starrableId: $id)"`
+	}
+	if _, err := client.Mutate(ctx, &m, map[string]interface{}{"id": graphql.ID("R1")}); err != nil {
+		t.Fatal(err)
+	}
+	if m.AddStar.Starrable.Stars != 11 || requested() != 3 {
+		t.Errorf("got %+v after %d requests, want 11 stars after 3", m.AddStar.Starrable, requested())
+	}
+	if q := query(variables); q.Repository.Stars != 11 || requested() != 3 {
+		t.Errorf("got %+v after %d requests, want 11 stars after 3", q.Repository, requested())
+	}
+
+	// The other arguments miss the cache.
+	if query(map[string]interface{}{"name": graphql.String("other")}); requested() != 4 {
+		t.Errorf("got %d requests, want 4 for other arguments", requested())
+	}
+
+	// The cache-and-network policy answers from the cache, and refreshes it.
+	mu.Lock()
+	stars = 20
+	mu.Unlock()
+	if q := query(variables, graphql.CacheAndNetwork); q.Repository.Stars != 11 {
+		t.Errorf("got %+v, want 11 stars from the cache", q.Repository)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for query(variables).Repository.Stars != 20 {
+		if time.Now().After(deadline) {
+			t.Fatal("the cache wasn't refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if requested() != 5 {
+		t.Errorf("got %d requests, want 5", requested())
+	}
+}
+
+func TestClient_WithCache_requestModifier(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var in struct{ Query string }
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Error(err)
+		}
+		requests++
+		login := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		typename := ""
+		if strings.Contains(in.Query, "__typename") {
+			typename = `"__typename": "User", `
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {`+typename+`"id": "`+login+`", "login": "`+login+`"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).WithCache(graphql.NewCache())
+	query := func(client *graphql.Client, want string) {
+		t.Helper()
+		var q struct {
+			Viewer struct {
+				ID    graphql.ID
+				Login graphql.String
+			}
+		}
+		if _, err := client.Query(context.Background(), &q, nil); err != nil {
+			t.Fatal(err)
+		}
+		if q.Viewer.Login != graphql.String(want) {
+			t.Errorf("got login %q, want %q", q.Viewer.Login, want)
+		}
+	}
+	query(client, "")
+	query(client, "")
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+
+	// The viewer of the client isn't the one of another user.
+	b := client.WithRequestModifier(func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer b")
+	})
+	query(b, "b")
+	query(b, "b")
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+}
+
+func TestClient_WithResponseCache(t *testing.T) {
+	// The viewer is fresh for a minute, the repository is revalidated each
+	// time, and the rate limit isn't stored.
+	var requests, notModified int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		body := mustRead(req.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(body, "viewer"):
+			w.Header().Set("Cache-Control", "private, max-age=60")
+			mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+		case strings.Contains(body, "repository"):
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if req.Header.Get("If-None-Match") == `"v1"` {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			mustWrite(w, `{"data": {"repository": {"name": "go-graphql-client"}}}`)
+		case strings.Contains(body, "mutation"):
+			w.Header().Set("Cache-Control", "max-age=60")
+			mustWrite(w, `{"data": {"addStar": {"clientMutationId": "1"}}}`)
+		default:
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+			mustWrite(w, `{"data": {"rateLimit": {"remaining": 4999}}}`)
+		}
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithResponseCache(graphql.NewLRUResponseStore(10))
+	ctx := context.Background()
+
+	var viewer struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Query(ctx, &viewer, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || viewer.Viewer.Login != "gopher" {
+			t.Errorf("got status %v and %+v, want 200 and the login", resp.StatusCode, viewer)
+		}
+	}
+	// The same query, formatted differently, is answered from the cache too.
+	if _, err := client.ExecRaw(ctx, "query {\n  viewer {\n    login\n  }\n}", nil); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1 for the fresh viewer", requests)
+	}
+
+	var repository struct {
+		Repository struct {
+			Name graphql.String
+		} `graphql:"repository(name: $name)"`
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.Query(ctx, &repository, map[string]interface{}{"name": graphql.String("go-graphql-client")}); err != nil {
+			t.Fatal(err)
+		}
+		if repository.Repository.Name != "go-graphql-client" {
+			t.Errorf("got %+v, want the name", repository)
+		}
+	}
+	if requests != 4 || notModified != 2 {
+		t.Errorf("got %d requests and %d not modified, want 4 and 2 for the revalidated repository", requests, notModified)
+	}
+
+	var rateLimit struct {
+		RateLimit struct {
+			Remaining graphql.Int
+		}
+	}
+	var m struct {
+		AddStar struct {
+			ClientMutationID graphql.String
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Query(ctx, &rateLimit, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Mutate(ctx, &m, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 8 {
+		t.Errorf("got %d requests, want 8 for the rate limit and the mutation that aren't stored", requests)
+	}
+}
+
+func TestClient_WithResponseCache_endpoints(t *testing.T) {
+	mux := http.NewServeMux()
+	for _, login := range []string{"a", "b"} {
+		login := login
+		mux.HandleFunc("/"+login, func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "max-age=60")
+			mustWrite(w, `{"data": {"viewer": {"login": "`+login+`"}}}`)
+		})
+	}
+	// Clients of different endpoints share the store, but not their responses.
+	store := graphql.NewLRUResponseStore(10)
+	httpClient := &http.Client{Transport: localRoundTripper{handler: mux}}
+	for _, login := range []string{"a", "b", "a"} {
+		var q struct {
+			Viewer struct {
+				Login graphql.String
+			}
+		}
+		client := graphql.NewClient("/"+login, httpClient).WithResponseCache(store)
+		if _, err := client.Query(context.Background(), &q, nil); err != nil {
+			t.Fatal(err)
+		}
+		if q.Viewer.Login != graphql.String(login) {
+			t.Errorf("got login %q from /%s", q.Viewer.Login, login)
+		}
+	}
+}
+
+func TestClient_WithResponseCache_requestModifier(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		login := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		mustWrite(w, `{"data": {"viewer": {"login": "`+login+`"}}}`)
+	})
+	store := graphql.NewLRUResponseStore(10)
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).WithResponseCache(store)
+	authorize := func(token string) graphql.RequestModifier {
+		return func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	query := func(client *graphql.Client, want string) {
+		t.Helper()
+		var q struct {
+			Viewer struct {
+				Login graphql.String
+			}
+		}
+		if _, err := client.Query(context.Background(), &q, nil); err != nil {
+			t.Fatal(err)
+		}
+		if q.Viewer.Login != graphql.String(want) {
+			t.Errorf("got login %q, want %q", q.Viewer.Login, want)
+		}
+	}
+
+	// Clients with different request headers share the store, but not their
+	// responses.
+	a := client.WithRequestModifier(authorize("a")).WithResponseCache(store)
+	b := client.WithRequestModifier(authorize("b")).WithResponseCache(store)
+	for _, c := range []struct {
+		client *graphql.Client
+		login  string
+	}{{a, "a"}, {b, "b"}, {a, "a"}, {b, "b"}} {
+		query(c.client, c.login)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+
+	// The client of a request modifier has no response cache unless set.
+	uncached := client.WithRequestModifier(authorize("a"))
+	query(uncached, "a")
+	query(uncached, "a")
+	if requests != 4 {
+		t.Errorf("got %d requests, want 4", requests)
+	}
+}
+
+func TestNewLRUResponseStore(t *testing.T) {
+	store := graphql.NewLRUResponseStore(2)
+	store.Set("a", &graphql.CachedResponse{Body: []byte("a")})
+	store.Set("b", &graphql.CachedResponse{Body: []byte("b")})
+	store.Get("a")
+	// b is the least recently used.
+	store.Set("c", &graphql.CachedResponse{Body: []byte("c")})
+	if _, ok := store.Get("b"); ok {
+		t.Error("got b, want it dropped")
+	}
+	for _, key := range []string{"a", "c"} {
+		if r, ok := store.Get(key); !ok || string(r.Body) != key {
+			t.Errorf("got %v, %v for %s, want it stored", r, ok, key)
+		}
+	}
+	store.Delete("a")
+	if _, ok := store.Get("a"); ok {
+		t.Error("got a after deletion")
+	}
+}
+
+func TestClient_WithDeduplication(t *testing.T) {
+	const n = 10
+	var (
+		mu        sync.Mutex
+		requests  int
+		mutations = make(chan struct{}, 2)
+		client    *graphql.Client
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(mustRead(req.Body), "mutation") {
+			// Both mutations must be in flight.
+			mutations <- struct{}{}
+			for len(mutations) < 2 {
+				time.Sleep(time.Millisecond)
+			}
+			mustWrite(w, `{"data": {"addStar": {"clientMutationId": "1"}}}`)
+			return
+		}
+		// Respond once all the queries wait for this one.
+		for graphql.InflightCallers(client) < n {
+			time.Sleep(time.Millisecond)
+		}
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher", "repositories": [{"name": "a"}, {"name": "b"}]}}}`)
+	})
+	client = graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithRequestModifier(func(req *http.Request) {
+			req.Header.Set("Authorization", "bearer token")
+		}).
+		WithDeduplication(true)
+	ctx := context.Background()
+
+	type query struct {
+		Viewer struct {
+			Login        graphql.String
+			Repositories []struct{ Name graphql.String }
+		}
+	}
+	queries := make([]query, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range queries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = client.Query(ctx, &queries[i], nil)
+		}(i)
+	}
+	wg.Wait()
+	for i, q := range queries {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if q.Viewer.Login != "gopher" || len(q.Viewer.Repositories) != 2 || q.Viewer.Repositories[1].Name != "b" {
+			t.Errorf("got query %d %+v, want the viewer", i, q)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1 for %d identical queries", requests, n)
+	}
+
+	var m [2]struct {
+		AddStar struct {
+			ClientMutationID graphql.String
+		}
+	}
+	for i := range m {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = client.Mutate(ctx, &m[i], nil)
+		}(i)
+	}
+	wg.Wait()
+	for i := range m {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if m[i].AddStar.ClientMutationID != "1" {
+			t.Errorf("got mutation %d %+v", i, m[i])
+		}
+	}
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3 with the mutations that aren't deduplicated", requests)
+	}
+}
+
+func TestClient_WithDeduplication_canceled(t *testing.T) {
+	sent := make(chan struct{})
+	joined := make(chan struct{})
+	var client *graphql.Client
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		// Respond once the caller that sent the query gave up, unless the
+		// request was canceled with it.
+		close(sent)
+		for graphql.InflightCallers(client) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		close(joined)
+		for graphql.InflightCallers(client) > 1 {
+			time.Sleep(time.Millisecond)
+		}
+		if err := req.Context().Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client = graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithDeduplication(true)
+
+	type query struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var first error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var q query
+		_, first = client.Query(ctx, &q, nil)
+	}()
+	go func() {
+		<-joined
+		cancel()
+	}()
+	// The second query joins the first one, and gets its response.
+	<-sent
+	var q query
+	if _, err := client.Query(context.Background(), &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if q.Viewer.Login != "gopher" {
+		t.Errorf("got %+v, want the viewer", q)
+	}
+	if <-done; first == nil {
+		t.Error("got no error for the canceled query")
+	}
+}
+
+func TestClient_WithDeduplication_allCanceled(t *testing.T) {
+	sent := make(chan struct{})
+	canceled := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		// Respond once the request is canceled.
+		close(sent)
+		<-req.Context().Done()
+		close(canceled)
+		http.Error(w, req.Context().Err().Error(), http.StatusServiceUnavailable)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithDeduplication(true)
+
+	var q struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := client.Query(ctx, &q, nil)
+		errc <- err
+	}()
+	// The request is canceled once the only query waiting for it gives up.
+	<-sent
+	cancel()
+	if err := <-errc; err == nil {
+		t.Error("got no error for the canceled query")
+	}
+	select {
+	case <-canceled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the request wasn't canceled")
+	}
+	if callers := graphql.InflightCallers(client); callers != 0 {
+		t.Errorf("got %d callers in flight, want 0", callers)
+	}
+}
+
+func TestClient_Introspect(t *testing.T) {
+	schema, err := graphqlserver.ParseSchema(starwars.Schema, &starwars.Resolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/query", &relay.Handler{Schema: schema})
+	client := graphql.NewClient("/query", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	s, err := client.Introspect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.QueryType.Name, "Query"; got != want {
+		t.Errorf("got query type %q, want %q", got, want)
+	}
+	droid := s.Type("Droid")
+	if droid == nil {
+		t.Fatal("type Droid not found")
+	}
+	if got, want := droid.Field("friendsConnection").Args[0].Name, "first"; got != want {
+		t.Errorf("got argument %q, want %q", got, want)
+	}
+	if got, want := droid.Field("appearsIn").Type.String(), "[Episode!]!"; got != want {
+		t.Errorf("got type %q, want %q", got, want)
+	}
+
+	// The SDL of the schema can be parsed back.
+	if _, err := graphqlserver.ParseSchema(s.SDL(), nil); err != nil {
+		t.Errorf("invalid SDL: %v\n%s", err, s.SDL())
+	}
+}
+
+func TestSchema_SDL_escapes(t *testing.T) {
+	var s introspection.Schema
+	if err := json.Unmarshal([]byte(`{
+		"queryType": {"name": "Query"},
+		"types": [{
+			"kind": "OBJECT",
+			"name": "Query",
+			"description": "a \"quoted\" \\ name\u0000\u0007",
+			"fields": [{
+				"name": "a",
+				"description": "first line\nsecond\u0001line",
+				"type": {"kind": "SCALAR", "name": "String"},
+				"isDeprecated": true,
+				"deprecationReason": "use b\u007f\b"
+			}, {
+				"name": "b",
+				"description": "first line\n\tsecond line",
+				"type": {"kind": "SCALAR", "name": "String"}
+			}]
+		}]
+	}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	sdl := s.SDL()
+	for _, want := range []string{
+		`"a \"quoted\" \\ name\u0000\u0007"`,
+		`"first line\nsecond\u0001line"`,
+		`@deprecated(reason: "use b\u007f\b")`,
+		"  \"\"\"\n  first line\n  \tsecond line\n  \"\"\"\n",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL doesn't contain %q:\n%s", want, sdl)
+		}
+	}
+	if _, err := graphqlserver.ParseSchema(sdl, nil); err != nil {
+		t.Errorf("invalid SDL: %v\n%s", err, sdl)
+	}
+}
+
 // localRoundTripper is an http.RoundTripper that executes HTTP transactions
 // by using handler directly, instead of going over an HTTP connection.
 type localRoundTripper struct {
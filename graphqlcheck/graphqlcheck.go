@@ -0,0 +1,631 @@
+// Package graphqlcheck defines an Analyzer that checks the query structs
+// passed to graphql.Client, graphql.SubscriptionClient and the
+// graphql.Construct functions for mistakes that otherwise only show up at
+// run time, as a panic while building the query, an error from the server
+// or a field that is silently left empty:
+//
+//   - malformed graphql struct tags;
+//   - fields that the decoder doesn't match to their response key, such as
+//     two fields sharing a response key without an alias;
+//   - map fields, which can't be turned into a selection set;
+//   - misused ordered maps ([][2]interface{});
+//   - unexported fields, which are queried but never decoded;
+//   - recursive types and conflicting named fragments.
+//
+// Given the -schema flag, it also validates the selections of each query
+// struct against a schema in SDL or introspection JSON, see package schema.
+//
+// The analyzer is run by the graphqlcheck command, which can be used on its
+// own or as a vet tool:
+//
+//	go vet -vettool=$(which graphqlcheck) ./...
+package graphqlcheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/phoban01/go-graphql-client/ident"
+	"github.com/phoban01/go-graphql-client/internal/language"
+	"github.com/phoban01/go-graphql-client/schema"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const doc = `check graphql query structs for mistakes
+
+The graphqlcheck analyzer reports malformed graphql struct tags, fields
+that can't be decoded, map fields, misused [][2]interface{} ordered maps,
+unexported fields, recursive types and conflicting named fragments in the
+structs passed to the graphql client. Given -schema, it also validates
+their selections against the schema.`
+
+// Analyzer reports mistakes in the query structs passed to the graphql
+// client. See the package documentation for the list of checks.
+var Analyzer = &analysis.Analyzer{
+	Name:     "graphqlcheck",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+var schemaFile string
+
+func init() {
+	Analyzer.Flags.StringVar(&schemaFile, "schema", "", "validate query structs against the schema in `file`, in SDL or introspection JSON")
+}
+
+const graphqlPath = "github.com/phoban01/go-graphql-client"
+
+// operation describes a function or method that builds a query from a
+// query struct.
+type operation struct {
+	// arg is the index of the query struct argument.
+	arg int
+	// operation is the operation type the query is built as.
+	operation string
+	// decode reports whether the response is decoded into the query struct.
+	decode bool
+}
+
+// operations are the functions and methods that take a query struct, by
+// qualified name.
+var operations = map[string]operation{
+	"ConstructQuery":                    {0, "query", false},
+	"ConstructMutation":                 {0, "mutation", false},
+	"ConstructSubscription":             {0, "subscription", false},
+	"Client.Query":                      {1, "query", true},
+	"Client.NamedQuery":                 {2, "query", true},
+	"Client.QueryRaw":                   {1, "query", false},
+	"Client.NamedQueryRaw":              {2, "query", false},
+	"Client.Mutate":                     {1, "mutation", true},
+	"Client.NamedMutate":                {2, "mutation", true},
+	"Client.MutateRaw":                  {1, "mutation", false},
+	"Client.NamedMutateRaw":             {2, "mutation", false},
+	"SubscriptionClient.Subscribe":      {0, "subscription", false},
+	"SubscriptionClient.NamedSubscribe": {1, "subscription", false},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	var s *schema.Schema
+	if schemaFile != "" {
+		var err error
+		if s, err = loadSchema(schemaFile); err != nil {
+			return nil, err
+		}
+	}
+	files := make(map[*token.File]bool)
+	for _, f := range pass.Files {
+		files[pass.Fset.File(f.Pos())] = true
+	}
+	reported := make(map[string]bool)
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		op, ok := callOperation(pass, call)
+		if !ok || op.arg >= len(call.Args) {
+			return
+		}
+		arg := call.Args[op.arg]
+		t := pass.TypesInfo.TypeOf(arg)
+		if t == nil || types.IsInterface(t) {
+			// The query struct isn't known statically.
+			return
+		}
+		c := &checker{
+			pass:          pass,
+			files:         files,
+			reported:      reported,
+			arg:           arg,
+			fragments:     make(map[string]types.Type),
+			fragmentNames: make(map[types.Type]string),
+			expanding:     make(map[types.Type]bool),
+			fields:        make(map[string]*field),
+		}
+		c.check(t, op)
+		if s != nil && !c.dynamic {
+			c.validate(s)
+		}
+	})
+	return nil, nil
+}
+
+var schemas sync.Map // file name -> *schemaResult
+
+type schemaResult struct {
+	once   sync.Once
+	schema *schema.Schema
+	err    error
+}
+
+// loadSchema loads the named schema file once per process, since the
+// analyzer is run for each package.
+func loadSchema(name string) (*schema.Schema, error) {
+	v, _ := schemas.LoadOrStore(name, new(schemaResult))
+	r := v.(*schemaResult)
+	r.once.Do(func() {
+		r.schema, r.err = schema.LoadFile(name)
+	})
+	return r.schema, r.err
+}
+
+// callOperation returns the operation built by call, if it calls one of
+// the operations of the graphql package.
+func callOperation(pass *analysis.Pass, call *ast.CallExpr) (operation, bool) {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != graphqlPath {
+		return operation{}, false
+	}
+	name := fn.Name()
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		named, ok := deref(recv.Type()).(*types.Named)
+		if !ok {
+			return operation{}, false
+		}
+		name = named.Obj().Name() + "." + name
+	}
+	op, ok := operations[name]
+	return op, ok
+}
+
+// checker checks the query struct passed to a single call.
+type checker struct {
+	pass     *analysis.Pass
+	files    map[*token.File]bool
+	reported map[string]bool
+	// arg is the query struct argument of the call.
+	arg ast.Expr
+
+	// fragments are the named fragment types spread so far, by name.
+	fragments map[string]types.Type
+	// fragmentNames are the names of the named fragment types spread so far.
+	fragmentNames map[types.Type]string
+	// expanding are the struct types being written, to detect recursion.
+	expanding map[types.Type]bool
+	// fragmentDefs are the definitions of the named fragments.
+	fragmentDefs strings.Builder
+	// document is the GraphQL document built from the query struct.
+	document string
+	// dynamic reports whether the query depends on values only known at
+	// run time, such as dynamic selections or ordered maps.
+	dynamic bool
+	// fields are the Go fields that the selections were built from, by
+	// response path.
+	fields map[string]*field
+}
+
+// field is a struct field on the way to the type being written.
+type field struct {
+	v *types.Var
+	// goPath is the Go path of the field within the query struct.
+	goPath string
+}
+
+// reportf reports a problem with f, or with the query struct itself if f
+// is nil. Problems with fields declared outside of the package being
+// analyzed are reported on the query struct argument, with the Go path of
+// the field.
+func (c *checker) reportf(f *field, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	pos := c.arg.Pos()
+	if f != nil {
+		if c.files[c.pass.Fset.File(f.v.Pos())] {
+			pos = f.v.Pos()
+		} else {
+			msg = f.goPath + ": " + msg
+		}
+	}
+	key := c.pass.Fset.Position(pos).String() + ": " + msg
+	if c.reported[key] {
+		// The same struct type may be passed to several calls.
+		return
+	}
+	c.reported[key] = true
+	c.pass.Reportf(pos, "%s", msg)
+}
+
+// check checks the query struct of type t passed to op, and builds the
+// document it's rendered as.
+func (c *checker) check(t types.Type, op operation) {
+	if _, ok := t.Underlying().(*types.Pointer); !ok && op.decode {
+		c.reportf(nil, "query struct of type %s isn't a pointer, so the response can't be decoded into it", t)
+	}
+	var w strings.Builder
+	w.WriteString(op.operation)
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		// A pointer passed to the client points to a value.
+		t = ptr.Elem()
+	}
+	c.writeQuery(&w, t, nil, nil, false, true)
+	w.WriteString(c.fragmentDefs.String())
+	c.document = w.String()
+}
+
+// writeQuery writes the selections built from t to w, following the rules
+// of graphql.ConstructQuery, and reports the problems found along the way.
+// f is the field of type t, or nil for the query struct itself, and path is
+// its response path. If inline is true, the fields of t are inlined into
+// the parent selection set. known reports whether the query is built from
+// a value of type t, rather than from the type alone, as for the elements
+// of a slice.
+func (c *checker) writeQuery(w *strings.Builder, t types.Type, f *field, path []string, inline, known bool) {
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		c.writeQuery(w, u.Elem(), f, path, false, false)
+	case *types.Struct:
+		if isGraphQLType(t, "Selection") {
+			c.dynamic = true
+			return
+		}
+		if isOptional(t) {
+			// Optional is selected as its value.
+			c.writeQuery(w, u.Field(0).Type(), f, path, inline, known)
+			return
+		}
+		// If the type implements json.Unmarshaler, it's a scalar. Don't expand it.
+		if types.Implements(types.NewPointer(t), jsonUnmarshaler) {
+			return
+		}
+		if isNamedFragment(t) {
+			name := c.spreadFragment(t, u, f, path, known)
+			if !inline {
+				w.WriteString("{")
+			}
+			w.WriteString("...")
+			w.WriteString(name)
+			if !inline {
+				w.WriteString("}")
+			}
+			return
+		}
+		if c.expanding[t] {
+			c.reportf(f, "recursive type %s makes the query infinitely deep", t)
+			return
+		}
+		c.expanding[t] = true
+		defer delete(c.expanding, t)
+		if !inline {
+			w.WriteString("{")
+		}
+		c.writeStructFields(w, u, f, path, known)
+		if !inline {
+			w.WriteString("}")
+		}
+	case *types.Slice:
+		pair, ok := u.Elem().Underlying().(*types.Array)
+		if !ok {
+			c.writeQuery(w, u.Elem(), f, path, false, false)
+			return
+		}
+		// The selections of an ordered map are only known at run time.
+		c.dynamic = true
+		switch {
+		case pair.Len() != 2:
+			c.reportf(f, "ordered map %s must hold pairs, got arrays of length %d", t, pair.Len())
+		case !types.IsInterface(pair.Elem()) && !isString(pair.Elem()):
+			c.reportf(f, "ordered map %s must have string keys, use [][2]interface{} instead", t)
+		case !known:
+			c.reportf(f, "ordered map %s within a slice or pointer has no value to build the query from", t)
+		}
+	case *types.Map:
+		c.reportf(f, "type %s is not supported, use [][2]interface{} or a struct instead", t)
+	}
+}
+
+// writeStructFields writes the selections built from the fields of struct
+// st to w, without the surrounding braces.
+func (c *checker) writeStructFields(w *strings.Builder, st *types.Struct, parent *field, path []string, known bool) {
+	// keys are the fields of st by response key, to detect fields that
+	// are never decoded because another field has the same key.
+	keys := make(map[string]*types.Var)
+	iter := 0
+	for i := 0; i < st.NumFields(); i++ {
+		v := st.Field(i)
+		tags := reflect.StructTag(st.Tag(i))
+		tag, tagged := tags.Lookup("graphql")
+		// Skip this field if the tag value is hyphen
+		if tag == "-" {
+			continue
+		}
+		if iter != 0 {
+			w.WriteString(",")
+		}
+		iter++
+
+		f := &field{v: v, goPath: v.Name()}
+		if parent != nil {
+			f.goPath = parent.goPath + "." + v.Name()
+		}
+		fieldPath := path
+		inline := v.Anonymous() && !tagged
+		// ownSelections reports whether the selection set of the field comes
+		// from its tag, as for maps.
+		ownSelections := false
+		if inline {
+			if _, ok := v.Type().Underlying().(*types.Pointer); ok {
+				c.reportf(f, "embedded pointer field %s is queried as a selection set without a field name, embed %s or add a graphql tag instead", v.Name(), deref(v.Type()))
+			}
+		} else {
+			key := tag
+			if !tagged {
+				key = ident.ParseMixedCaps(v.Name()).ToLowerCamelCase()
+			}
+			w.WriteString(key)
+			switch sel := c.parseKey(f, key, tagged, isMap(v.Type())).(type) {
+			case *language.Field:
+				fieldPath = appendPath(path, sel.ResponseKey())
+				ownSelections = sel.SelectionSet != nil
+				c.checkDecoded(f, tag, tagged, sel.ResponseKey(), keys)
+			case *language.InlineFragment:
+				fieldPath = appendPath(path, "... on "+sel.TypeCondition)
+			case *language.FragmentSpread:
+				c.reportf(f, "graphql tag %q spreads a fragment, which can't have a selection set, use a type that implements graphql.NamedFragment instead", tag)
+			}
+			if _, ok := c.fields[strings.Join(fieldPath, ".")]; !ok {
+				c.fields[strings.Join(fieldPath, ".")] = f
+			}
+		}
+		// Skip writeQuery if the GraphQL type associated with the filed is scalar
+		if isTrue(tags.Get("scalar")) || ownSelections {
+			continue
+		}
+		c.writeQuery(w, v.Type(), f, fieldPath, inline, known)
+	}
+}
+
+// parseKey parses key, the tag or name a field is queried as, into a
+// selection, or returns nil if it isn't a single valid selection. Only the
+// tags of map fields, whose values are decoded from the response keys of the
+// selection set, may have a field with a selection set, as reported by
+// mapField.
+func (c *checker) parseKey(f *field, key string, tagged, mapField bool) language.Selection {
+	doc, err := language.Parse("{" + key + "}")
+	// placeholder reports whether key was parsed with a placeholder
+	// selection set, as inline fragments can't be parsed without one.
+	placeholder := false
+	if err != nil && strings.HasPrefix(strings.TrimSpace(key), "...") {
+		if fragmentDoc, fragmentErr := language.Parse("{" + key + "{__typename}}"); fragmentErr == nil {
+			doc, err, placeholder = fragmentDoc, nil, true
+		}
+	}
+	if err != nil {
+		msg := err.Error()
+		if serr, ok := err.(*language.SyntaxError); ok {
+			msg = serr.Message
+		}
+		if !tagged {
+			c.reportf(f, "field %s is queried as %q, which isn't a valid GraphQL name: %s", f.v.Name(), key, msg)
+		} else {
+			c.reportf(f, "malformed graphql tag %q: %s", key, msg)
+		}
+		return nil
+	}
+	selections := doc.Operations[0].SelectionSet
+	if len(selections) != 1 {
+		c.reportf(f, "graphql tag %q must select a single field or fragment, got %d selections", key, len(selections))
+		return nil
+	}
+	// The selection set must come from the field type.
+	var ownSelections bool
+	switch sel := selections[0].(type) {
+	case *language.Field:
+		ownSelections = sel.SelectionSet != nil && !mapField
+	case *language.InlineFragment:
+		ownSelections = !placeholder
+	}
+	if ownSelections {
+		c.reportf(f, "graphql tag %q has a selection set, which must come from the type of field %s instead", key, f.v.Name())
+		return nil
+	}
+	return selections[0]
+}
+
+// checkDecoded reports field f, queried as the response key responseKey, if
+// the decoder won't fill it in. keys are the fields of the same struct
+// seen so far, by response key.
+func (c *checker) checkDecoded(f *field, tag string, tagged bool, responseKey string, keys map[string]*types.Var) {
+	if !f.v.Exported() && !f.v.Anonymous() {
+		// The decoder skips unexported fields, except embedded structs.
+		c.reportf(f, "unexported field %s is queried as %q but never decoded, export it or add a graphql:\"-\" tag", f.v.Name(), responseKey)
+		return
+	}
+	if tagged && decoderName(tag) != responseKey {
+		c.reportf(f, "field %s is decoded from the key %q instead of its response key %q", f.v.Name(), decoderName(tag), responseKey)
+		return
+	}
+	if prev, ok := keys[responseKey]; ok {
+		c.reportf(f, "field %s has the same response key %q as field %s, so it's never decoded, add an alias to its graphql tag", f.v.Name(), responseKey, prev.Name())
+		return
+	}
+	keys[responseKey] = f.v
+}
+
+// spreadFragment returns the name of the named fragment t, a struct type
+// of underlying type st spread at f. The fields of a fragment are checked
+// at its first spread.
+func (c *checker) spreadFragment(t types.Type, st *types.Struct, f *field, path []string, known bool) string {
+	if name, ok := c.fragmentNames[t]; ok {
+		return name
+	}
+	name, cond := c.methodString(t, "FragmentName"), c.methodString(t, "TypeCondition")
+	c.fragmentNames[t] = name
+	if name == "" || cond == "" {
+		// The fragment is only known at run time.
+		c.dynamic = true
+	} else if prev, ok := c.fragments[name]; ok && !types.Identical(prev, t) {
+		c.reportf(f, "fragment %q is defined by both %s and %s", name, prev, t)
+	} else {
+		c.fragments[name] = t
+	}
+	var def strings.Builder
+	def.WriteString("fragment ")
+	def.WriteString(name)
+	def.WriteString(" on ")
+	def.WriteString(cond)
+	def.WriteString("{")
+	c.writeStructFields(&def, st, f, path, known)
+	def.WriteString("}")
+	c.fragmentDefs.WriteString(def.String())
+	return name
+}
+
+// methodString returns the string constant returned by the method name of
+// type t, or "" if it isn't known statically, e.g. because t is declared
+// in another package.
+func (c *checker) methodString(t types.Type, name string) string {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), true, nil, name)
+	fn, ok := obj.(*types.Func)
+	if !ok || fn.Pkg() != c.pass.Pkg {
+		return ""
+	}
+	fn = fn.Origin()
+	for _, file := range c.pass.Files {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || c.pass.TypesInfo.Defs[decl.Name] != fn || decl.Body == nil || len(decl.Body.List) != 1 {
+				continue
+			}
+			ret, ok := decl.Body.List[0].(*ast.ReturnStmt)
+			if !ok || len(ret.Results) != 1 {
+				return ""
+			}
+			if v := c.pass.TypesInfo.Types[ret.Results[0]].Value; v != nil && v.Kind() == constant.String {
+				return constant.StringVal(v)
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// validate validates the query against schema s, and reports each error on
+// the field it's about.
+func (c *checker) validate(s *schema.Schema) {
+	errs, ok := s.ValidateSelections(c.document).(schema.Errors)
+	if !ok {
+		// Syntax errors are reported on the offending fields.
+		return
+	}
+	for _, err := range errs {
+		f, exact := c.fieldAt(err.Path)
+		if exact {
+			c.reportf(f, "%s", err.Message)
+		} else {
+			c.reportf(f, "%s", err)
+		}
+	}
+}
+
+// fieldAt returns the Go field that the selection at the response path was
+// built from, or its closest ancestor, and whether it's the field itself.
+func (c *checker) fieldAt(path string) (f *field, exact bool) {
+	for exact = true; path != ""; exact = false {
+		if f, ok := c.fields[path]; ok {
+			return f, exact
+		}
+		i := strings.LastIndex(path, ".")
+		if i == -1 {
+			break
+		}
+		path = path[:i]
+	}
+	return nil, false
+}
+
+// decoderName returns the name the decoder matches a field tagged with tag
+// against, see jsonutil.keyHasGraphQLName.
+func decoderName(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if i := strings.IndexAny(tag, "(@{"); i != -1 {
+		tag = tag[:i]
+	}
+	if i := strings.Index(tag, ":"); i != -1 {
+		tag = tag[:i]
+	}
+	return strings.TrimSpace(tag)
+}
+
+// isNamedFragment reports whether struct type t implements
+// graphql.NamedFragment, ignoring the methods promoted from an embedded
+// fragment, which don't make t a fragment of the same name.
+func isNamedFragment(t types.Type) bool {
+	ptr := types.NewPointer(t)
+	name, index, _ := types.LookupFieldOrMethod(ptr, true, nil, "FragmentName")
+	cond, _, _ := types.LookupFieldOrMethod(ptr, true, nil, "TypeCondition")
+	if _, ok := name.(*types.Func); !ok {
+		return false
+	}
+	if _, ok := cond.(*types.Func); !ok {
+		return false
+	}
+	return len(index) == 1
+}
+
+// isGraphQLType reports whether t is the named type name of the graphql package.
+func isGraphQLType(t types.Type, name string) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == graphqlPath && named.Obj().Name() == name
+}
+
+// isOptional reports whether t is an instance of graphql.Optional.
+func isOptional(t types.Type) bool {
+	return isGraphQLType(types.Unalias(t), "Optional")
+}
+
+func isString(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0
+}
+
+// isMap reports whether t is a map, or a pointer to or a slice of maps.
+func isMap(t types.Type) bool {
+	for {
+		switch u := t.Underlying().(type) {
+		case *types.Pointer:
+			t = u.Elem()
+		case *types.Slice:
+			t = u.Elem()
+		case *types.Map:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+func deref(t types.Type) types.Type {
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
+
+func appendPath(path []string, name string) []string {
+	p := make([]string, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, name)
+}
+
+func isTrue(s string) bool {
+	b, _ := strconv.ParseBool(s)
+	return b
+}
+
+// jsonUnmarshaler is the json.Unmarshaler interface, which the package
+// being analyzed may not import.
+var jsonUnmarshaler = func() *types.Interface {
+	params := types.NewTuple(types.NewVar(token.NoPos, nil, "data", types.NewSlice(types.Typ[types.Byte])))
+	results := types.NewTuple(types.NewVar(token.NoPos, nil, "", types.Universe.Lookup("error").Type()))
+	sig := types.NewSignatureType(nil, nil, nil, params, results, false)
+	return types.NewInterfaceType([]*types.Func{types.NewFunc(token.NoPos, nil, "UnmarshalJSON", sig)}, nil).Complete()
+}()
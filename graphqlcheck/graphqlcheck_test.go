@@ -0,0 +1,22 @@
+package graphqlcheck_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/phoban01/go-graphql-client/graphqlcheck"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), graphqlcheck.Analyzer, "a")
+}
+
+func TestAnalyzer_schema(t *testing.T) {
+	dir := analysistest.TestData()
+	if err := graphqlcheck.Analyzer.Flags.Set("schema", filepath.Join(dir, "schema.graphql")); err != nil {
+		t.Fatal(err)
+	}
+	defer graphqlcheck.Analyzer.Flags.Set("schema", "")
+	analysistest.Run(t, dir, graphqlcheck.Analyzer, "b")
+}
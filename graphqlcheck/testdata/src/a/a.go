@@ -0,0 +1,110 @@
+package a
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	graphql "github.com/phoban01/go-graphql-client"
+)
+
+type UserFields struct {
+	Login graphql.String
+	email graphql.String // want `unexported field email is queried as "email" but never decoded, export it or add a graphql:"-" tag`
+}
+
+func (UserFields) FragmentName() string  { return "UserFields" }
+func (UserFields) TypeCondition() string { return "User" }
+
+type OtherUserFields struct {
+	Name graphql.String
+}
+
+func (OtherUserFields) FragmentName() string  { return "UserFields" }
+func (OtherUserFields) TypeCondition() string { return "User" }
+
+type Node struct {
+	ID     graphql.ID
+	Parent *Node // want `recursive type a.Node makes the query infinitely deep`
+}
+
+type Timestamp struct{ time.Time }
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error { return nil }
+
+func valid(ctx context.Context, client *graphql.Client) {
+	var q struct {
+		Viewer struct {
+			UserFields
+			Name      graphql.String `graphql:"name @include(if: $withName)"`
+			AvatarURL graphql.String `graphql:"avatarUrl(size: 72)"`
+			SmallURL  graphql.String `graphql:"smallUrl: avatarUrl(size: 16)"`
+			CreatedAt Timestamp
+			Settings  json.RawMessage   `scalar:"true"`
+			Ignored   map[string]string `graphql:"-"`
+		}
+		Repository struct {
+			Issues []struct {
+				Title graphql.String
+			} `graphql:"issues(first: 10)"`
+			Droid struct {
+				PrimaryFunction graphql.String
+			} `graphql:"... on Droid"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+		Dynamic graphql.Selection
+		Ordered [][2]interface{}
+	}
+	client.Query(ctx, &q, nil)
+}
+
+func tags(ctx context.Context, client *graphql.Client) {
+	var q struct {
+		Repository struct{ Name graphql.String } `graphql:"repository(owner: $owner"` // want `malformed graphql tag "repository\(owner: \$owner": expected name, got "}"`
+		Two        graphql.String                `graphql:"login name"`               // want `graphql tag "login name" must select a single field or fragment, got 2 selections`
+		Own        struct{ Name graphql.String } `graphql:"hero { name }"`            // want `graphql tag "hero { name }" has a selection set, which must come from the type of field Own instead`
+		Spread     struct{ Name graphql.String } `graphql:"...UserFields"`            // want `graphql tag "...UserFields" spreads a fragment, which can't have a selection set, use a type that implements graphql.NamedFragment instead`
+		Droid      struct{ Name graphql.String } `graphql:"... on Droid { name }"`    // want `graphql tag "... on Droid { name }" has a selection set, which must come from the type of field Droid instead`
+		Comma      graphql.String                `graphql:"login,"`                   // want `field Comma is decoded from the key "login," instead of its response key "login"`
+		*Embedded                                // want `embedded pointer field Embedded is queried as a selection set without a field name, embed a.Embedded or add a graphql tag instead`
+	}
+	client.Query(ctx, q, nil) // want `query struct of type struct{.*} isn't a pointer, so the response can't be decoded into it`
+}
+
+type Embedded struct{ Name graphql.String }
+
+func aliases(ctx context.Context, client *graphql.Client) {
+	var q struct {
+		Small graphql.String `graphql:"avatarUrl(size: 16)"`
+		Large graphql.String `graphql:"avatarUrl(size: 72)"` // want `field Large has the same response key "avatarUrl" as field Small, so it's never decoded, add an alias to its graphql tag`
+		ID    graphql.ID
+		Id    graphql.ID // want `field Id has the same response key "id" as field ID, so it's never decoded, add an alias to its graphql tag`
+	}
+	client.NamedQuery(ctx, "Aliases", &q, nil)
+}
+
+type Labels map[string]graphql.String
+
+func maps(client *graphql.SubscriptionClient) {
+	var s struct {
+		Labels  Labels // want `type a.Labels is not supported, use \[\]\[2\]interface{} or a struct instead`
+		Aliases Labels `graphql:"aliases: labels { first: label(index: 0), second: label(index: 1) }"`
+		Items   []struct {
+			Fields [][2]interface{} // want `ordered map \[\]\[2\]interface{} within a slice or pointer has no value to build the query from`
+		}
+		Triples [][3]interface{} // want `ordered map \[\]\[3\]interface{} must hold pairs, got arrays of length 3`
+		Ints    [][2]int         // want `ordered map \[\]\[2\]int must have string keys, use \[\]\[2\]interface{} instead`
+	}
+	client.Subscribe(&s, nil, nil)
+	graphql.ConstructQuery(map[string]interface{}{}, nil) // want `type map\[string\]interface{} is not supported, use \[\]\[2\]interface{} or a struct instead`
+}
+
+func fragments(ctx context.Context, client *graphql.Client) {
+	var q struct {
+		Viewer UserFields
+		Author OtherUserFields // want `fragment "UserFields" is defined by both a.UserFields and a.OtherUserFields`
+		Node   Node
+	}
+	client.QueryRaw(ctx, &q, nil)
+	var unknown interface{} = &q
+	client.Query(ctx, unknown, nil)
+}
@@ -0,0 +1,72 @@
+package b
+
+import (
+	"context"
+
+	graphql "github.com/phoban01/go-graphql-client"
+)
+
+type UserFields struct {
+	Login graphql.String
+	Email graphql.String // want `field "email" is not defined on type "User"`
+}
+
+func (UserFields) FragmentName() string  { return "UserFields" }
+func (UserFields) TypeCondition() string { return "User" }
+
+type IssueFields struct {
+	Titel graphql.String // want `field "titel" is not defined on type "Issue"`
+}
+
+func queries(ctx context.Context, client *graphql.Client) {
+	var q struct {
+		Viewer     UserFields
+		Repository struct { // want `missing required argument "owner" of type "String!" on field "repository"`
+			Issue IssueFields    `graphql:"issue(number: $number)"`
+			Owner graphql.String // want `field "owner" is not defined on type "Repository"`
+		} `graphql:"repository(name: $name)"`
+		Droid struct{ Name graphql.String } `graphql:"... on Droid"` // want `fragment type condition "Droid" is not defined`
+	}
+	client.Query(ctx, &q, nil)
+
+	var m struct {
+		AddStar struct {
+			Starrable struct {
+				Name graphql.String
+			}
+		} `graphql:"addStar(input: $input)"`
+	}
+	client.Mutate(ctx, &m, nil)
+
+	var optional struct {
+		Viewer struct {
+			Login graphql.Optional[graphql.String]
+			Bio   graphql.Optional[graphql.String] // want `field "bio" is not defined on type "User"`
+		}
+	}
+	client.Query(ctx, &optional, nil)
+
+	var issues struct {
+		Repository map[string]struct { // want `field "body" is not defined on type "Issue"`
+			Title graphql.String
+		} `graphql:"repository(owner: \"golang\", name: \"go\") { first: issue(number: 1) { title }, second: issue(number: 2) { body } }"`
+	}
+	client.Query(ctx, &issues, nil)
+
+	var dynamic struct {
+		Viewer struct {
+			Name graphql.String
+		}
+		Dynamic graphql.Selection
+	}
+	client.Query(ctx, &dynamic, nil)
+}
+
+func subscriptions(client *graphql.SubscriptionClient) {
+	var s struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	client.Subscribe(&s, nil, nil) // want `schema doesn't support subscription operations`
+}
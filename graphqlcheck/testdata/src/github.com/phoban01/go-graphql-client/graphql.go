@@ -0,0 +1,53 @@
+// Package graphql is a stub of the graphql client for the analyzer tests.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+type (
+	String  string
+	Int     int32
+	Boolean bool
+	ID      string
+)
+
+type Option interface{}
+
+type Selection struct{}
+
+type Optional[T any] struct {
+	Value   T
+	Present bool
+	Null    bool
+}
+
+type Client struct{}
+
+func (c *Client) Query(ctx context.Context, q interface{}, variables interface{}, options ...Option) (*http.Response, error) {
+	return nil, nil
+}
+
+func (c *Client) NamedQuery(ctx context.Context, name string, q interface{}, variables interface{}, options ...Option) (*http.Response, error) {
+	return nil, nil
+}
+
+func (c *Client) Mutate(ctx context.Context, m interface{}, variables interface{}, options ...Option) (*http.Response, error) {
+	return nil, nil
+}
+
+func (c *Client) QueryRaw(ctx context.Context, q interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
+	return nil, nil
+}
+
+type SubscriptionClient struct{}
+
+func (sc *SubscriptionClient) Subscribe(v interface{}, variables interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
+	return "", nil
+}
+
+func ConstructQuery(v interface{}, variables interface{}, options ...Option) (string, error) {
+	return "", nil
+}
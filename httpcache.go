@@ -0,0 +1,216 @@
+package graphql
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phoban01/go-graphql-client/internal/language"
+)
+
+// CachedResponse is a response body stored by a ResponseStore, see
+// Client.WithResponseCache.
+type CachedResponse struct {
+	// Body is the response body, decompressed.
+	Body []byte
+	// Header is the header of the response, or of its last revalidation.
+	Header http.Header
+	// Expires is the time the response becomes stale, and has to be
+	// revalidated with its ETag.
+	Expires time.Time
+}
+
+// ResponseStore stores the responses cached by Client.WithResponseCache by
+// key. Implementations must be safe for concurrent use, and may drop entries
+// at any time, e.g. to limit their size. Entries are replaced rather than
+// modified once set.
+type ResponseStore interface {
+	// Get returns the response stored with key, if any.
+	Get(key string) (*CachedResponse, bool)
+	// Set stores the response with key, replacing any previous one.
+	Set(key string, r *CachedResponse)
+	// Delete removes the response stored with key, if any.
+	Delete(key string)
+}
+
+// lruResponseStore is an in-memory ResponseStore that drops the least
+// recently used responses beyond its capacity.
+type lruResponseStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	// order holds the *lruEntry values, most recently used first.
+	order *list.List
+}
+
+type lruEntry struct {
+	key      string
+	response *CachedResponse
+}
+
+// NewLRUResponseStore returns an in-memory ResponseStore that holds at most
+// capacity responses, dropping the least recently used ones. A non-positive
+// capacity means no limit.
+func NewLRUResponseStore(capacity int) ResponseStore {
+	return &lruResponseStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (s *lruResponseStore) Get(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(e)
+	return e.Value.(*lruEntry).response, true
+}
+
+func (s *lruResponseStore) Set(key string, r *CachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.Value.(*lruEntry).response = r
+		s.order.MoveToFront(e)
+		return
+	}
+	s.entries[key] = s.order.PushFront(&lruEntry{key: key, response: r})
+	if s.capacity > 0 && s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (s *lruResponseStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		s.order.Remove(e)
+		delete(s.entries, key)
+	}
+}
+
+// responseCacheKey returns the key of the cached response of query with the
+// variables of payload, sent with request, and reports whether its response
+// may be cached, i.e. query is a valid document of a single query operation.
+// The key depends on the URL and header of request, so that the response of
+// a user isn't served to another one, but not on the formatting of query, nor
+// on the order of its variables and fields.
+func responseCacheKey(request *http.Request, query string, payload map[string]interface{}) (string, bool) {
+	doc, err := language.Parse(query)
+	if err != nil || len(doc.Operations) != 1 || doc.Operations[0].Operation != "query" {
+		return "", false
+	}
+	language.Sort(doc)
+	b, err := json.Marshal(struct {
+		URL           string                 `json:"url"`
+		Header        http.Header            `json:"header,omitempty"`
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName,omitempty"`
+		Variables     map[string]interface{} `json:"variables,omitempty"`
+	}{
+		URL:           request.URL.String(),
+		Header:        request.Header,
+		Query:         language.Print(doc, ""),
+		OperationName: doc.Operations[0].Name,
+		Variables:     payload,
+	})
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// responseExpires returns the time a response with header, received at now,
+// becomes stale, and reports whether it may be stored: it has a max-age or
+// an ETag, and no no-store directive.
+func responseExpires(header http.Header, now time.Time) (time.Time, bool) {
+	var maxAge time.Duration
+	noCache := false
+	for _, directive := range strings.Split(strings.Join(header.Values("Cache-Control"), ","), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return time.Time{}, false
+		case "no-cache":
+			// The response must be revalidated each time.
+			noCache = true
+		case "max-age":
+			if seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64); err == nil {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil {
+		maxAge -= time.Duration(age) * time.Second
+	}
+	if noCache {
+		maxAge = 0
+	}
+	if maxAge <= 0 && header.Get("ETag") == "" {
+		return time.Time{}, false
+	}
+	return now.Add(maxAge), true
+}
+
+// cachedHTTPResponse returns the HTTP response of a response served from the
+// cache for request.
+func cachedHTTPResponse(request *http.Request, cached *CachedResponse) *http.Response {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     cached.Header.Clone(),
+		Body:       http.NoBody,
+		Request:    request,
+	}
+}
+
+// revalidateResponse returns the cached response stored with key, updated
+// by header, the header of the 304 Not Modified response that revalidated
+// it, and stores it again unless the new header forbids it.
+func (c *Client) revalidateResponse(key string, cached *CachedResponse, header http.Header) *CachedResponse {
+	updated := &CachedResponse{Body: cached.Body, Header: cached.Header.Clone()}
+	for k, v := range cachedHeader(header) {
+		updated.Header[k] = v
+	}
+	expires, ok := responseExpires(updated.Header, time.Now())
+	if !ok {
+		c.responseCache.Delete(key)
+		return updated
+	}
+	updated.Expires = expires
+	c.responseCache.Set(key, updated)
+	return updated
+}
+
+// cachedETag returns the ETag of the cached response, if any.
+func cachedETag(cached *CachedResponse) string {
+	if cached == nil {
+		return ""
+	}
+	return cached.Header.Get("ETag")
+}
+
+// cachedHeader returns a copy of the header of a response to cache, without
+// the fields that don't apply to its decompressed body.
+func cachedHeader(header http.Header) http.Header {
+	h := header.Clone()
+	h.Del("Content-Encoding")
+	h.Del("Content-Length")
+	return h
+}
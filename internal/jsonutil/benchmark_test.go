@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -73,6 +74,83 @@ func BenchmarkUnmarshalGraphQL(b *testing.B) {
 	}
 }
 
+// repositoryQuery is a wider query, with a list of nodes of builtin kinds.
+type repositoryQuery struct {
+	Repository struct {
+		Name          graphql.String
+		Description   *graphql.String
+		IsPrivate     graphql.Boolean
+		IsArchived    graphql.Boolean
+		ForkCount     graphql.Int
+		DiskUsage     *graphql.Int
+		StargazerRate graphql.Float
+		Issues        struct {
+			TotalCount graphql.Int
+			Nodes      []struct {
+				Number    graphql.Int
+				Title     graphql.String
+				Closed    graphql.Boolean
+				Locked    graphql.Boolean
+				Comments  struct{ TotalCount graphql.Int }
+				Reactions struct{ TotalCount graphql.Int }
+				Author    *struct{ Login graphql.String }
+			}
+		}
+	}
+}
+
+func repositoryResponse() []byte {
+	var nodes []string
+	for i := 0; i < 20; i++ {
+		nodes = append(nodes, `{
+			"number": `+strconv.Itoa(i)+`,
+			"title": "Issue `+strconv.Itoa(i)+`",
+			"closed": false,
+			"locked": true,
+			"comments": {"totalCount": 3},
+			"reactions": {"totalCount": 12},
+			"author": {"login": "gopher"}
+		}`)
+	}
+	return []byte(`{
+		"repository": {
+			"name": "go-graphql-client",
+			"description": null,
+			"isPrivate": false,
+			"isArchived": false,
+			"forkCount": 42,
+			"diskUsage": 1024,
+			"stargazerRate": 0.75,
+			"issues": {
+				"totalCount": 20,
+				"nodes": [` + strings.Join(nodes, ",") + `]
+			}
+		}
+	}`)
+}
+
+func BenchmarkUnmarshalGraphQL_repository(b *testing.B) {
+	data := repositoryResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var got repositoryQuery
+		if err := jsonutil.UnmarshalGraphQL(data, &got); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONUnmarshal_repository(b *testing.B) {
+	data := repositoryResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var got repositoryQuery
+		if err := json.Unmarshal(data, &got); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkJSONUnmarshal(b *testing.B) {
 	type query struct {
 		Viewer struct {
@@ -0,0 +1,62 @@
+package jsonutil
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Tokenizer reads the JSON tokens and values of a stream, like
+// json.Decoder, which is its default implementation.
+//
+// Token returns the tokens of the types returned by json.Decoder.Token,
+// with numbers as json.Number. Decode decodes the next JSON value into v,
+// which is a *json.RawMessage when decoding GraphQL query data structures.
+type Tokenizer interface {
+	Token() (json.Token, error)
+	Decode(v interface{}) error
+	More() bool
+}
+
+// Codec encodes and decodes JSON, so that JSON libraries other than
+// encoding/json can be used to encode GraphQL requests and to decode their
+// responses. Implementations must behave like encoding/json for the values
+// they're given, and are checked by the tests of this package.
+type Codec interface {
+	// Marshal returns the JSON encoding of v, like json.Marshal.
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes the JSON data into v, like json.Unmarshal.
+	Unmarshal(data []byte, v interface{}) error
+	// NewTokenizer returns a Tokenizer reading from r, with numbers
+	// read as json.Number.
+	NewTokenizer(r io.Reader) Tokenizer
+}
+
+// StdCodec is the Codec of encoding/json, used by default.
+var StdCodec Codec = stdCodec{}
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (stdCodec) NewTokenizer(r io.Reader) Tokenizer {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec
+}
+
+// WithCodec makes decoding use codec to read the JSON input, rather than
+// StdCodec. Values of types that implement json.Unmarshaler or
+// encoding.TextUnmarshaler are still decoded by their own methods.
+func WithCodec(codec Codec) Option {
+	return func(d *decoder) {
+		if codec != nil {
+			d.codec = codec
+		}
+	}
+}
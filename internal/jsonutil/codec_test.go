@@ -0,0 +1,123 @@
+package jsonutil_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/phoban01/go-graphql-client/internal/jsonutil"
+)
+
+// codecs are the codecs that the tests of the package are run with, so that
+// they check that a codec decodes GraphQL query data structures the same way
+// as encoding/json. Codecs wrapping other JSON libraries are checked by
+// adding them here.
+var codecs = []struct {
+	name  string
+	codec jsonutil.Codec
+}{
+	{"std", jsonutil.StdCodec},
+	{"token", tokenCodec{}},
+}
+
+// testCodec is the codec of the current run of the tests.
+var testCodec jsonutil.Codec
+
+func TestMain(m *testing.M) {
+	for _, c := range codecs {
+		testCodec = c.codec
+		if code := m.Run(); code != 0 {
+			fmt.Fprintf(os.Stderr, "tests failed with the %s codec\n", c.name)
+			os.Exit(code)
+		}
+	}
+	os.Exit(0)
+}
+
+// unmarshalGraphQL is jsonutil.UnmarshalGraphQL with testCodec.
+func unmarshalGraphQL(data []byte, v interface{}, opts ...jsonutil.Option) error {
+	return jsonutil.UnmarshalGraphQL(data, v, append(opts, jsonutil.WithCodec(testCodec))...)
+}
+
+// tokenCodec is a Codec whose tokenizers decode values from the tokens of
+// a json.Decoder, rather than with the decoder itself, as a JSON library
+// that only provides a tokenizer would.
+type tokenCodec struct{}
+
+func (tokenCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (tokenCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (tokenCodec) NewTokenizer(r io.Reader) jsonutil.Tokenizer {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &tokenTokenizer{dec: dec}
+}
+
+type tokenTokenizer struct {
+	dec *json.Decoder
+}
+
+func (t *tokenTokenizer) Token() (json.Token, error) {
+	return t.dec.Token()
+}
+
+func (t *tokenTokenizer) More() bool {
+	return t.dec.More()
+}
+
+// Decode encodes the tokens of the next JSON value again, and unmarshals
+// them into v.
+func (t *tokenTokenizer) Decode(v interface{}) error {
+	var buf bytes.Buffer
+	// items holds the number of keys and values written to each open
+	// object or array.
+	type container struct {
+		delim json.Delim
+		items int
+	}
+	var open []container
+	for {
+		tok, err := t.dec.Token()
+		if err == io.EOF && buf.Len() > 0 {
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
+			return err
+		}
+		if tok == json.Delim('}') || tok == json.Delim(']') {
+			buf.WriteByte(byte(tok.(json.Delim)))
+			open = open[:len(open)-1]
+		} else {
+			if len(open) > 0 {
+				c := &open[len(open)-1]
+				switch {
+				case c.delim == '{' && c.items%2 == 1:
+					buf.WriteByte(':')
+				case c.items > 0:
+					buf.WriteByte(',')
+				}
+				c.items++
+			}
+			if delim, ok := tok.(json.Delim); ok {
+				buf.WriteByte(byte(delim))
+				open = append(open, container{delim: delim})
+			} else {
+				b, err := json.Marshal(tok)
+				if err != nil {
+					return err
+				}
+				buf.Write(b)
+			}
+		}
+		if len(open) == 0 {
+			return json.Unmarshal(buf.Bytes(), v)
+		}
+	}
+}
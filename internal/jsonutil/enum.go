@@ -0,0 +1,61 @@
+package jsonutil
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Enum is implemented by the Go types of GraphQL enums. Their values are
+// decoded from one of the values of the enum, and other values fail to
+// decode.
+type Enum interface {
+	// GetGraphQLType returns the name of the enum, e.g. "Episode".
+	GetGraphQLType() string
+	// EnumValues returns the values of the enum, e.g. "NEWHOPE" and "EMPIRE".
+	EnumValues() []string
+}
+
+var enumType = reflect.TypeOf((*Enum)(nil)).Elem()
+
+// enum holds the name and the values of an Enum type.
+type enum struct {
+	name   string
+	values map[string]bool
+}
+
+var enumCache sync.Map // map[reflect.Type]*enum
+
+// cachedEnum returns the enum of t, or nil if t isn't a string type that
+// implements Enum.
+func cachedEnum(t reflect.Type) *enum {
+	if e, ok := enumCache.Load(t); ok {
+		return e.(*enum)
+	}
+	var e *enum
+	if t.Kind() == reflect.String && t.Implements(enumType) {
+		v := reflect.Zero(t).Interface().(Enum)
+		e = &enum{name: v.GetGraphQLType(), values: make(map[string]bool)}
+		for _, value := range v.EnumValues() {
+			e.values[value] = true
+		}
+	}
+	enumCache.Store(t, e)
+	return e
+}
+
+// validateEnum reports an error if v, or the value v points to, is an Enum
+// whose value isn't one of the values of the enum.
+func validateEnum(v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	e := cachedEnum(v.Type())
+	if e == nil || e.values[v.String()] {
+		return nil
+	}
+	return fmt.Errorf("%q isn't a value of enum %s", v.String(), e.name)
+}
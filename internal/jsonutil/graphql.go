@@ -4,6 +4,7 @@ package jsonutil
 
 import (
 	"bytes"
+	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,21 +12,26 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/phoban01/go-graphql-client/ident"
+	"github.com/phoban01/go-graphql-client/internal/selection"
 )
 
 // UnmarshalGraphQL parses the JSON-encoded GraphQL response data and stores
 // the result in the GraphQL query data structure pointed to by v.
 //
-// The implementation is created on top of the JSON tokenizer available
-// in "encoding/json".Decoder.
-func UnmarshalGraphQL(data []byte, v interface{}) error {
-	dec := json.NewDecoder(bytes.NewReader(data))
-	dec.UseNumber()
-	err := (&decoder{tokenizer: dec}).Decode(v)
+// The implementation is created on top of the JSON tokenizer of the codec,
+// "encoding/json".Decoder by default.
+func UnmarshalGraphQL(data []byte, v interface{}, opts ...Option) error {
+	d := newDecoder(nil, opts)
+	d.tokenizer = d.codec.NewTokenizer(bytes.NewReader(data))
+	err := d.Decode(v)
 	if err != nil {
 		return err
 	}
-	tok, err := dec.Token()
+	tok, err := d.tokenizer.Token()
 	switch err {
 	case io.EOF:
 		// Expect to get io.EOF. There shouldn't be any more
@@ -38,13 +44,101 @@ func UnmarshalGraphQL(data []byte, v interface{}) error {
 	}
 }
 
+// DecodeGraphQL decodes the next JSON value from dec, without buffering it,
+// and stores the result in the GraphQL query data structure pointed to by v.
+// dec should decode numbers as json.Number, see json.Decoder.UseNumber.
+// A null value leaves v unchanged.
+//
+// dec is usually a json.Decoder, or the Tokenizer of the codec given with
+// WithCodec.
+//
+// If the value can't be stored in v, the rest of the value is skipped
+// before returning the error, so that dec can go on with the JSON input
+// that follows, unless the JSON input itself is invalid.
+func DecodeGraphQL(dec Tokenizer, v interface{}, opts ...Option) error {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return errors.New("unexpected end of JSON input")
+	} else if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	d := newDecoder(&pushbackTokenizer{Tokenizer: dec, tok: tok, pushed: true}, opts)
+	if err := d.Decode(v); err != nil {
+		d.skip()
+		return err
+	}
+	return nil
+}
+
+// Option configures the decoding of GraphQL query data structures.
+type Option func(*decoder)
+
+// ScalarDecoders makes decoding decode the values of the Go types of
+// decoders with their decoder, given the JSON value and a pointer to the
+// value to decode into, rather than with their json.Unmarshaler
+// implementation. Values of these types are leaves of the query data
+// structure, like those with a scalar tag.
+func ScalarDecoders(decoders map[reflect.Type]func(data []byte, v interface{}) error) Option {
+	return func(d *decoder) {
+		if len(decoders) > 0 {
+			d.scalars = decoders
+		}
+	}
+}
+
+// Strict makes decoding fail when a field of the query data structure that
+// isn't nullable is missing from the JSON input, reporting the JSON path of
+// the field. Fields are nullable if they're pointers, interfaces or Optional.
+//
+// Fields of inline fragments with a type condition or a directive, of named
+// fragments, and of fields with an @include or @skip directive aren't required,
+// since they may not apply to the returned data.
+func Strict() Option {
+	return func(d *decoder) {
+		d.strict = true
+	}
+}
+
+// IgnoreTypename makes decoding skip the __typename fields of the JSON input
+// that no field of the query data structure holds, e.g. because they were
+// added to the query to identify the objects of the response.
+func IgnoreTypename() Option {
+	return func(d *decoder) {
+		d.ignoreTypename = true
+	}
+}
+
+// pushbackTokenizer returns tok before the tokens of the tokenizer,
+// if it's pushed back.
+type pushbackTokenizer struct {
+	Tokenizer
+	tok    json.Token
+	pushed bool
+}
+
+func (t *pushbackTokenizer) Token() (json.Token, error) {
+	if t.pushed {
+		t.pushed = false
+		return t.tok, nil
+	}
+	return t.Tokenizer.Token()
+}
+
 // decoder is a JSON decoder that performs custom unmarshaling behavior
 // for GraphQL query data structures. It's implemented on top of a JSON tokenizer.
 type decoder struct {
-	tokenizer interface {
-		Token() (json.Token, error)
-		Decode(v interface{}) error
-	}
+	tokenizer Tokenizer
+	// codec decodes the JSON values read whole, and creates the tokenizers
+	// of their decoders.
+	codec Codec
+	// scalars are the decoders of the custom scalars, by Go type.
+	scalars map[reflect.Type]func(data []byte, v interface{}) error
+	// possibleTypes are the concrete types of the interface types with
+	// possible types, by __typename, see PossibleTypes.
+	possibleTypes map[reflect.Type]map[string]reflect.Type
 
 	// Stack of what part of input JSON we're in the middle of - objects, arrays.
 	parseState []json.Delim
@@ -56,6 +150,49 @@ type decoder struct {
 	// a single JSON value into multiple GraphQL fragments or embedded structs, so
 	// we keep track of them all.
 	vs []stack
+
+	// conditional reports, for each stack of d.vs, whether it decodes into
+	// a fragment that may not apply to the JSON input, such as an inline
+	// fragment with a type condition.
+	conditional []bool
+
+	// path holds the JSON path of each object and array of parseState.
+	path []pathElem
+
+	// root is the type of the top-level value.
+	root reflect.Type
+
+	// ignoreTypename makes __typename keys that no field matches ignored,
+	// when decoding into a concrete type of an interface.
+	ignoreTypename bool
+
+	strict bool
+	// objects holds, in strict mode, the fields found in each object
+	// of parseState, to report the missing ones at the end of the object.
+	objects [][]objectFields
+}
+
+func newDecoder(tokenizer Tokenizer, opts []Option) *decoder {
+	d := &decoder{tokenizer: tokenizer, codec: StdCodec}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// pathElem is the position in a JSON object or array.
+type pathElem struct {
+	key   string
+	index int
+}
+
+// objectFields is a struct that a JSON object is decoded into,
+// along with the fields found in the object.
+type objectFields struct {
+	stack  int
+	fields *structFields
+	// found reports whether the struct field at each index was found.
+	found []bool
 }
 
 type stack []reflect.Value
@@ -75,18 +212,59 @@ func (d *decoder) Decode(v interface{}) error {
 		return fmt.Errorf("cannot decode into non-pointer %T", v)
 	}
 	d.vs = []stack{{rv.Elem()}}
-	return d.decode()
+	d.conditional = []bool{false}
+	d.root = rv.Elem().Type()
+	if err := d.decode(); err != nil {
+		if _, ok := err.(*DecodeError); ok {
+			return err
+		}
+		return d.decodeError(err)
+	}
+	return nil
+}
+
+// DecodeError is the error of decoding a JSON value into a GraphQL query
+// data structure, at the position of the value where decoding failed.
+type DecodeError struct {
+	// Path is the JSON path of the value, e.g. "repository.issues.nodes[3].createdAt",
+	// or empty for the top-level value.
+	Path string
+	// Field is the path of the Go struct field that the value is decoded
+	// into, e.g. "Repository.Issues.Nodes.CreatedAt", as far as it's known.
+	Field string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	switch {
+	case e.Path == "":
+		return e.Err.Error()
+	case e.Field == "":
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Path, e.Field, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeError returns err with the position of the value being decoded.
+func (d *decoder) decodeError(err error) *DecodeError {
+	return &DecodeError{Path: d.pathString(), Field: d.fieldPath(), Err: err}
 }
 
 // decode decodes a single JSON value from d.tokenizer into d.vs.
 func (d *decoder) decode() error {
-	rawMessageValue := reflect.ValueOf(json.RawMessage{})
-
 	// The loop invariant is that the top of each d.vs stack
 	// is where we try to unmarshal the next JSON value we see.
 	for len(d.vs) > 0 {
 		var tok interface{}
 		tok, err := d.tokenizer.Token()
+		// deferred reports which stacks of d.vs have a deferred value on top,
+		// if any.
+		var deferred []bool
 
 		if err == io.EOF {
 			return errors.New("unexpected end of JSON input")
@@ -102,23 +280,50 @@ func (d *decoder) decode() error {
 			if !ok {
 				return errors.New("unexpected non-key in JSON input")
 			}
+			d.path[len(d.path)-1].key = key
 			someFieldExist := false
 			// If one field is raw all must be treated as raw
 			rawMessage := false
 			isScalar := false
+			// Deferred fields are decoded once their whole value has been read.
+			deferredField := false
 			for i := range d.vs {
 				v := d.vs[i].Top()
 				for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
 					v = v.Elem()
 				}
 				var f reflect.Value
+				if sel, ok := selection.Of(v); ok {
+					if target := sel.Field(key); target != nil {
+						f = reflect.ValueOf(target).Elem()
+						someFieldExist = true
+						// A leaf field without a Go type holds the whole JSON value.
+						isScalar = f.Kind() == reflect.Interface
+						if f.Type() == rawMessageType {
+							rawMessage = true
+						}
+					}
+					d.vs[i] = append(d.vs[i], f)
+					continue
+				}
 				switch v.Kind() {
 				case reflect.Struct:
-					f, isScalar = fieldByGraphQLName(v, key)
-					if f.IsValid() {
+					if field, ok := cachedStructFields(v.Type()).byGraphQLName(key); ok {
+						f = v.Field(field.index)
 						someFieldExist = true
+						if d.strict {
+							d.found(i, field.index)
+						}
+						isScalar = isScalar || field.scalar || field.unmarshaler
+						if d.isDeferredField(field, f.Type()) {
+							if deferred == nil {
+								deferred = make([]bool, len(d.vs))
+							}
+							deferred[i] = true
+							deferredField = true
+						}
 						// Check for special embedded json
-						if f.Type() == rawMessageValue.Type() {
+						if field.rawMessage {
 							rawMessage = true
 						}
 					}
@@ -130,11 +335,11 @@ func (d *decoder) decode() error {
 				}
 				d.vs[i] = append(d.vs[i], f)
 			}
-			if !someFieldExist {
+			if !someFieldExist && !(d.ignoreTypename && key == "__typename") {
 				return fmt.Errorf("struct field for %q doesn't exist in any of %v places to unmarshal", key, len(d.vs))
 			}
 
-			if rawMessage || isScalar {
+			if rawMessage || isScalar || deferredField {
 				// Read the next complete object from the json stream
 				var data json.RawMessage
 				err = d.tokenizer.Decode(&data)
@@ -152,9 +357,11 @@ func (d *decoder) decode() error {
 					return err
 				}
 			}
+			d.unwrapOptionals(tok)
 
 		// Are we inside an array and seeing next value (rather than end of array)?
 		case d.state() == '[' && tok != json.Delim(']'):
+			d.path[len(d.path)-1].index++
 			someSliceExist := false
 			for i := range d.vs {
 				v := d.vs[i].Top()
@@ -178,6 +385,7 @@ func (d *decoder) decode() error {
 			if !someSliceExist {
 				return fmt.Errorf("slice doesn't exist in any of %v places to unmarshal", len(d.vs))
 			}
+			d.unwrapOptionals(tok)
 		}
 
 		switch tok := tok.(type) {
@@ -189,10 +397,27 @@ func (d *decoder) decode() error {
 				if !v.IsValid() {
 					continue
 				}
+				if deferred != nil && deferred[i] {
+					if err := d.decodeRaw(tok.(json.RawMessage), v, ""); err != nil {
+						decodeErr := err.(*DecodeError)
+						parent := d.decodeError(decodeErr.Err)
+						parent.Path = joinPath(parent.Path, decodeErr.Path)
+						if decodeErr.Field != "" {
+							parent.Field += "." + decodeErr.Field
+						}
+						return parent
+					}
+					continue
+				}
 				err := unmarshalValue(tok, v)
 				if err != nil {
 					return err
 				}
+				if !isNull(tok) {
+					if err := validateEnum(v); err != nil {
+						return err
+					}
+				}
 			}
 			d.popAllVs()
 
@@ -203,30 +428,41 @@ func (d *decoder) decode() error {
 
 				d.pushState(tok)
 
-				frontier := make([]reflect.Value, len(d.vs)) // Places to look for GraphQL fragments/embedded structs.
+				frontier := make([]int, len(d.vs)) // Stacks of d.vs to look for GraphQL fragments/embedded structs in.
 				for i := range d.vs {
 					v := d.vs[i].Top()
-					frontier[i] = v
+					frontier[i] = i
 					// TODO: Do this recursively or not? Add a test case if needed.
 					if v.Kind() == reflect.Ptr && v.IsNil() {
 						v.Set(reflect.New(v.Type().Elem())) // v = new(T).
 					}
 				}
+				var objects []objectFields
 				// Find GraphQL fragments/embedded structs recursively, adding to frontier
 				// as new ones are discovered and exploring them further.
 				for len(frontier) > 0 {
-					v := frontier[0]
+					s := frontier[0]
 					frontier = frontier[1:]
+					v := d.vs[s].Top()
 					for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
 						v = v.Elem()
 					}
-					if v.Kind() == reflect.Struct {
-						for i := 0; i < v.NumField(); i++ {
-							if isGraphQLFragment(v.Type().Field(i)) || v.Type().Field(i).Anonymous {
-								// Add GraphQL fragment or embedded struct.
-								d.vs = append(d.vs, []reflect.Value{v.Field(i)})
-								frontier = append(frontier, v.Field(i))
-							}
+					if sel, ok := selection.Of(v); ok {
+						for _, target := range sel.Fragments() {
+							// Add GraphQL fragment of the dynamic selection set.
+							d.pushStack(reflect.ValueOf(target).Elem(), true)
+							frontier = append(frontier, len(d.vs)-1)
+						}
+					} else if v.Kind() == reflect.Struct {
+						fields := cachedStructFields(v.Type())
+						conditional := d.conditional[s] || fields.namedFragment
+						if d.strict && !conditional {
+							objects = append(objects, objectFields{stack: s, fields: fields, found: make([]bool, v.NumField())})
+						}
+						for _, f := range fields.fragments {
+							// Add GraphQL fragment or embedded struct.
+							d.pushStack(v.Field(f.index), conditional || f.conditional)
+							frontier = append(frontier, len(d.vs)-1)
 						}
 					} else if isOrderedMap(v) {
 						for i := 0; i < v.Len(); i++ {
@@ -234,12 +470,15 @@ func (d *decoder) decode() error {
 							key, val := pair.Index(0), pair.Index(1)
 							if keyForGraphQLFragment(key.Interface().(string)) {
 								// Add GraphQL fragment or embedded struct.
-								d.vs = append(d.vs, []reflect.Value{val})
-								frontier = append(frontier, val)
+								d.pushStack(val, d.conditional[s] || isConditionalFragment(key.Interface().(string)))
+								frontier = append(frontier, len(d.vs)-1)
 							}
 						}
 					}
 				}
+				if d.strict {
+					d.objects = append(d.objects, objects)
+				}
 			case '[':
 				// Start of array.
 
@@ -275,8 +514,15 @@ func (d *decoder) decode() error {
 				}
 			case '}':
 				// End of object.
+				var err error
+				if d.strict {
+					err = d.checkMissingFields()
+				}
 				d.popAllVs()
 				d.popState()
+				if err != nil {
+					return err
+				}
 			case ']':
 				// End of array.
 				d.popLeftArrayTemplates()
@@ -293,6 +539,14 @@ func (d *decoder) decode() error {
 }
 
 func copyTemplate(template reflect.Value) (reflect.Value, error) {
+	if sel, ok := selection.Of(reflect.Indirect(template)); ok {
+		// copy the dynamic selection, so that each item is decoded into its own values
+		clone := reflect.ValueOf(sel.Clone()).Convert(reflect.PtrTo(reflect.Indirect(template).Type()))
+		if template.Kind() != reflect.Ptr {
+			clone = clone.Elem()
+		}
+		return clone, nil
+	}
 	if isOrderedMap(template) {
 		// copy slice if it's actually an ordered map
 		return copyOrderedMap(template), nil
@@ -323,15 +577,34 @@ func copyOrderedMap(m reflect.Value) reflect.Value {
 	return newMap
 }
 
+// skip reads the rest of the JSON value that d stopped decoding in the
+// middle of, until the objects and arrays it's in are closed.
+func (d *decoder) skip() {
+	for depth := len(d.parseState); depth > 0; {
+		tok, err := d.tokenizer.Token()
+		if err != nil {
+			return
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+}
+
 // pushState pushes a new parse state s onto the stack.
 func (d *decoder) pushState(s json.Delim) {
 	d.parseState = append(d.parseState, s)
+	d.path = append(d.path, pathElem{index: -1})
 }
 
 // popState pops a parse state (already obtained) off the stack.
 // The stack must be non-empty.
 func (d *decoder) popState() {
 	d.parseState = d.parseState[:len(d.parseState)-1]
+	d.path = d.path[:len(d.path)-1]
 }
 
 // state reports the parse state on top of stack, or 0 if empty.
@@ -342,16 +615,138 @@ func (d *decoder) state() json.Delim {
 	return d.parseState[len(d.parseState)-1]
 }
 
+// pushStack adds a stack to d.vs, to unmarshal into v.
+// conditional reports whether v is a fragment that may not apply.
+func (d *decoder) pushStack(v reflect.Value, conditional bool) {
+	d.vs = append(d.vs, stack{v})
+	d.conditional = append(d.conditional, conditional)
+}
+
 // popAllVs pops from all d.vs stacks, keeping only non-empty ones.
+//
+// Stacks are added to d.vs at the start of objects, and become empty at their
+// end, so the indexes of the remaining stacks don't change.
 func (d *decoder) popAllVs() {
-	var nonEmpty []stack
+	nonEmpty := d.vs[:0]
+	conditional := d.conditional[:0]
 	for i := range d.vs {
 		d.vs[i] = d.vs[i].Pop()
 		if len(d.vs[i]) > 0 {
 			nonEmpty = append(nonEmpty, d.vs[i])
+			conditional = append(conditional, d.conditional[i])
 		}
 	}
 	d.vs = nonEmpty
+	d.conditional = conditional
+}
+
+// found records, in strict mode, that the struct field at index was found
+// in the object being decoded into the top of stack s.
+func (d *decoder) found(s, index int) {
+	for _, o := range d.objects[len(d.objects)-1] {
+		if o.stack == s {
+			o.found[index] = true
+		}
+	}
+}
+
+// checkMissingFields returns an error, in strict mode, if a field required
+// by the structs that the object being decoded ends into is missing.
+func (d *decoder) checkMissingFields() error {
+	objects := d.objects[len(d.objects)-1]
+	d.objects = d.objects[:len(d.objects)-1]
+	for _, o := range objects {
+		for _, f := range o.fields.required {
+			if o.found[f.index] {
+				continue
+			}
+			d.path[len(d.path)-1].key = f.key
+			return d.decodeError(errors.New("non-nullable field is missing"))
+		}
+	}
+	return nil
+}
+
+// pathString returns the JSON path of the value being decoded,
+// e.g. "repository.issues.nodes[0].title".
+func (d *decoder) pathString() string {
+	var b strings.Builder
+	for i, e := range d.path {
+		if d.parseState[i] == '[' {
+			if e.index == -1 {
+				// Before the first item.
+				break
+			}
+			b.WriteString("[")
+			b.WriteString(strconv.Itoa(e.index))
+			b.WriteString("]")
+			continue
+		}
+		if e.key == "" {
+			// Before the first key.
+			break
+		}
+		if i > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(e.key)
+	}
+	return b.String()
+}
+
+// fieldPath returns the path of the Go struct field that the value being
+// decoded goes into, e.g. "Repository.Issues.Nodes.Title", following the
+// JSON path from the top-level type through struct fields, fragments and
+// embedded structs. It stops where the JSON path can't be followed.
+func (d *decoder) fieldPath() string {
+	var names []string
+	t := d.root
+	for i, e := range d.path {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if d.parseState[i] == '[' {
+			if t.Kind() != reflect.Slice || e.index == -1 {
+				break
+			}
+			t = t.Elem()
+			continue
+		}
+		if t.Kind() != reflect.Struct || e.key == "" {
+			break
+		}
+		fieldNames, ft, ok := structFieldByGraphQLName(t, e.key)
+		if !ok {
+			break
+		}
+		names = append(names, fieldNames...)
+		t = ft
+	}
+	return strings.Join(names, ".")
+}
+
+// structFieldByGraphQLName returns the names of the struct field of t that
+// matches GraphQL name, which may be in fragments or embedded structs of t,
+// and its type.
+func structFieldByGraphQLName(t reflect.Type, name string) ([]string, reflect.Type, bool) {
+	fields := cachedStructFields(t)
+	if f, ok := fields.byGraphQLName(name); ok {
+		return []string{f.name}, t.Field(f.index).Type, true
+	}
+	for _, f := range fields.fragments {
+		sf := t.Field(f.index)
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct {
+			continue
+		}
+		if names, t, ok := structFieldByGraphQLName(ft, name); ok {
+			return append([]string{sf.Name}, names...), t, true
+		}
+	}
+	return nil, nil, false
 }
 
 // popLeftArrayTemplates pops left from last array items of all d.vs stacks.
@@ -364,21 +759,239 @@ func (d *decoder) popLeftArrayTemplates() {
 	}
 }
 
-// fieldByGraphQLName returns an exported struct field of struct v
-// that matches GraphQL name, or invalid reflect.Value if none found.
-func fieldByGraphQLName(v reflect.Value, name string) (val reflect.Value, taggedAsScalar bool) {
-	for i := 0; i < v.NumField(); i++ {
-		if v.Type().Field(i).PkgPath != "" {
+// structFields is the lookup table of the fields of a struct type,
+// computed once per type and cached in structFieldsCache.
+type structFields struct {
+	// fields are the exported struct fields, in declaration order.
+	fields []field
+	// tagged maps the GraphQL names of the fields with a graphql tag
+	// to the position of the first such field in fields.
+	tagged map[string]int
+	// untagged maps the lowercase names of the fields without a graphql tag,
+	// which match GraphQL names case-insensitively, to the position of the
+	// first such field in fields. Fields with non-ASCII names are in folded
+	// instead, since they're matched with strings.EqualFold.
+	untagged map[string]int
+	folded   []int
+	// fragments are the GraphQL fragments and embedded structs.
+	fragments []fragment
+	// required are the fields that aren't nullable, which strict mode
+	// requires in the JSON input.
+	required []field
+	// namedFragment reports whether the struct is a named fragment.
+	namedFragment bool
+}
+
+type fragment struct {
+	index int
+	// conditional reports whether the fragment may not apply to the
+	// JSON input, e.g. because it has a type condition.
+	conditional bool
+}
+
+type field struct {
+	index int
+	name  string
+	// scalar reports whether the field is tagged as a scalar.
+	scalar bool
+	// rawMessage reports whether the field is a json.RawMessage.
+	rawMessage bool
+	// unmarshaler reports whether the field is an unmarshaler, or a pointer
+	// to or a slice of them, whose whole value is unmarshaled at once, e.g.
+	// a JSON scalar whose values are objects.
+	unmarshaler bool
+	// hasTag reports whether the field has a graphql tag.
+	hasTag bool
+	// key is the GraphQL response key of the field.
+	key string
+	// deferrable reports whether the field is a map or an interface, or a
+	// pointer to or a slice of them, which may be deferred (see isDeferred).
+	deferrable bool
+}
+
+var structFieldsCache sync.Map // map[reflect.Type]*structFields
+
+// cachedStructFields returns the lookup table of struct type t.
+func cachedStructFields(t reflect.Type) *structFields {
+	if fs, ok := structFieldsCache.Load(t); ok {
+		return fs.(*structFields)
+	}
+	fs, _ := structFieldsCache.LoadOrStore(t, newStructFields(t))
+	return fs.(*structFields)
+}
+
+func newStructFields(t reflect.Type) *structFields {
+	fs := &structFields{
+		tagged:        make(map[string]int),
+		untagged:      make(map[string]int),
+		namedFragment: t.Implements(namedFragmentType) || reflect.PtrTo(t).Implements(namedFragmentType),
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		value, tagged := sf.Tag.Lookup("graphql")
+		isFragment := isGraphQLFragment(sf)
+		if isFragment || sf.Anonymous {
+			// Embedded structs with a graphql tag that isn't a fragment
+			// belong to the field, not to the object.
+			fs.fragments = append(fs.fragments, fragment{index: i, conditional: tagged && isConditionalFragment(value)})
+		}
+		if sf.PkgPath != "" {
 			// Skip unexported field.
 			continue
 		}
-		if hasGraphQLName(v.Type().Field(i), name) {
-			return v.Field(i), hasScalarTag(v.Type().Field(i))
+		f := field{
+			index:       i,
+			name:        sf.Name,
+			scalar:      hasScalarTag(sf),
+			rawMessage:  sf.Type == rawMessageType,
+			unmarshaler: hasUnmarshaler(sf.Type),
+			key:         ident.ParseMixedCaps(sf.Name).ToLowerCamelCase(),
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		f.deferrable = !f.scalar && (ft.Kind() == reflect.Map || ft.Kind() == reflect.Interface)
+		pos := len(fs.fields)
+		if tagged {
+			f.hasTag = true
+			if name, ok := graphQLName(value); ok {
+				f.key = name
+				if _, dup := fs.tagged[name]; !dup {
+					fs.tagged[name] = pos
+				}
+			}
+		} else if isASCII(sf.Name) {
+			name := strings.ToLower(sf.Name)
+			if _, dup := fs.untagged[name]; !dup {
+				fs.untagged[name] = pos
+			}
+		} else {
+			fs.folded = append(fs.folded, pos)
+		}
+		fs.fields = append(fs.fields, f)
+		if !sf.Anonymous && !isFragment && value != "-" && !hasConditionalDirective(value) &&
+			sf.Type.Kind() != reflect.Ptr && sf.Type.Kind() != reflect.Interface && !IsOptional(sf.Type) {
+			fs.required = append(fs.required, f)
+		}
+	}
+	return fs
+}
+
+// namedFragment is implemented by named fragments, see graphql.NamedFragment.
+type namedFragment interface {
+	FragmentName() string
+	TypeCondition() string
+}
+
+var namedFragmentType = reflect.TypeOf((*namedFragment)(nil)).Elem()
+
+// isConditionalFragment reports whether the fragment with the graphql tag
+// value may not apply to the JSON input, because it isn't an inline fragment
+// without type condition and directives, e.g. "... on User".
+func isConditionalFragment(value string) bool {
+	value = strings.TrimSpace(value)
+	return !strings.HasPrefix(value, "...") || strings.TrimSpace(value[len("..."):]) != ""
+}
+
+// hasConditionalDirective reports whether the graphql tag value
+// has an @include or @skip directive.
+func hasConditionalDirective(value string) bool {
+	return strings.Contains(value, "@include") || strings.Contains(value, "@skip")
+}
+
+// byGraphQLName returns the first exported struct field that matches
+// GraphQL name: a field whose graphql tag has name as response key, or a
+// field without graphql tag whose Go name equals name case-insensitively.
+func (fs *structFields) byGraphQLName(name string) (field, bool) {
+	pos := -1
+	if p, ok := fs.tagged[name]; ok {
+		pos = p
+	}
+	if isASCII(name) {
+		// Look up the lowercase name without allocating it, if it's short.
+		var buf [64]byte
+		if p, ok := fs.untagged[string(lowerASCII(buf[:0], name))]; ok && (pos == -1 || p < pos) {
+			pos = p
+		}
+	} else {
+		// Non-ASCII names may still fold to ASCII ones, e.g. the Kelvin sign.
+		for p, f := range fs.fields {
+			if pos != -1 && p >= pos {
+				break
+			}
+			if !f.hasTag && strings.EqualFold(f.name, name) {
+				pos = p
+			}
+		}
+	}
+	for _, p := range fs.folded {
+		if pos != -1 && p >= pos {
+			break
+		}
+		if strings.EqualFold(fs.fields[p].name, name) {
+			pos = p
+		}
+	}
+	if pos == -1 {
+		return field{}, false
+	}
+	return fs.fields[pos], true
+}
+
+// FieldByResponseKey returns the field of the query data structure v that
+// the value of the response key is decoded into, looking into the fragments
+// and embedded structs of v like decoding does. It reports false if there is
+// no such field, or if v is a nil pointer.
+func FieldByResponseKey(v reflect.Value, key string) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if sel, ok := selection.Of(v); ok {
+		if target := sel.Field(key); target != nil {
+			return reflect.ValueOf(target).Elem(), true
+		}
+		return reflect.Value{}, false
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	fs := cachedStructFields(v.Type())
+	if field, ok := fs.byGraphQLName(key); ok {
+		return v.Field(field.index), true
+	}
+	for _, fragment := range fs.fragments {
+		if f, ok := FieldByResponseKey(v.Field(fragment.index), key); ok {
+			return f, true
 		}
 	}
 	return reflect.Value{}, false
 }
 
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// lowerASCII appends the ASCII string s in lowercase to buf.
+func lowerASCII(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
 // orderedMapValueByGraphQLName takes [][2]string, interprets it as an ordered map
 // and returns value for corresponding key, or invalid reflect.Value if none found.
 func orderedMapValueByGraphQLName(v reflect.Value, name string) reflect.Value {
@@ -401,30 +1014,27 @@ func isTrue(s string) bool {
 	return b
 }
 
-// hasGraphQLName reports whether struct field f has GraphQL name.
-func hasGraphQLName(f reflect.StructField, name string) bool {
-	value, ok := f.Tag.Lookup("graphql")
-	if !ok {
-		// TODO: caseconv package is relatively slow. Optimize it, then consider using it here.
-		//return caseconv.MixedCapsToLowerCamelCase(f.Name) == name
-		return strings.EqualFold(f.Name, name)
-	}
-	return keyHasGraphQLName(value, name)
+func keyHasGraphQLName(value, name string) bool {
+	key, ok := graphQLName(value)
+	return ok && key == name
 }
 
-func keyHasGraphQLName(value, name string) bool {
+// graphQLName returns the response key of the graphql tag value, or false
+// if the value is a GraphQL fragment, which doesn't have a name.
+func graphQLName(value string) (string, bool) {
 	value = strings.TrimSpace(value) // TODO: Parse better.
 	if strings.HasPrefix(value, "...") {
-		// GraphQL fragment. It doesn't have a name.
-		return false
+		return "", false
 	}
-	if i := strings.Index(value, "("); i != -1 {
+	// Cut arguments and directives, e.g. "friends @include(if: $withFriends)",
+	// and the selection set of maps.
+	if i := strings.IndexAny(value, "(@{"); i != -1 {
 		value = value[:i]
 	}
 	if i := strings.Index(value, ":"); i != -1 {
 		value = value[:i]
 	}
-	return strings.TrimSpace(value) == name
+	return strings.TrimSpace(value), true
 }
 
 // isGraphQLFragment reports whether struct field f is a GraphQL fragment.
@@ -446,7 +1056,10 @@ func keyForGraphQLFragment(value string) bool {
 // v must be addressable and not obtained by the use of unexported
 // struct fields, otherwise unmarshalValue will panic.
 func unmarshalValue(value interface{}, v reflect.Value) error {
-	b, err := json.Marshal(value) // TODO: Short-circuit (if profiling says it's worth it).
+	if setValue(value, v) {
+		return nil
+	}
+	b, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
@@ -465,3 +1078,151 @@ func unmarshalValue(value interface{}, v reflect.Value) error {
 	v.Set(newVal.Elem())
 	return nil
 }
+
+var (
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	numberType          = reflect.TypeOf(json.Number(""))
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setValue sets v to the JSON scalar value directly, without a round-trip
+// through encoding/json, and reports whether it did. It only handles the
+// values that json.Unmarshal would decode successfully into builtin kinds,
+// leaving custom unmarshalers and errors to unmarshalValue, so that both
+// produce the same results.
+func setValue(value interface{}, v reflect.Value) bool {
+	t := v.Type()
+	if t.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if t.NumMethod() != 0 {
+				return false
+			}
+			// The same values as json.Unmarshal into an empty interface.
+			switch value := value.(type) {
+			case nil:
+				v.Set(reflect.Zero(t))
+			case string:
+				v.Set(reflect.ValueOf(value))
+			case bool:
+				v.Set(reflect.ValueOf(value))
+			case json.Number:
+				f, err := strconv.ParseFloat(string(value), 64)
+				if err != nil {
+					return false
+				}
+				v.Set(reflect.ValueOf(f))
+			default:
+				return false
+			}
+			return true
+		}
+		// Decode into a new value of the dynamic type, like unmarshalValue.
+		nv := reflect.New(v.Elem().Type()).Elem()
+		if !setValue(value, nv) {
+			return false
+		}
+		v.Set(nv)
+		return true
+	}
+	if isUnmarshaler(t) {
+		return false
+	}
+	if value == nil {
+		v.Set(reflect.Zero(t))
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(t.Elem())
+		if !setValue(value, p.Elem()) {
+			return false
+		}
+		v.Set(p)
+	case reflect.String:
+		switch value := value.(type) {
+		case string:
+			if t == numberType {
+				return false
+			}
+			v.SetString(value)
+		case json.Number:
+			if t != numberType {
+				return false
+			}
+			v.SetString(string(value))
+		default:
+			return false
+		}
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return false
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		if err != nil || v.OverflowInt(i) {
+			return false
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		u, err := strconv.ParseUint(string(n), 10, 64)
+		if err != nil || v.OverflowUint(u) {
+			return false
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := strconv.ParseFloat(string(n), t.Bits())
+		if err != nil || v.OverflowFloat(f) {
+			return false
+		}
+		v.SetFloat(f)
+	default:
+		return false
+	}
+	return true
+}
+
+// hasUnmarshaler reports whether t is an unmarshaler, see isUnmarshaler, or
+// a pointer to or a slice of them.
+func hasUnmarshaler(t reflect.Type) bool {
+	for {
+		if isUnmarshaler(t) {
+			return true
+		}
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+var unmarshalerCache sync.Map // map[reflect.Type]bool
+
+// isUnmarshaler reports whether values of type t, or pointers to them,
+// implement json.Unmarshaler or encoding.TextUnmarshaler.
+func isUnmarshaler(t reflect.Type) bool {
+	if ok, cached := unmarshalerCache.Load(t); cached {
+		return ok.(bool)
+	}
+	pt := reflect.PtrTo(t)
+	ok := t.Implements(jsonUnmarshalerType) || pt.Implements(jsonUnmarshalerType) ||
+		t.Implements(textUnmarshalerType) || pt.Implements(textUnmarshalerType)
+	unmarshalerCache.Store(t, ok)
+	return ok
+}
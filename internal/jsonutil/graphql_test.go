@@ -2,7 +2,9 @@ package jsonutil_test
 
 import (
 	"encoding/json"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,7 +28,7 @@ func TestUnmarshalGraphQL(t *testing.T) {
 		}
 	}
 	var got query
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"me": {
 			"name": "Luke Skywalker",
 			"height": 1.72
@@ -48,7 +50,7 @@ func TestUnmarshalGraphQL_graphqlTag(t *testing.T) {
 		Foo graphql.String `graphql:"baz"`
 	}
 	var got query
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"baz": "bar"
 	}`), &got)
 	if err != nil {
@@ -62,12 +64,37 @@ func TestUnmarshalGraphQL_graphqlTag(t *testing.T) {
 	}
 }
 
+func TestUnmarshalGraphQL_graphqlTagDirective(t *testing.T) {
+	type query struct {
+		Foo graphql.String `graphql:"foo @include(if: $withFoo)"`
+		Bar graphql.String `graphql:"baz: bar @skip(if: false)"`
+		Qux graphql.String `graphql:"qux(id: 1)@include(if: true)"`
+	}
+	var got query
+	err := unmarshalGraphQL([]byte(`{
+		"foo": "foo",
+		"baz": "bar",
+		"qux": "qux"
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Foo: "foo",
+		Bar: "bar",
+		Qux: "qux",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("not equal")
+	}
+}
+
 func TestUnmarshalGraphQL_jsonTag(t *testing.T) {
 	type query struct {
 		Foo graphql.String `json:"baz"`
 	}
 	var got query
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"foo": "bar"
 	}`), &got)
 	if err != nil {
@@ -87,7 +114,7 @@ func TestUnmarshalGraphQL_jsonRawTag(t *testing.T) {
 		Another string
 	}
 	var got query
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"Data": { "foo":"bar" },
 		"Another" : "stuff"
         }`), &got)
@@ -112,7 +139,7 @@ func TestUnmarshalGraphQL_fieldAsScalar(t *testing.T) {
 		Tags    map[string]int `scalar:"true"`
 	}
 	var got query
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
                 "Data" : {"ValA":1,"ValB":"foo"},
                 "DataPtr" : {"ValC":3,"ValD":false},
 		"Another" : "stuff",
@@ -145,7 +172,7 @@ func TestUnmarshalGraphQL_orderedMap(t *testing.T) {
 	got := query{
 		{"foo", graphql.String("")},
 	}
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"foo": "bar"
 	}`), &got)
 	if err != nil {
@@ -167,7 +194,7 @@ func TestUnmarshalGraphQL_orderedMapAlias(t *testing.T) {
 		{"update0:update(name:$name0)", &Update{}},
 		{"update1:update(name:$name1)", &Update{}},
 	}
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
       "update0": {
         "name": "grihabor"
       },
@@ -194,7 +221,7 @@ func TestUnmarshalGraphQL_array(t *testing.T) {
 		Baz []graphql.String
 	}
 	var got query
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"foo": [
 			"bar",
 			"baz"
@@ -219,7 +246,7 @@ func TestUnmarshalGraphQL_array(t *testing.T) {
 // (rather than appended to).
 func TestUnmarshalGraphQL_arrayReset(t *testing.T) {
 	var got = []string{"initial"}
-	err := jsonutil.UnmarshalGraphQL([]byte(`["bar", "baz"]`), &got)
+	err := unmarshalGraphQL([]byte(`["bar", "baz"]`), &got)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -236,7 +263,7 @@ func TestUnmarshalGraphQL_objectArray(t *testing.T) {
 		}
 	}
 	var got query
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"foo": [
 			{"name": "bar"},
 			{"name": "baz"}
@@ -265,7 +292,7 @@ func TestUnmarshalGraphQL_orderedMapArray(t *testing.T) {
 			{{"name", graphql.String("")}},
 		},
 	}
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"foo": [
 			{"name": "bar"},
 			{"name": "baz"}
@@ -292,7 +319,7 @@ func TestUnmarshalGraphQL_pointer(t *testing.T) {
 	}
 	var got query
 	got.Bar = new(graphql.String) // Test that got.Bar gets set to nil.
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"foo": "foo",
 		"bar": null
 	}`), &got)
@@ -315,7 +342,7 @@ func TestUnmarshalGraphQL_objectPointerArray(t *testing.T) {
 		}
 	}
 	var got query
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"foo": [
 			{"name": "bar"},
 			null,
@@ -346,7 +373,7 @@ func TestUnmarshalGraphQL_orderedMapNullInArray(t *testing.T) {
 			{{"name", ""}},
 		},
 	}
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"foo": [
 			{"name": "bar"},
 			null,
@@ -380,7 +407,7 @@ func TestUnmarshalGraphQL_pointerWithInlineFragment(t *testing.T) {
 		Editor *actor
 	}
 	var got query
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"author": {
 			"databaseId": 1,
 			"login": "test1"
@@ -412,11 +439,11 @@ func TestUnmarshalGraphQL_unexportedField(t *testing.T) {
 	type query struct {
 		foo graphql.String
 	}
-	err := jsonutil.UnmarshalGraphQL([]byte(`{"foo": "bar"}`), new(query))
+	err := unmarshalGraphQL([]byte(`{"foo": "bar"}`), new(query))
 	if err == nil {
 		t.Fatal("got error: nil, want: non-nil")
 	}
-	if got, want := err.Error(), "struct field for \"foo\" doesn't exist in any of 1 places to unmarshal"; got != want {
+	if got, want := err.Error(), "foo: struct field for \"foo\" doesn't exist in any of 1 places to unmarshal"; got != want {
 		t.Errorf("got error: %v, want: %v", got, want)
 	}
 }
@@ -425,7 +452,7 @@ func TestUnmarshalGraphQL_multipleValues(t *testing.T) {
 	type query struct {
 		Foo graphql.String
 	}
-	err := jsonutil.UnmarshalGraphQL([]byte(`{"foo": "bar"}{"foo": "baz"}`), new(query))
+	err := unmarshalGraphQL([]byte(`{"foo": "bar"}{"foo": "baz"}`), new(query))
 	if err == nil {
 		t.Fatal("got error: nil, want: non-nil")
 	}
@@ -437,7 +464,7 @@ func TestUnmarshalGraphQL_multipleValues(t *testing.T) {
 func TestUnmarshalGraphQL_multipleValuesInOrderedMap(t *testing.T) {
 	type query [][2]interface{}
 	q := query{{"foo", ""}}
-	err := jsonutil.UnmarshalGraphQL([]byte(`{"foo": "bar"}{"foo": "baz"}`), &q)
+	err := unmarshalGraphQL([]byte(`{"foo": "bar"}{"foo": "baz"}`), &q)
 	if err == nil {
 		t.Fatal("got error: nil, want: non-nil")
 	}
@@ -475,7 +502,7 @@ func TestUnmarshalGraphQL_union(t *testing.T) {
 		ReopenedEvent reopenedEvent `graphql:"... on ReopenedEvent"`
 	}
 	var got issueTimelineItem
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"__typename": "ClosedEvent",
 		"createdAt": "2017-06-29T04:12:01Z",
 		"actor": {
@@ -527,7 +554,7 @@ func TestUnmarshalGraphQL_orderedMapUnion(t *testing.T) {
 		{"... on ClosedEvent", closedEvent},
 		{"... on ReopenedEvent", reopenedEvent},
 	}
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"__typename": "ClosedEvent",
 		"createdAt": "2017-06-29T04:12:01Z",
 		"actor": {
@@ -586,7 +613,7 @@ func TestUnmarshalGraphQL_arrayInsideInlineFragment(t *testing.T) {
 		} `graphql:"search(type: ISSUE, first: 1, query: \"type:pr repo:owner/name\")"`
 	}
 	var got query
-	err := jsonutil.UnmarshalGraphQL([]byte(`{
+	err := unmarshalGraphQL([]byte(`{
 		"search": {
 			"nodes": [
 				{
@@ -622,3 +649,733 @@ func TestUnmarshalGraphQL_arrayInsideInlineFragment(t *testing.T) {
 		t.Error("not equal")
 	}
 }
+
+// actorFields is a named fragment, see graphql.NamedFragment.
+type actorFields struct {
+	Login graphql.String
+}
+
+func (actorFields) FragmentName() string  { return "ActorFields" }
+func (actorFields) TypeCondition() string { return "Actor" }
+
+func TestUnmarshalGraphQL_namedFragment(t *testing.T) {
+	/*
+		{
+			viewer {
+				...ActorFields
+			}
+			node {
+				__typename
+				...ActorFields
+			}
+		}
+		fragment ActorFields on Actor {
+			login
+		}
+	*/
+	type query struct {
+		Viewer actorFields
+		Node   struct {
+			Typename string `graphql:"__typename"`
+			actorFields
+		}
+	}
+	var got query
+	err := unmarshalGraphQL([]byte(`{
+		"viewer": {
+			"login": "gopher"
+		},
+		"node": {
+			"__typename": "User",
+			"login": "shurcooL-test"
+		}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Viewer.Login = "gopher"
+	want.Node.Typename = "User"
+	want.Node.Login = "shurcooL-test"
+	if !reflect.DeepEqual(got, want) {
+		t.Error("not equal")
+	}
+}
+
+func TestUnmarshalGraphQL_selection(t *testing.T) {
+	/*
+		{
+			login
+			repo: repository(name: "go-graphql-client") {
+				name
+				stargazers { totalCount }
+				issues { title }
+			}
+			... on User { bio }
+		}
+	*/
+	type user struct {
+		Bio graphql.String
+	}
+	var login graphql.String
+	var stargazers struct {
+		TotalCount graphql.Int
+	}
+	var issues []*graphql.Selection
+	var bio user
+	repo := graphql.NewSelection().
+		Field("name", nil).
+		Field("stargazers", &stargazers).
+		Field("issues", &issues)
+	issues = append(issues, graphql.NewSelection().Field("title", nil))
+	got := graphql.NewSelection().
+		Field("login", &login).
+		Field("repo: repository(name: \"go-graphql-client\")", repo).
+		On("User", &bio)
+	err := unmarshalGraphQL([]byte(`{
+		"login": "gopher",
+		"repo": {
+			"name": "go-graphql-client",
+			"stargazers": {"totalCount": 42},
+			"issues": [
+				{"title": "first"},
+				{"title": "second"}
+			]
+		},
+		"bio": "Gopher"
+	}`), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login != "gopher" {
+		t.Errorf("got login: %q, want: %q", login, "gopher")
+	}
+	if got, want := repo.Value("name"), "go-graphql-client"; got != want {
+		t.Errorf("got repo name: %v, want: %v", got, want)
+	}
+	if stargazers.TotalCount != 42 {
+		t.Errorf("got stargazers: %v, want: 42", stargazers.TotalCount)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("got %d issues, want 2", len(issues))
+	}
+	if got, want := issues[0].Value("title"), "first"; got != want {
+		t.Errorf("got issues[0] title: %v, want: %v", got, want)
+	}
+	if got, want := issues[1].Value("title"), "second"; got != want {
+		t.Errorf("got issues[1] title: %v, want: %v", got, want)
+	}
+	if bio.Bio != "Gopher" {
+		t.Errorf("got bio: %q, want: %q", bio.Bio, "Gopher")
+	}
+}
+
+func TestUnmarshalGraphQL_selectionInStruct(t *testing.T) {
+	type query struct {
+		Viewer struct {
+			Login   graphql.String
+			Dynamic graphql.Selection `graphql:"status"`
+		}
+	}
+	var got query
+	got.Viewer.Dynamic.Field("emoji", nil).Field("message", nil)
+	err := unmarshalGraphQL([]byte(`{
+		"viewer": {
+			"login": "gopher",
+			"status": {"emoji": ":wave:", "message": {"text": "hello"}}
+		}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Viewer.Login != "gopher" {
+		t.Errorf("got login: %q, want: %q", got.Viewer.Login, "gopher")
+	}
+	if got, want := got.Viewer.Dynamic.Value("emoji"), ":wave:"; got != want {
+		t.Errorf("got emoji: %v, want: %v", got, want)
+	}
+	if got, want := got.Viewer.Dynamic.Value("message"), map[string]interface{}{"text": "hello"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got message: %v, want: %v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_builtinKinds(t *testing.T) {
+	type myString string
+	type query struct {
+		Int       int
+		Int8      int8
+		Uint16    uint16
+		Float32   float32
+		Bool      bool
+		Named     myString
+		Pointer   *int64
+		Null      *string
+		Number    json.Number
+		Interface interface{}
+		Dynamic   interface{}
+		Time      *time.Time
+	}
+	got := query{Null: new(string), Dynamic: graphql.Int(0)}
+	err := unmarshalGraphQL([]byte(`{
+		"int": -42,
+		"int8": 127,
+		"uint16": 65535,
+		"float32": 1.5,
+		"bool": true,
+		"named": "foo",
+		"pointer": 9007199254740993,
+		"null": null,
+		"number": 1e3,
+		"interface": 1.25,
+		"dynamic": 7,
+		"time": "2017-06-29T04:12:01Z"
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pointer := int64(9007199254740993)
+	createdAt := time.Unix(1498709521, 0).UTC()
+	want := query{
+		Int:       -42,
+		Int8:      127,
+		Uint16:    65535,
+		Float32:   1.5,
+		Bool:      true,
+		Named:     "foo",
+		Pointer:   &pointer,
+		Number:    "1e3",
+		Interface: 1.25,
+		Dynamic:   graphql.Int(7),
+		Time:      &createdAt,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// The values that can't be assigned to the builtin kinds directly
+// fail with the same errors as encoding/json.
+func TestUnmarshalGraphQL_builtinKindErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		v    interface{}
+	}{
+		{`{"value": 128}`, &struct{ Value int8 }{}},
+		{`{"value": -1}`, &struct{ Value uint }{}},
+		{`{"value": 1.5}`, &struct{ Value int }{}},
+		{`{"value": "1"}`, &struct{ Value int }{}},
+		{`{"value": 1}`, &struct{ Value string }{}},
+		{`{"value": "foo"}`, &struct{ Value json.Number }{}},
+		{`{"value": 1e39}`, &struct{ Value float32 }{}},
+		{`{"value": true}`, &struct{ Value *float64 }{}},
+	}
+	for _, tc := range tests {
+		err := unmarshalGraphQL([]byte(tc.data), tc.v)
+		var decodeErr *jsonutil.DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Errorf("%s: got error %v, want a DecodeError", tc.data, err)
+			continue
+		}
+		value := reflect.New(reflect.TypeOf(tc.v).Elem().Field(0).Type)
+		want := json.Unmarshal([]byte(tc.data[len(`{"value": `):len(tc.data)-1]), value.Interface())
+		if want == nil || decodeErr.Err.Error() != want.Error() {
+			t.Errorf("%s: got error %v, want %v", tc.data, err, want)
+		}
+	}
+}
+
+func TestUnmarshalGraphQL_fieldNameCase(t *testing.T) {
+	// The first field matching the key is used, whether it matches
+	// the graphql tag or, without a tag, its name case-insensitively.
+	type query struct {
+		Login  graphql.String
+		Handle graphql.String `graphql:"login"`
+		Name   graphql.String `graphql:"name"`
+		NAME   graphql.String
+		Kind   graphql.String
+	}
+	var got query
+	err := unmarshalGraphQL([]byte(`{
+		"login": "gopher",
+		"NAME": "Gopher",
+		"name": "The Gopher",
+		"\u212Aind": "Kelvin sign"
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Login: "gopher",
+		Name:  "The Gopher",
+		NAME:  "Gopher",
+		Kind:  "Kelvin sign",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeGraphQL(t *testing.T) {
+	dec := testCodec.NewTokenizer(strings.NewReader(`
+		{"user": {"name": "Gopher", "friends": [{"name": "Luke"}]}}
+		null
+		{"user": {"name": 42, "friends": [{"name": "Leia"}]}}
+		{"user": {"name": "Han"}}
+	`))
+	type query struct {
+		User struct {
+			Name    graphql.String
+			Friends []struct{ Name graphql.String }
+		}
+	}
+	var got query
+	if err := jsonutil.DecodeGraphQL(dec, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.User.Name != "Gopher" || len(got.User.Friends) != 1 || got.User.Friends[0].Name != "Luke" {
+		t.Errorf("got %+v", got)
+	}
+
+	// A null value leaves the query unchanged.
+	if err := jsonutil.DecodeGraphQL(dec, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.User.Name != "Gopher" {
+		t.Errorf("got %+v, want the query unchanged", got)
+	}
+
+	// The rest of a value that can't be decoded is skipped.
+	if err := jsonutil.DecodeGraphQL(dec, &query{}); err == nil {
+		t.Error("got nil error, want an error decoding a number into a string")
+	}
+	got = query{}
+	if err := jsonutil.DecodeGraphQL(dec, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.User.Name != "Han" {
+		t.Errorf("got %+v, want the value after the invalid one", got)
+	}
+}
+
+func TestUnmarshalGraphQL_strict(t *testing.T) {
+	type characterFields struct {
+		Name graphql.String
+	}
+	type query struct {
+		Hero struct {
+			characterFields
+			Height    *graphql.Float
+			Friends   []struct{ Name graphql.String }
+			DroidInfo struct {
+				PrimaryFunction graphql.String
+			} `graphql:"... on Droid"`
+			Starships []struct{ Length graphql.Float } `graphql:"starships @include(if: $withStarships)"`
+		}
+	}
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			name: "complete",
+			data: `{"hero": {"name": "Luke", "friends": [{"name": "Han"}]}}`,
+		},
+		{
+			name: "missing field",
+			data: `{"hero": {"friends": []}}`,
+			want: `hero.name (Hero.characterFields.Name): non-nullable field is missing`,
+		},
+		{
+			name: "missing field in list",
+			data: `{"hero": {"name": "Luke", "friends": [{"name": "Han"}, {}]}}`,
+			want: `hero.friends[1].name (Hero.Friends.Name): non-nullable field is missing`,
+		},
+		{
+			name: "missing object",
+			data: `{}`,
+			want: `hero (Hero): non-nullable field is missing`,
+		},
+		{
+			name: "unknown field",
+			data: `{"hero": {"name": "Luke", "friends": [{"name": "Han", "age": 32}]}}`,
+			want: `hero.friends[0].age (Hero.Friends): struct field for "age" doesn't exist in any of 3 places to unmarshal`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got query
+			err := unmarshalGraphQL([]byte(tc.data), &got, jsonutil.Strict())
+			if tc.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.want {
+				t.Errorf("got error %v, want %s", err, tc.want)
+			}
+			// Missing fields are only reported in strict mode.
+			if err := unmarshalGraphQL([]byte(tc.data), &query{}); err != nil && tc.name != "unknown field" {
+				t.Errorf("got error %v in non-strict mode", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalGraphQL_decodeError(t *testing.T) {
+	type query struct {
+		Repository struct {
+			Issues struct {
+				Nodes []struct {
+					Number    graphql.Int
+					CreatedAt time.Time
+				}
+			}
+			DroidFields struct {
+				PrimaryFunction graphql.String
+			} `graphql:"... on Droid"`
+		}
+	}
+	tests := []struct {
+		data      string
+		wantPath  string
+		wantField string
+	}{
+		{
+			data:      `{"repository": {"issues": {"nodes": [{"number": 1, "createdAt": "2017-06-29T04:12:01Z"}, {"number": 2, "createdAt": "yesterday"}]}}}`,
+			wantPath:  "repository.issues.nodes[1].createdAt",
+			wantField: "Repository.Issues.Nodes.CreatedAt",
+		},
+		{
+			data:      `{"repository": {"primaryFunction": 42}}`,
+			wantPath:  "repository.primaryFunction",
+			wantField: "Repository.DroidFields.PrimaryFunction",
+		},
+		{
+			data:      `{"repository": {"issues": {"nodes": [{"number": 1}, {"number": }]}}}`,
+			wantPath:  "repository.issues.nodes[1].number",
+			wantField: "Repository.Issues.Nodes.Number",
+		},
+		{
+			data:      `{"repository": {"issues": true}}`,
+			wantPath:  "repository.issues",
+			wantField: "Repository.Issues",
+		},
+	}
+	for _, tc := range tests {
+		err := unmarshalGraphQL([]byte(tc.data), &query{})
+		var decodeErr *jsonutil.DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Errorf("got error %v, want a DecodeError", err)
+			continue
+		}
+		if decodeErr.Path != tc.wantPath || decodeErr.Field != tc.wantField {
+			t.Errorf("got error at %q (%s), want %q (%s)", decodeErr.Path, decodeErr.Field, tc.wantPath, tc.wantField)
+		}
+	}
+}
+
+type (
+	character interface{ isCharacter() }
+	human     struct {
+		Name   graphql.String
+		Height graphql.Float
+	}
+	droid struct {
+		Name            graphql.String
+		PrimaryFunction graphql.String
+	}
+)
+
+func (human) isCharacter()  {}
+func (*droid) isCharacter() {}
+
+var characterTypes = jsonutil.PossibleTypes(map[reflect.Type]map[string]reflect.Type{
+	reflect.TypeOf((*character)(nil)).Elem(): {
+		"Human": reflect.TypeOf(human{}),
+		"Droid": reflect.TypeOf(&droid{}),
+	},
+})
+
+func TestUnmarshalGraphQL_possibleTypes(t *testing.T) {
+	type query struct {
+		Hero    character
+		Friends []character
+		Villain *character
+		Name    graphql.String
+	}
+	var got query
+	err := unmarshalGraphQL([]byte(`{
+		"hero": {"__typename": "Droid", "name": "R2-D2", "primaryFunction": "Astromech"},
+		"friends": [
+			{"__typename": "Human", "name": "Luke Skywalker", "height": 1.72},
+			{"__typename": "Starship", "name": "Millennium Falcon"},
+			null
+		],
+		"villain": {"__typename": "Human", "name": "Darth Vader", "height": 2.02},
+		"name": "Star Wars"
+	}`), &got, characterTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	villain := character(human{Name: "Darth Vader", Height: 2.02})
+	want := query{
+		Hero:    &droid{Name: "R2-D2", PrimaryFunction: "Astromech"},
+		Friends: []character{human{Name: "Luke Skywalker", Height: 1.72}, nil, nil},
+		Villain: &villain,
+		Name:    "Star Wars",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%#v\nwant:\n%#v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_possibleTypesErrors(t *testing.T) {
+	type query struct {
+		Friends []character
+	}
+	tests := []struct {
+		data    string
+		opts    []jsonutil.Option
+		wantErr string
+	}{
+		{
+			data:    `{"friends": [{"__typename": "Human", "name": "Luke Skywalker", "height": "tall"}]}`,
+			wantErr: `friends[0].height (Friends.Height): json: cannot unmarshal string into Go value of type graphql.Float`,
+		},
+		{
+			data:    `{"friends": [{"name": "Luke Skywalker"}]}`,
+			wantErr: `friends[0] (Friends): __typename is missing, it's required to decode into jsonutil_test.character`,
+		},
+		{
+			data:    `{"friends": [{"__typename": "Starship"}]}`,
+			opts:    []jsonutil.Option{jsonutil.Strict()},
+			wantErr: `friends[0] (Friends): __typename "Starship" isn't a registered type of jsonutil_test.character`,
+		},
+		{
+			data:    `{"friends": [{"__typename": "Human", "name": "Luke Skywalker"}]}`,
+			opts:    []jsonutil.Option{jsonutil.Strict()},
+			wantErr: `friends[0].height (Friends.Height): non-nullable field is missing`,
+		},
+	}
+	for _, tc := range tests {
+		err := unmarshalGraphQL([]byte(tc.data), &query{}, append(tc.opts, characterTypes)...)
+		if err == nil || err.Error() != tc.wantErr {
+			t.Errorf("got error %v, want %s", err, tc.wantErr)
+		}
+	}
+}
+
+// episode is an enum.
+type episode string
+
+func (episode) GetGraphQLType() string { return "Episode" }
+func (episode) EnumValues() []string   { return []string{"NEWHOPE", "EMPIRE", "JEDI"} }
+
+func TestUnmarshalGraphQL_enum(t *testing.T) {
+	type query struct {
+		Hero struct {
+			AppearsIn []episode
+		}
+		Favorite *episode
+		Tagged   episode `scalar:"true"`
+		Missing  *episode
+	}
+	var got query
+	err := unmarshalGraphQL([]byte(`{
+		"hero": {"appearsIn": ["NEWHOPE", "JEDI"]},
+		"favorite": "EMPIRE",
+		"tagged": "JEDI",
+		"missing": null
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Hero.AppearsIn = []episode{"NEWHOPE", "JEDI"}
+	favorite := episode("EMPIRE")
+	want.Favorite = &favorite
+	want.Tagged = "JEDI"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+
+	err = unmarshalGraphQL([]byte(`{
+		"hero": {"appearsIn": ["NEWHOPE", "PHANTOM"]},
+		"favorite": null,
+		"tagged": null,
+		"missing": null
+	}`), &query{})
+	var decodeErr *jsonutil.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("got error %v, want a DecodeError", err)
+	}
+	if got, want := decodeErr.Path, "hero.appearsIn[1]"; got != want {
+		t.Errorf("got error at %q, want %q", got, want)
+	}
+	if got, want := decodeErr.Err.Error(), `"PHANTOM" isn't a value of enum Episode`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_optional(t *testing.T) {
+	type query struct {
+		Login    graphql.Optional[graphql.String]
+		Bio      graphql.Optional[graphql.String]
+		Location graphql.Optional[*graphql.String]
+		Company  graphql.Optional[struct{ Name graphql.String }]
+		Emails   []graphql.Optional[graphql.String]
+	}
+	var got query
+	err := unmarshalGraphQL([]byte(`{
+		"login": "gopher",
+		"location": null,
+		"company": {"name": "Go"},
+		"emails": ["gopher@example.com", null]
+	}`), &got, jsonutil.Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Login:    graphql.Optional[graphql.String]{Value: "gopher", Present: true},
+		Location: graphql.Optional[*graphql.String]{Present: true, Null: true},
+		Company:  graphql.Optional[struct{ Name graphql.String }]{Value: struct{ Name graphql.String }{"Go"}, Present: true},
+		Emails: []graphql.Optional[graphql.String]{
+			{Value: "gopher@example.com", Present: true},
+			{Present: true, Null: true},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+	if login, ok := got.Login.Get(); !ok || login != "gopher" {
+		t.Errorf("got login %q, %v, want gopher", login, ok)
+	}
+	for _, o := range []graphql.Optional[graphql.String]{got.Bio, got.Emails[1]} {
+		if _, ok := o.Get(); ok {
+			t.Errorf("got a value for %+v, want none", o)
+		}
+	}
+}
+
+func TestUnmarshalGraphQL_map(t *testing.T) {
+	type issue struct {
+		Title  graphql.String
+		Labels []graphql.String
+	}
+	type query struct {
+		Repository struct {
+			Issues map[string]*issue `graphql:"issues { first: issue(number: 1) { title, labels }, second: issue(number: 2) { title, labels }, third: issue(number: 3) { title, labels } }"`
+		}
+		Counts     map[string][]graphql.Int `graphql:"counts { stars: counts(of: STARS), forks: counts(of: FORKS) }"`
+		Characters []map[string]character   `graphql:"characters { hero: hero { __typename, name } }"`
+		Owners     map[string]struct{ Login graphql.String }
+	}
+	var got query
+	err := unmarshalGraphQL([]byte(`{
+		"repository": {"issues": {
+			"first": {"title": "Bug", "labels": ["bug"]},
+			"second": {"title": "Feature", "labels": []},
+			"third": null
+		}},
+		"counts": {"stars": [1, 2], "forks": []},
+		"characters": [{"hero": {"__typename": "Human", "name": "Luke Skywalker", "height": 1.72}}],
+		"owners": null
+	}`), &got, characterTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Repository.Issues = map[string]*issue{
+		"first":  {Title: "Bug", Labels: []graphql.String{"bug"}},
+		"second": {Title: "Feature", Labels: []graphql.String{}},
+		"third":  nil,
+	}
+	want.Counts = map[string][]graphql.Int{"stars": {1, 2}, "forks": {}}
+	want.Characters = []map[string]character{{"hero": human{Name: "Luke Skywalker", Height: 1.72}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_scalarDecoders(t *testing.T) {
+	// Points are received as [x, y] lists.
+	type point struct{ X, Y float64 }
+	decoders := map[reflect.Type]func(data []byte, v interface{}) error{
+		reflect.TypeOf(point{}): func(data []byte, v interface{}) error {
+			var xy [2]float64
+			if err := json.Unmarshal(data, &xy); err != nil {
+				return err
+			}
+			*v.(*point) = point{xy[0], xy[1]}
+			return nil
+		},
+	}
+	type query struct {
+		Location *point
+		Path     []point
+		Missing  *point
+	}
+	var got query
+	err := unmarshalGraphQL([]byte(`{
+		"location": [1, 2],
+		"path": [[0, 0], [3.5, -1]],
+		"missing": null
+	}`), &got, jsonutil.ScalarDecoders(decoders))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Location: &point{1, 2},
+		Path:     []point{{0, 0}, {3.5, -1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_mapErrors(t *testing.T) {
+	tests := []struct {
+		v       interface{}
+		data    string
+		opts    []jsonutil.Option
+		wantErr string
+	}{
+		{
+			v: &struct {
+				Issues map[string]struct{ Number graphql.Int }
+			}{},
+			data:    `{"issues": {"first": {"number": 1}, "second": {"number": "two"}}}`,
+			wantErr: `issues.second.number (Issues.Number): json: cannot unmarshal string into Go value of type graphql.Int`,
+		},
+		{
+			v: &struct {
+				Issues map[string]struct{ Number graphql.Int }
+			}{},
+			data:    `{"issues": {"first": {}}}`,
+			opts:    []jsonutil.Option{jsonutil.Strict()},
+			wantErr: `issues.first.number (Issues.Number): non-nullable field is missing`,
+		},
+		{
+			v: &struct {
+				Issues map[string]graphql.Int
+			}{},
+			data:    `{"issues": [1, 2]}`,
+			wantErr: `issues (Issues): cannot decode a non-object into map[string]graphql.Int`,
+		},
+		{
+			v: &struct {
+				Issues map[int]graphql.Int
+			}{},
+			data:    `{"issues": {"1": 1}}`,
+			wantErr: `issues (Issues): map key type must be a string, got int`,
+		},
+	}
+	for _, tc := range tests {
+		err := unmarshalGraphQL([]byte(tc.data), tc.v, tc.opts...)
+		if err == nil || err.Error() != tc.wantErr {
+			t.Errorf("got error %v, want %s", err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package jsonutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// OptionalField is embedded in the Optional type of the graphql package,
+// graphql.Optional[T], to mark it as optional for decoding. Its first three
+// fields are the value of type T, and whether the field was present and
+// null.
+type OptionalField struct{}
+
+func (*OptionalField) optional() {}
+
+var optionalType = reflect.TypeOf((*interface{ optional() })(nil)).Elem()
+
+var optionalCache sync.Map // map[reflect.Type]bool
+
+// IsOptional reports whether t is an Optional type, i.e. embeds
+// OptionalField, whose value type is the type of its first field.
+func IsOptional(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	if ok, cached := optionalCache.Load(t); cached {
+		return ok.(bool)
+	}
+	ok := reflect.PtrTo(t).Implements(optionalType)
+	optionalCache.Store(t, ok)
+	return ok
+}
+
+// unwrapOptionals records that the values on top of the stacks of d.vs that
+// are Optional are present, with the value tok, and replaces them with their
+// Value fields, so that tok is decoded into them. Null values are recorded,
+// and removed from the stacks, since there's nothing to decode into them.
+func (d *decoder) unwrapOptionals(tok json.Token) {
+	for i := range d.vs {
+		v := d.vs[i].Top()
+		if !v.IsValid() || !IsOptional(v.Type()) {
+			continue
+		}
+		v.Set(reflect.Zero(v.Type()))
+		v.Field(1).SetBool(true)
+		if isNull(tok) {
+			v.Field(2).SetBool(true)
+			d.vs[i][len(d.vs[i])-1] = reflect.Value{}
+			continue
+		}
+		d.vs[i][len(d.vs[i])-1] = v.Field(0)
+	}
+}
+
+// isNull reports whether tok is a JSON null, read either as a token or as a
+// raw value.
+func isNull(tok json.Token) bool {
+	if raw, ok := tok.(json.RawMessage); ok {
+		return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+	}
+	return tok == nil
+}
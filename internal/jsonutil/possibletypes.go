@@ -0,0 +1,61 @@
+package jsonutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// PossibleTypes makes decoding decode the values of the Go interface types
+// of types, which stand for GraphQL unions or interfaces, into a new value
+// of the concrete type registered for the __typename of their JSON object,
+// or leave them nil if no type matches.
+func PossibleTypes(types map[reflect.Type]map[string]reflect.Type) Option {
+	return func(d *decoder) {
+		if len(types) > 0 {
+			d.possibleTypes = types
+		}
+	}
+}
+
+// decodePossibleTypes decodes the JSON value data into v, an interface with
+// possible types, choosing the concrete type of the object by its
+// __typename. Errors are reported with path, the JSON path of data relative
+// to the value being decoded by d.
+func (d *decoder) decodePossibleTypes(data json.RawMessage, v reflect.Value, path string) error {
+	t := v.Type()
+	var object struct {
+		Typename *string `json:"__typename"`
+	}
+	if err := d.codec.Unmarshal(data, &object); err != nil {
+		return &DecodeError{Path: path, Err: err}
+	}
+	if object.Typename == nil {
+		return &DecodeError{Path: path, Err: fmt.Errorf("__typename is missing, it's required to decode into %v", t)}
+	}
+	concrete, ok := d.possibleTypes[t][*object.Typename]
+	if !ok {
+		if d.strict {
+			return &DecodeError{Path: path, Err: fmt.Errorf("__typename %q isn't a registered type of %v", *object.Typename, t)}
+		}
+		// The server may return types unknown to the client, e.g. new
+		// members of a union.
+		v.Set(reflect.Zero(t))
+		return nil
+	}
+	value := reflect.New(concrete).Elem()
+	if err := d.decodeSub(data, value, path, true); err != nil {
+		return err
+	}
+	v.Set(value)
+	return nil
+}
+
+// joinPath returns the JSON path of the value at path rel relative to the
+// value at path.
+func joinPath(path, rel string) string {
+	if path == "" || rel == "" || rel[0] == '[' {
+		return path + rel
+	}
+	return path + "." + rel
+}
@@ -0,0 +1,170 @@
+package jsonutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// isDeferred reports whether values of type t are decoded whole, once their
+// JSON value has been read, rather than token by token: maps, whose values
+// aren't addressable, interfaces with possible types, whose concrete type
+// isn't known before their __typename, and scalars with a decoder, or
+// pointers to, slices of or maps of them.
+func (d *decoder) isDeferred(t reflect.Type) bool {
+	for {
+		if _, ok := d.scalars[t]; ok {
+			return true
+		}
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice:
+			t = t.Elem()
+		case reflect.Map:
+			return true
+		case reflect.Interface:
+			_, ok := d.possibleTypes[t]
+			return ok
+		default:
+			return false
+		}
+	}
+}
+
+// hasScalarDecoder reports whether t is a scalar with a decoder, or a pointer
+// to or a slice of them.
+func (d *decoder) hasScalarDecoder(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		if _, ok := d.scalars[t]; ok {
+			return true
+		}
+		t = t.Elem()
+	}
+	_, ok := d.scalars[t]
+	return ok
+}
+
+// isDeferredField reports whether the value of field, of type t, is deferred.
+// The fields with a scalar tag are only deferred if they have a scalar
+// decoder, to be decoded with it.
+func (d *decoder) isDeferredField(field field, t reflect.Type) bool {
+	if field.scalar {
+		return d.scalars != nil && d.hasScalarDecoder(t)
+	}
+	return (field.deferrable || d.scalars != nil) && d.isDeferred(t)
+}
+
+// decodeRaw decodes the JSON value data into v, whose type is deferred (see
+// isDeferred). Errors are reported with path, the JSON path of data relative
+// to the value being decoded by d.
+func (d *decoder) decodeRaw(data json.RawMessage, v reflect.Value, path string) error {
+	t := v.Type()
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		v.Set(reflect.Zero(t))
+		return nil
+	}
+	if decode, ok := d.scalars[t]; ok {
+		p := reflect.New(t)
+		if err := decode(data, p.Interface()); err != nil {
+			return &DecodeError{Path: path, Err: err}
+		}
+		v.Set(p.Elem())
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(t.Elem())
+		if err := d.decodeRaw(data, p.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := d.codec.Unmarshal(data, &items); err != nil {
+			return &DecodeError{Path: path, Err: err}
+		}
+		s := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := d.decodeRaw(item, s.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Map:
+		return d.decodeMap(data, v, path)
+	case reflect.Interface:
+		if _, ok := d.possibleTypes[t]; ok {
+			return d.decodePossibleTypes(data, v, path)
+		}
+	}
+	return d.decodeSub(data, v, path, false)
+}
+
+// decodeMap decodes the JSON object data into v, a map with string keys,
+// decoding each value as the query type of the map values.
+func (d *decoder) decodeMap(data json.RawMessage, v reflect.Value, path string) error {
+	t := v.Type()
+	if t.Key().Kind() != reflect.String {
+		return &DecodeError{Path: path, Err: fmt.Errorf("map key type must be a string, got %v", t.Key())}
+	}
+	dec := d.codec.NewTokenizer(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return &DecodeError{Path: path, Err: fmt.Errorf("cannot decode a non-object into %v", t)}
+	}
+	m := reflect.MakeMap(t)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return &DecodeError{Path: path, Err: err}
+		}
+		key := tok.(string)
+		var item json.RawMessage
+		if err := dec.Decode(&item); err != nil {
+			return &DecodeError{Path: joinPath(path, key), Err: err}
+		}
+		value := reflect.New(t.Elem()).Elem()
+		if d.isDeferred(t.Elem()) {
+			err = d.decodeRaw(item, value, joinPath(path, key))
+		} else {
+			err = d.decodeSub(item, value, joinPath(path, key), false)
+		}
+		if err != nil {
+			return err
+		}
+		m.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), value)
+	}
+	v.Set(m)
+	return nil
+}
+
+// decodeSub decodes the JSON value data into v with a new decoder, with the
+// options of d. If ignoreTypename is true, a __typename key is skipped if v
+// has no field for it.
+func (d *decoder) decodeSub(data json.RawMessage, v reflect.Value, path string, ignoreTypename bool) error {
+	if v.Kind() == reflect.Interface {
+		// An interface without possible types holds the JSON value itself.
+		if err := unmarshalValue(data, v); err != nil {
+			return &DecodeError{Path: path, Err: err}
+		}
+		return nil
+	}
+	sub := &decoder{
+		tokenizer:      d.codec.NewTokenizer(bytes.NewReader(data)),
+		codec:          d.codec,
+		scalars:        d.scalars,
+		possibleTypes:  d.possibleTypes,
+		strict:         d.strict,
+		ignoreTypename: ignoreTypename || d.ignoreTypename,
+	}
+	if err := sub.Decode(v.Addr().Interface()); err != nil {
+		if decodeErr, ok := err.(*DecodeError); ok {
+			decodeErr.Path = joinPath(path, decodeErr.Path)
+			return decodeErr
+		}
+		return &DecodeError{Path: path, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// Package language implements a parser and a printer for GraphQL executable
+// documents, i.e. operations and fragment definitions.
+package language
+
+// Document is a parsed GraphQL executable document.
+type Document struct {
+	Operations []*OperationDefinition
+	Fragments  []*FragmentDefinition
+}
+
+// Fragment returns the fragment definition with the given name, or nil.
+func (d *Document) Fragment(name string) *FragmentDefinition {
+	for _, f := range d.Fragments {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Position is the location of a node in the source, starting at line 1, column 1.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// OperationDefinition is a query, mutation or subscription.
+type OperationDefinition struct {
+	// Operation is "query", "mutation" or "subscription".
+	Operation           string
+	Name                string
+	VariableDefinitions []*VariableDefinition
+	Directives          []*Directive
+	SelectionSet        []Selection
+	Position            Position
+}
+
+// FragmentDefinition is a named fragment definition.
+type FragmentDefinition struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Position      Position
+}
+
+// VariableDefinition is the definition of an operation variable.
+type VariableDefinition struct {
+	Name         string
+	Type         *Type
+	DefaultValue *Value
+	Directives   []*Directive
+	Position     Position
+}
+
+// Type is a GraphQL type reference. It is a list type if Elem is not nil,
+// and a named type otherwise.
+type Type struct {
+	Name    string
+	Elem    *Type
+	NonNull bool
+}
+
+// String returns the GraphQL syntax of t, e.g. "[Int!]!".
+func (t *Type) String() string {
+	var s string
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	} else {
+		s = t.Name
+	}
+	if t.NonNull {
+		s += "!"
+	}
+	return s
+}
+
+// Directive is a directive applied to a node, e.g. "@include(if: $flag)".
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+	Position  Position
+}
+
+// Argument is a named argument of a field or directive.
+type Argument struct {
+	Name     string
+	Value    *Value
+	Position Position
+}
+
+// ValueKind is the kind of a Value.
+type ValueKind int
+
+const (
+	VariableValue ValueKind = iota
+	IntValue
+	FloatValue
+	StringValue
+	BooleanValue
+	NullValue
+	EnumValue
+	ListValue
+	ObjectValue
+)
+
+// Value is an input value literal.
+type Value struct {
+	Kind ValueKind
+	// Raw holds the variable name, the number, the decoded string, the enum
+	// value, "true", "false" or "null", depending on Kind.
+	Raw string
+	// List holds the items of a ListValue.
+	List []*Value
+	// Fields holds the fields of an ObjectValue.
+	Fields   []*ObjectField
+	Position Position
+}
+
+// ObjectField is a field of an input object literal.
+type ObjectField struct {
+	Name  string
+	Value *Value
+}
+
+// Selection is a *Field, a *FragmentSpread or an *InlineFragment.
+type Selection interface {
+	selection()
+}
+
+// Field is a field selection.
+type Field struct {
+	Alias        string
+	Name         string
+	Arguments    []*Argument
+	Directives   []*Directive
+	SelectionSet []Selection
+	Position     Position
+}
+
+// ResponseKey returns the alias of f if any, or its name.
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread is a spread of a named fragment, e.g. "...UserFields".
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+	Position   Position
+}
+
+// InlineFragment is an inline fragment, e.g. "... on User{name}".
+// TypeCondition is empty if the fragment has no type condition.
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Position      Position
+}
+
+func (*Field) selection()          {}
+func (*FragmentSpread) selection() {}
+func (*InlineFragment) selection() {}
@@ -0,0 +1,324 @@
+package language
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of document"
+	case tokenPunctuator:
+		return "punctuator"
+	case tokenName:
+		return "name"
+	case tokenInt:
+		return "int"
+	case tokenFloat:
+		return "float"
+	case tokenString:
+		return "string"
+	}
+	return "unknown token"
+}
+
+// token is a lexical token. The value of a string token is decoded.
+type token struct {
+	kind  tokenKind
+	value string
+	pos   Position
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return t.kind.String()
+	case tokenString:
+		return strconv.Quote(t.value)
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// SyntaxError is returned when a document can't be parsed.
+type SyntaxError struct {
+	Message  string
+	Position Position
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("graphql: syntax error at %d:%d: %s", e.Position.Line, e.Position.Column, e.Message)
+}
+
+// lexer splits a GraphQL document into tokens, skipping whitespace, commas
+// and comments.
+type lexer struct {
+	src       string
+	offset    int
+	line      int
+	lineStart int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: strings.TrimPrefix(src, "\ufeff"), line: 1}
+}
+
+func (l *lexer) pos() Position {
+	return Position{Line: l.line, Column: l.offset - l.lineStart + 1}
+}
+
+func (l *lexer) errorf(pos Position, format string, args ...interface{}) error {
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Position: pos}
+}
+
+func (l *lexer) newline() {
+	l.line++
+	l.lineStart = l.offset
+}
+
+// skipIgnored skips whitespace, line terminators, commas and comments.
+func (l *lexer) skipIgnored() {
+	for l.offset < len(l.src) {
+		switch c := l.src[l.offset]; c {
+		case ' ', '\t', ',':
+			l.offset++
+		case '\n':
+			l.offset++
+			l.newline()
+		case '\r':
+			l.offset++
+			if l.offset < len(l.src) && l.src[l.offset] == '\n' {
+				l.offset++
+			}
+			l.newline()
+		case '#':
+			for l.offset < len(l.src) && l.src[l.offset] != '\n' && l.src[l.offset] != '\r' {
+				l.offset++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next token.
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	pos := l.pos()
+	if l.offset >= len(l.src) {
+		return token{kind: tokenEOF, pos: pos}, nil
+	}
+	c := l.src[l.offset]
+	switch {
+	case strings.IndexByte("!$&()/:=@[]{}|", c) != -1:
+		l.offset++
+		return token{kind: tokenPunctuator, value: string(c), pos: pos}, nil
+	case c == '.':
+		if strings.HasPrefix(l.src[l.offset:], "...") {
+			l.offset += 3
+			return token{kind: tokenPunctuator, value: "...", pos: pos}, nil
+		}
+		return token{}, l.errorf(pos, "unexpected character %q", c)
+	case isNameStart(c):
+		start := l.offset
+		for l.offset < len(l.src) && isNameContinue(l.src[l.offset]) {
+			l.offset++
+		}
+		return token{kind: tokenName, value: l.src[start:l.offset], pos: pos}, nil
+	case c == '-' || isDigit(c):
+		return l.number(pos)
+	case c == '"':
+		if strings.HasPrefix(l.src[l.offset:], `"""`) {
+			return l.blockString(pos)
+		}
+		return l.string(pos)
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+	return token{}, l.errorf(pos, "unexpected character %q", r)
+}
+
+func (l *lexer) number(pos Position) (token, error) {
+	start := l.offset
+	if l.src[l.offset] == '-' {
+		l.offset++
+	}
+	intStart := l.offset
+	if !l.digits() {
+		return token{}, l.errorf(pos, "invalid number %q", l.src[start:l.offset])
+	}
+	if l.src[intStart] == '0' && l.offset-intStart > 1 {
+		return token{}, l.errorf(pos, "invalid number %q: unexpected leading zero", l.src[start:l.offset])
+	}
+	kind := tokenInt
+	if l.offset < len(l.src) && l.src[l.offset] == '.' {
+		kind = tokenFloat
+		l.offset++
+		if !l.digits() {
+			return token{}, l.errorf(pos, "invalid number %q", l.src[start:l.offset])
+		}
+	}
+	if l.offset < len(l.src) && (l.src[l.offset] == 'e' || l.src[l.offset] == 'E') {
+		kind = tokenFloat
+		l.offset++
+		if l.offset < len(l.src) && (l.src[l.offset] == '+' || l.src[l.offset] == '-') {
+			l.offset++
+		}
+		if !l.digits() {
+			return token{}, l.errorf(pos, "invalid number %q", l.src[start:l.offset])
+		}
+	}
+	if l.offset < len(l.src) && (isNameStart(l.src[l.offset]) || l.src[l.offset] == '.') {
+		return token{}, l.errorf(pos, "invalid number %q", l.src[start:l.offset+1])
+	}
+	return token{kind: kind, value: l.src[start:l.offset], pos: pos}, nil
+}
+
+// digits consumes a sequence of digits and reports whether it was non-empty.
+func (l *lexer) digits() bool {
+	start := l.offset
+	for l.offset < len(l.src) && isDigit(l.src[l.offset]) {
+		l.offset++
+	}
+	return l.offset > start
+}
+
+func (l *lexer) string(pos Position) (token, error) {
+	l.offset++ // opening quote
+	var b strings.Builder
+	for {
+		if l.offset >= len(l.src) {
+			return token{}, l.errorf(pos, "unterminated string")
+		}
+		c := l.src[l.offset]
+		switch c {
+		case '"':
+			l.offset++
+			return token{kind: tokenString, value: b.String(), pos: pos}, nil
+		case '\n', '\r':
+			return token{}, l.errorf(pos, "unterminated string")
+		case '\\':
+			if l.offset+1 >= len(l.src) {
+				return token{}, l.errorf(pos, "unterminated string")
+			}
+			esc := l.src[l.offset+1]
+			l.offset += 2
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.offset+4 > len(l.src) {
+					return token{}, l.errorf(pos, "invalid unicode escape sequence")
+				}
+				n, err := strconv.ParseUint(l.src[l.offset:l.offset+4], 16, 32)
+				if err != nil {
+					return token{}, l.errorf(pos, "invalid unicode escape sequence %q", l.src[l.offset-2:l.offset+4])
+				}
+				l.offset += 4
+				b.WriteRune(rune(n))
+			default:
+				return token{}, l.errorf(pos, "invalid escape sequence \\%c", esc)
+			}
+		default:
+			b.WriteByte(c)
+			l.offset++
+		}
+	}
+}
+
+func (l *lexer) blockString(pos Position) (token, error) {
+	l.offset += 3 // opening quotes
+	var b strings.Builder
+	for {
+		if l.offset >= len(l.src) {
+			return token{}, l.errorf(pos, "unterminated block string")
+		}
+		rest := l.src[l.offset:]
+		switch {
+		case strings.HasPrefix(rest, `"""`):
+			l.offset += 3
+			return token{kind: tokenString, value: blockStringValue(b.String()), pos: pos}, nil
+		case strings.HasPrefix(rest, `\"""`):
+			b.WriteString(`"""`)
+			l.offset += 4
+		case rest[0] == '\n':
+			b.WriteByte('\n')
+			l.offset++
+			l.newline()
+		case rest[0] == '\r':
+			b.WriteByte('\n')
+			l.offset++
+			if l.offset < len(l.src) && l.src[l.offset] == '\n' {
+				l.offset++
+			}
+			l.newline()
+		default:
+			b.WriteByte(rest[0])
+			l.offset++
+		}
+	}
+}
+
+// blockStringValue removes the common indentation and the leading and
+// trailing blank lines of a block string, as specified by
+// https://spec.graphql.org/June2018/#BlockStringValue().
+func blockStringValue(raw string) string {
+	lines := strings.Split(raw, "\n")
+	common := -1
+	for _, line := range lines[1:] {
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < len(line) && (common == -1 || indent < common) {
+			common = indent
+		}
+	}
+	if common > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= common {
+				lines[i] = lines[i][common:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNameContinue(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
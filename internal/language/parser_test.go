@@ -0,0 +1,97 @@
+package language
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(`
+		query Q($id: ID!, $ids: [ID!] = ["a"]) @live {
+			node(id: $id) {
+				__typename
+				... on User { name }
+				...Extra @include(if: true)
+			}
+			alias: nodes(ids: $ids)
+		}
+		fragment Extra on Node { id }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(doc.Operations), 1; got != want {
+		t.Fatalf("got %d operations, want %d", got, want)
+	}
+	op := doc.Operations[0]
+	if op.Operation != "query" || op.Name != "Q" {
+		t.Errorf("got operation %s %s, want query Q", op.Operation, op.Name)
+	}
+	if got, want := op.VariableDefinitions[1].Type.String(), "[ID!]"; got != want {
+		t.Errorf("got type %s, want %s", got, want)
+	}
+	if got, want := op.VariableDefinitions[1].DefaultValue.List[0].Raw, "a"; got != want {
+		t.Errorf("got default value %q, want %q", got, want)
+	}
+	node := op.SelectionSet[0].(*Field)
+	if got, want := node.Position, (Position{Line: 3, Column: 4}); got != want {
+		t.Errorf("got position %v, want %v", got, want)
+	}
+	if got, want := node.SelectionSet[1].(*InlineFragment).TypeCondition, "User"; got != want {
+		t.Errorf("got type condition %q, want %q", got, want)
+	}
+	if got, want := node.SelectionSet[2].(*FragmentSpread).Name, "Extra"; got != want {
+		t.Errorf("got fragment spread %q, want %q", got, want)
+	}
+	if got, want := op.SelectionSet[1].(*Field).ResponseKey(), "alias"; got != want {
+		t.Errorf("got response key %q, want %q", got, want)
+	}
+	if doc.Fragment("Extra") == nil {
+		t.Error("fragment Extra not found")
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{``, `graphql: syntax error at 1:1: empty document`},
+		{`{a`, `graphql: syntax error at 1:3: expected name, got end of document`},
+		{`{a(b: $c d: 1.)}`, `graphql: syntax error at 1:13: invalid number "1."`},
+		{`query($a: Int = $b) {a}`, `graphql: syntax error at 1:17: unexpected variable in constant value`},
+		{"{a(b: \"c\n\")}", `graphql: syntax error at 1:7: unterminated string`},
+		{`type Query {a: Int}`, `graphql: syntax error at 1:1: unexpected "type"`},
+	}
+	for _, tc := range tests {
+		_, err := Parse(tc.in)
+		if err == nil {
+			t.Errorf("Parse(%q): got nil error", tc.in)
+			continue
+		}
+		if got := err.Error(); got != tc.want {
+			t.Errorf("Parse(%q):\ngot:  %s\nwant: %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	const src = `subscription OnEvent($kinds:[Kind!]=[A B]){event(kinds:$kinds){id...on Commit{sha}}}`
+	doc, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Print(doc, ""); got != src {
+		t.Errorf("\ngot:  %s\nwant: %s", got, src)
+	}
+	want := `subscription OnEvent($kinds: [Kind!] = [A, B]) {
+  event(kinds: $kinds) {
+    id
+    ... on Commit {
+      sha
+    }
+  }
+}`
+	if got := Print(doc, "  "); got != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,377 @@
+package language
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Print returns the GraphQL source of doc.
+//
+// If indent is empty, the document is minified: tokens are only separated
+// where needed, and ignored tokens such as commas and comments are left out.
+// Otherwise, the document is printed over multiple lines, with each level
+// of selection sets indented by indent and definitions separated by a
+// blank line.
+func Print(doc *Document, indent string) string {
+	p := &printer{indent: indent}
+	for _, op := range doc.Operations {
+		p.definitionSeparator()
+		p.operation(op)
+	}
+	for _, f := range doc.Fragments {
+		p.definitionSeparator()
+		p.fragmentDefinition(f)
+	}
+	return p.buf.String()
+}
+
+// Sort puts doc in canonical order, so that documents that only differ by
+// the order of their fragment definitions, variable definitions,
+// arguments or input object fields print the same. Operations, selections
+// and directives are left in order, since their order is significant.
+func Sort(doc *Document) {
+	sort.SliceStable(doc.Fragments, func(i, j int) bool {
+		return doc.Fragments[i].Name < doc.Fragments[j].Name
+	})
+	for _, op := range doc.Operations {
+		sort.SliceStable(op.VariableDefinitions, func(i, j int) bool {
+			return op.VariableDefinitions[i].Name < op.VariableDefinitions[j].Name
+		})
+		for _, def := range op.VariableDefinitions {
+			sortValue(def.DefaultValue)
+			sortDirectives(def.Directives)
+		}
+		sortDirectives(op.Directives)
+		sortSelections(op.SelectionSet)
+	}
+	for _, f := range doc.Fragments {
+		sortDirectives(f.Directives)
+		sortSelections(f.SelectionSet)
+	}
+}
+
+func sortSelections(selections []Selection) {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *Field:
+			sortArguments(sel.Arguments)
+			sortDirectives(sel.Directives)
+			sortSelections(sel.SelectionSet)
+		case *FragmentSpread:
+			sortDirectives(sel.Directives)
+		case *InlineFragment:
+			sortDirectives(sel.Directives)
+			sortSelections(sel.SelectionSet)
+		}
+	}
+}
+
+// sortDirectives sorts the arguments of directives, but not the directives
+// themselves, since their order may be significant.
+func sortDirectives(directives []*Directive) {
+	for _, d := range directives {
+		sortArguments(d.Arguments)
+	}
+}
+
+func sortArguments(args []*Argument) {
+	sort.SliceStable(args, func(i, j int) bool {
+		return args[i].Name < args[j].Name
+	})
+	for _, arg := range args {
+		sortValue(arg.Value)
+	}
+}
+
+func sortValue(v *Value) {
+	if v == nil {
+		return
+	}
+	for _, item := range v.List {
+		sortValue(item)
+	}
+	sort.SliceStable(v.Fields, func(i, j int) bool {
+		return v.Fields[i].Name < v.Fields[j].Name
+	})
+	for _, f := range v.Fields {
+		sortValue(f.Value)
+	}
+}
+
+// FieldKey returns the source of f without its selection set, on a single
+// line, as written in a graphql struct tag, e.g. "repo: repository(name: $name)".
+func FieldKey(f *Field) string {
+	p := &printer{indent: " "}
+	key := *f
+	key.SelectionSet = nil
+	p.field(&key)
+	return p.buf.String()
+}
+
+// InlineFragmentKey returns the source of f without its selection set, on a
+// single line, as written in a graphql struct tag, e.g. "... on Droid".
+func InlineFragmentKey(f *InlineFragment) string {
+	p := &printer{indent: " "}
+	p.token("...")
+	if f.TypeCondition != "" {
+		p.buf.WriteString(" on ")
+		p.token(f.TypeCondition)
+	}
+	p.directives(f.Directives)
+	return p.buf.String()
+}
+
+// DirectivesString returns the source of directives on a single line,
+// e.g. "@include(if: $flag) @skip(if: false)".
+func DirectivesString(directives []*Directive) string {
+	p := &printer{indent: " "}
+	p.directives(directives)
+	return strings.TrimPrefix(p.buf.String(), " ")
+}
+
+// ValueString returns the source of v on a single line, e.g. `{first: 10, after: "x"}`.
+func ValueString(v *Value) string {
+	p := &printer{indent: " "}
+	p.value(v)
+	return p.buf.String()
+}
+
+type printer struct {
+	buf    strings.Builder
+	indent string
+	depth  int
+}
+
+func (p *printer) pretty() bool {
+	return p.indent != ""
+}
+
+// token writes the token s. In minified output, a space is inserted if
+// s would otherwise merge with the previous token.
+func (p *printer) token(s string) {
+	if !p.pretty() && p.buf.Len() > 0 && s != "" {
+		last := p.buf.String()[p.buf.Len()-1]
+		if isNameContinue(last) && isNameContinue(s[0]) {
+			p.buf.WriteByte(' ')
+		}
+	}
+	p.buf.WriteString(s)
+}
+
+// space writes a space in pretty output.
+func (p *printer) space() {
+	if p.pretty() {
+		p.buf.WriteByte(' ')
+	}
+}
+
+// separator writes the separator of list items, such as arguments, in pretty output.
+func (p *printer) separator() {
+	if p.pretty() {
+		p.buf.WriteString(", ")
+	}
+}
+
+// newline starts a new indented line in pretty output.
+func (p *printer) newline() {
+	if p.pretty() {
+		p.buf.WriteByte('\n')
+		p.buf.WriteString(strings.Repeat(p.indent, p.depth))
+	}
+}
+
+func (p *printer) definitionSeparator() {
+	if p.pretty() && p.buf.Len() > 0 {
+		p.buf.WriteString("\n\n")
+	}
+}
+
+func (p *printer) operation(op *OperationDefinition) {
+	if op.Operation == "query" && op.Name == "" && len(op.VariableDefinitions) == 0 && len(op.Directives) == 0 {
+		p.selectionSet(op.SelectionSet)
+		return
+	}
+	p.token(op.Operation)
+	if op.Name != "" {
+		p.buf.WriteByte(' ')
+		p.token(op.Name)
+	}
+	if len(op.VariableDefinitions) > 0 {
+		p.token("(")
+		for i, def := range op.VariableDefinitions {
+			if i > 0 {
+				p.separator()
+			}
+			p.token("$")
+			p.token(def.Name)
+			p.token(":")
+			p.space()
+			p.token(def.Type.String())
+			if def.DefaultValue != nil {
+				p.space()
+				p.token("=")
+				p.space()
+				p.value(def.DefaultValue)
+			}
+			p.directives(def.Directives)
+		}
+		p.token(")")
+	}
+	p.directives(op.Directives)
+	p.space()
+	p.selectionSet(op.SelectionSet)
+}
+
+func (p *printer) fragmentDefinition(f *FragmentDefinition) {
+	p.token("fragment")
+	p.buf.WriteByte(' ')
+	p.token(f.Name)
+	p.buf.WriteByte(' ')
+	p.token("on")
+	p.buf.WriteByte(' ')
+	p.token(f.TypeCondition)
+	p.directives(f.Directives)
+	p.space()
+	p.selectionSet(f.SelectionSet)
+}
+
+func (p *printer) directives(directives []*Directive) {
+	for _, d := range directives {
+		p.space()
+		p.token("@")
+		p.token(d.Name)
+		p.arguments(d.Arguments)
+	}
+}
+
+func (p *printer) arguments(args []*Argument) {
+	if len(args) == 0 {
+		return
+	}
+	p.token("(")
+	for i, arg := range args {
+		if i > 0 {
+			p.separator()
+		}
+		p.token(arg.Name)
+		p.token(":")
+		p.space()
+		p.value(arg.Value)
+	}
+	p.token(")")
+}
+
+func (p *printer) selectionSet(selections []Selection) {
+	p.token("{")
+	if len(selections) == 0 {
+		p.token("}")
+		return
+	}
+	p.depth++
+	for _, sel := range selections {
+		p.newline()
+		switch sel := sel.(type) {
+		case *Field:
+			p.field(sel)
+		case *FragmentSpread:
+			p.token("...")
+			p.token(sel.Name)
+			p.directives(sel.Directives)
+		case *InlineFragment:
+			p.token("...")
+			if sel.TypeCondition != "" {
+				p.space()
+				p.token("on")
+				p.buf.WriteByte(' ')
+				p.token(sel.TypeCondition)
+			}
+			p.directives(sel.Directives)
+			p.space()
+			p.selectionSet(sel.SelectionSet)
+		}
+	}
+	p.depth--
+	p.newline()
+	p.token("}")
+}
+
+func (p *printer) field(f *Field) {
+	if f.Alias != "" {
+		p.token(f.Alias)
+		p.token(":")
+		p.space()
+	}
+	p.token(f.Name)
+	p.arguments(f.Arguments)
+	p.directives(f.Directives)
+	if f.SelectionSet != nil {
+		p.space()
+		p.selectionSet(f.SelectionSet)
+	}
+}
+
+func (p *printer) value(v *Value) {
+	switch v.Kind {
+	case VariableValue:
+		p.token("$")
+		p.token(v.Raw)
+	case StringValue:
+		p.token(quote(v.Raw))
+	case ListValue:
+		p.token("[")
+		for i, item := range v.List {
+			if i > 0 {
+				p.separator()
+			}
+			p.value(item)
+		}
+		p.token("]")
+	case ObjectValue:
+		p.token("{")
+		for i, f := range v.Fields {
+			if i > 0 {
+				p.separator()
+			}
+			p.token(f.Name)
+			p.token(":")
+			p.space()
+			p.value(f.Value)
+		}
+		p.token("}")
+	default:
+		p.token(v.Raw)
+	}
+}
+
+// quote returns s as a GraphQL string literal.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,106 @@
+// Package selection implements the dynamically built selection sets of
+// graphql.Selection, which is a defined type of Set, so that the response
+// decoder finds where their fields are decoded into without graphql.Selection
+// exporting methods for it.
+package selection
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Set is a dynamically built selection set, whose fields are kept in the
+// order they were added.
+type Set struct {
+	fields []*Field
+}
+
+// Field is a field or a fragment of a Set.
+type Field struct {
+	// Key uses the syntax of the graphql struct tag, e.g. "repo: repository(name: $name)".
+	// It's empty for named fragment spreads.
+	Key string
+	// Target is a pointer to the value the field is decoded into, or a
+	// pointer to a selection set.
+	Target interface{}
+	// Fragment reports whether the field is an inline fragment or a named fragment spread.
+	Fragment bool
+}
+
+var setType = reflect.TypeOf(Set{})
+
+// Of returns the selection set of v, if v is an addressable value of a type
+// defined as Set, such as graphql.Selection.
+func Of(v reflect.Value) (*Set, bool) {
+	if !v.IsValid() || v.Kind() != reflect.Struct || !v.CanAddr() || !v.Type().ConvertibleTo(setType) {
+		return nil, false
+	}
+	return v.Addr().Convert(reflect.PtrTo(setType)).Interface().(*Set), true
+}
+
+// Append appends f to s.
+func (s *Set) Append(f Field) {
+	s.fields = append(s.fields, &f)
+}
+
+// Fields returns the fields and fragments of s, in the order they were added.
+func (s *Set) Fields() []*Field {
+	return s.fields
+}
+
+// Field returns the pointer the field with the given response key is decoded
+// into, or nil if there is no such field.
+func (s *Set) Field(key string) interface{} {
+	for _, f := range s.fields {
+		if !f.Fragment && ResponseKey(f.Key) == key {
+			return f.Target
+		}
+	}
+	return nil
+}
+
+// Fragments returns the pointers the fragments of s are decoded into.
+func (s *Set) Fragments() []interface{} {
+	var targets []interface{}
+	for _, f := range s.fields {
+		if f.Fragment {
+			targets = append(targets, f.Target)
+		}
+	}
+	return targets
+}
+
+// Clone returns a copy of s whose fields are decoded into new values,
+// initialized with copies of the current ones, so that each item of a list
+// is decoded into its own values.
+func (s *Set) Clone() *Set {
+	clone := &Set{fields: make([]*Field, len(s.fields))}
+	for i, f := range s.fields {
+		cf := *f
+		if v := reflect.ValueOf(f.Target); v.Kind() == reflect.Ptr && !v.IsNil() {
+			if sub, ok := Of(v.Elem()); ok {
+				cf.Target = reflect.ValueOf(sub.Clone()).Convert(v.Type()).Interface()
+			} else {
+				nv := reflect.New(v.Type().Elem())
+				nv.Elem().Set(v.Elem())
+				cf.Target = nv.Interface()
+			}
+		}
+		clone.fields[i] = &cf
+	}
+	return clone
+}
+
+// ResponseKey returns the key of a field in the response, given its
+// graphql tag syntax: the alias if any, or the field name.
+//
+// E.g., "repo: repository(name: $name)" -> "repo".
+func ResponseKey(key string) string {
+	if i := strings.IndexAny(key, "(@{"); i != -1 {
+		key = key[:i]
+	}
+	if i := strings.Index(key, ":"); i != -1 {
+		key = key[:i]
+	}
+	return strings.TrimSpace(key)
+}
@@ -0,0 +1,23 @@
+package selection
+
+import (
+	"testing"
+)
+
+func TestResponseKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"login", "login"},
+		{"repo: repository(name: $name)", "repo"},
+		{"repo:repository", "repo"},
+		{"avatarUrl(size: 72)", "avatarUrl"},
+		{"name @include(if: $withName)", "name"},
+	}
+	for _, tc := range tests {
+		if got := ResponseKey(tc.in); got != tc.want {
+			t.Errorf("ResponseKey(%q): got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
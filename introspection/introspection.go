@@ -0,0 +1,284 @@
+// Package introspection provides a model of the GraphQL introspection
+// result, as returned by the introspection Query.
+package introspection
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Query is the introspection query, fetching the whole schema.
+const Query = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives {
+      name
+      description
+      locations
+      args { ...InputValue }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  description
+  fields(includeDeprecated: true) {
+    name
+    description
+    args { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated
+    deprecationReason
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) {
+    name
+    description
+    isDeprecated
+    deprecationReason
+  }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+          ofType {
+            kind
+            name
+            ofType {
+              kind
+              name
+              ofType {
+                kind
+                name
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// TypeKind is the kind of a GraphQL type.
+type TypeKind string
+
+const (
+	Scalar      TypeKind = "SCALAR"
+	Object      TypeKind = "OBJECT"
+	Interface   TypeKind = "INTERFACE"
+	Union       TypeKind = "UNION"
+	Enum        TypeKind = "ENUM"
+	InputObject TypeKind = "INPUT_OBJECT"
+	List        TypeKind = "LIST"
+	NonNull     TypeKind = "NON_NULL"
+)
+
+// Schema is the result of the introspection query.
+type Schema struct {
+	QueryType        *TypeName    `json:"queryType"`
+	MutationType     *TypeName    `json:"mutationType"`
+	SubscriptionType *TypeName    `json:"subscriptionType"`
+	Types            []*Type      `json:"types"`
+	Directives       []*Directive `json:"directives"`
+}
+
+// TypeName refers to a type by name.
+type TypeName struct {
+	Name string `json:"name"`
+}
+
+// Type is a named type of the schema.
+type Type struct {
+	Kind          TypeKind      `json:"kind"`
+	Name          string        `json:"name"`
+	Description   string        `json:"description,omitempty"`
+	Fields        []*Field      `json:"fields"`
+	InputFields   []*InputValue `json:"inputFields"`
+	Interfaces    []*TypeRef    `json:"interfaces"`
+	EnumValues    []*EnumValue  `json:"enumValues"`
+	PossibleTypes []*TypeRef    `json:"possibleTypes"`
+}
+
+// Field is a field of an object or interface type.
+type Field struct {
+	Name              string        `json:"name"`
+	Description       string        `json:"description,omitempty"`
+	Args              []*InputValue `json:"args"`
+	Type              *TypeRef      `json:"type"`
+	IsDeprecated      bool          `json:"isDeprecated"`
+	DeprecationReason *string       `json:"deprecationReason"`
+}
+
+// InputValue is an argument or an input object field.
+type InputValue struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Type        *TypeRef `json:"type"`
+	// DefaultValue is the GraphQL literal of the default value, if any.
+	DefaultValue *string `json:"defaultValue"`
+}
+
+// EnumValue is a value of an enum type.
+type EnumValue struct {
+	Name              string  `json:"name"`
+	Description       string  `json:"description,omitempty"`
+	IsDeprecated      bool    `json:"isDeprecated"`
+	DeprecationReason *string `json:"deprecationReason"`
+}
+
+// Directive is a directive supported by the schema.
+type Directive struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Locations   []string      `json:"locations"`
+	Args        []*InputValue `json:"args"`
+}
+
+// TypeRef is a reference to a type. It's a list or non-null type wrapping
+// OfType, or a named type.
+type TypeRef struct {
+	Kind   TypeKind `json:"kind"`
+	Name   string   `json:"name,omitempty"`
+	OfType *TypeRef `json:"ofType,omitempty"`
+}
+
+// String returns the GraphQL syntax of r, e.g. "[Int!]!".
+func (r *TypeRef) String() string {
+	switch r.Kind {
+	case List:
+		return "[" + r.OfType.String() + "]"
+	case NonNull:
+		return r.OfType.String() + "!"
+	}
+	return r.Name
+}
+
+// NamedType returns the name of the type r refers to, without list and
+// non-null wrappers.
+func (r *TypeRef) NamedType() string {
+	for r.OfType != nil {
+		r = r.OfType
+	}
+	return r.Name
+}
+
+// Type returns the type with the given name, or nil.
+func (s *Schema) Type(name string) *Type {
+	for _, t := range s.Types {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Directive returns the directive with the given name, or nil.
+func (s *Schema) Directive(name string) *Directive {
+	for _, d := range s.Directives {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// PossibleTypes returns the names of the possible types of the interface and
+// union types of s, by type name, e.g. for Cache.SetPossibleTypes.
+func (s *Schema) PossibleTypes() map[string][]string {
+	possibleTypes := make(map[string][]string)
+	for _, t := range s.Types {
+		if t.Kind != Interface && t.Kind != Union {
+			continue
+		}
+		names := make([]string, len(t.PossibleTypes))
+		for i, pt := range t.PossibleTypes {
+			names[i] = pt.Name
+		}
+		possibleTypes[t.Name] = names
+	}
+	return possibleTypes
+}
+
+// Field returns the field of t with the given name, or nil.
+func (t *Type) Field(name string) *Field {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// InputField returns the input field of t with the given name, or nil.
+func (t *Type) InputField(name string) *InputValue {
+	for _, f := range t.InputFields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// IsLeaf reports whether t is a scalar or enum type.
+func (t *Type) IsLeaf() bool {
+	return t.Kind == Scalar || t.Kind == Enum
+}
+
+// IsComposite reports whether t is an object, interface or union type.
+func (t *Type) IsComposite() bool {
+	return t.Kind == Object || t.Kind == Interface || t.Kind == Union
+}
+
+// Parse parses an introspection result. data is either the response of the
+// introspection query, with or without the "data" envelope, or the
+// "__schema" object itself.
+func Parse(data []byte) (*Schema, error) {
+	var envelope struct {
+		Data *struct {
+			Schema *Schema `json:"__schema"`
+		} `json:"data"`
+		Schema *Schema `json:"__schema"`
+		Types  []*Type `json:"types"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	switch {
+	case envelope.Data != nil && envelope.Data.Schema != nil:
+		return envelope.Data.Schema, nil
+	case envelope.Schema != nil:
+		return envelope.Schema, nil
+	case envelope.Types != nil:
+		var s Schema
+		err := json.Unmarshal(data, &s)
+		return &s, err
+	}
+	return nil, errors.New("introspection: no __schema found")
+}
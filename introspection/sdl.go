@@ -0,0 +1,239 @@
+package introspection
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var builtinScalars = map[string]bool{
+	"Int":     true,
+	"Float":   true,
+	"String":  true,
+	"Boolean": true,
+	"ID":      true,
+}
+
+var builtinDirectives = map[string]bool{
+	"skip":        true,
+	"include":     true,
+	"deprecated":  true,
+	"specifiedBy": true,
+}
+
+const defaultDeprecationReason = "No longer supported"
+
+// SDL returns the schema in the GraphQL schema definition language.
+// Built-in scalars and directives, and introspection types, are left out.
+// Types and directives are sorted by name.
+func (s *Schema) SDL() string {
+	var b strings.Builder
+	writeSchemaDefinition(&b, s)
+
+	directives := append([]*Directive(nil), s.Directives...)
+	sort.SliceStable(directives, func(i, j int) bool { return directives[i].Name < directives[j].Name })
+	for _, d := range directives {
+		if builtinDirectives[d.Name] {
+			continue
+		}
+		separate(&b)
+		writeDescription(&b, d.Description, "")
+		b.WriteString("directive @")
+		b.WriteString(d.Name)
+		writeArgs(&b, d.Args)
+		b.WriteString(" on ")
+		b.WriteString(strings.Join(d.Locations, " | "))
+		b.WriteString("\n")
+	}
+
+	types := append([]*Type(nil), s.Types...)
+	sort.SliceStable(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	for _, t := range types {
+		if strings.HasPrefix(t.Name, "__") || t.Kind == Scalar && builtinScalars[t.Name] {
+			continue
+		}
+		separate(&b)
+		writeType(&b, t)
+	}
+	return b.String()
+}
+
+// writeSchemaDefinition writes the schema definition, unless the root
+// operation types have their conventional names.
+func writeSchemaDefinition(b *strings.Builder, s *Schema) {
+	roots := []struct {
+		operation string
+		typ       *TypeName
+		name      string
+	}{
+		{"query", s.QueryType, "Query"},
+		{"mutation", s.MutationType, "Mutation"},
+		{"subscription", s.SubscriptionType, "Subscription"},
+	}
+	conventional := true
+	for _, r := range roots {
+		if r.typ != nil && r.typ.Name != r.name {
+			conventional = false
+		}
+	}
+	if conventional {
+		return
+	}
+	b.WriteString("schema {\n")
+	for _, r := range roots {
+		if r.typ != nil {
+			b.WriteString("  " + r.operation + ": " + r.typ.Name + "\n")
+		}
+	}
+	b.WriteString("}\n")
+}
+
+func separate(b *strings.Builder) {
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+}
+
+func writeType(b *strings.Builder, t *Type) {
+	writeDescription(b, t.Description, "")
+	switch t.Kind {
+	case Scalar:
+		b.WriteString("scalar " + t.Name + "\n")
+	case Object, Interface:
+		if t.Kind == Object {
+			b.WriteString("type ")
+		} else {
+			b.WriteString("interface ")
+		}
+		b.WriteString(t.Name)
+		if len(t.Interfaces) > 0 {
+			names := make([]string, len(t.Interfaces))
+			for i, iface := range t.Interfaces {
+				names[i] = iface.Name
+			}
+			b.WriteString(" implements " + strings.Join(names, " & "))
+		}
+		b.WriteString(" {\n")
+		for _, f := range t.Fields {
+			writeDescription(b, f.Description, "  ")
+			b.WriteString("  " + f.Name)
+			writeArgs(b, f.Args)
+			b.WriteString(": " + f.Type.String())
+			writeDeprecated(b, f.IsDeprecated, f.DeprecationReason)
+			b.WriteString("\n")
+		}
+		b.WriteString("}\n")
+	case Union:
+		names := make([]string, len(t.PossibleTypes))
+		for i, pt := range t.PossibleTypes {
+			names[i] = pt.Name
+		}
+		b.WriteString("union " + t.Name + " = " + strings.Join(names, " | ") + "\n")
+	case Enum:
+		b.WriteString("enum " + t.Name + " {\n")
+		for _, v := range t.EnumValues {
+			writeDescription(b, v.Description, "  ")
+			b.WriteString("  " + v.Name)
+			writeDeprecated(b, v.IsDeprecated, v.DeprecationReason)
+			b.WriteString("\n")
+		}
+		b.WriteString("}\n")
+	case InputObject:
+		b.WriteString("input " + t.Name + " {\n")
+		for _, f := range t.InputFields {
+			writeDescription(b, f.Description, "  ")
+			b.WriteString("  ")
+			writeInputValue(b, f)
+			b.WriteString("\n")
+		}
+		b.WriteString("}\n")
+	}
+}
+
+func writeArgs(b *strings.Builder, args []*InputValue) {
+	if len(args) == 0 {
+		return
+	}
+	b.WriteString("(")
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeInputValue(b, arg)
+	}
+	b.WriteString(")")
+}
+
+func writeInputValue(b *strings.Builder, v *InputValue) {
+	b.WriteString(v.Name + ": " + v.Type.String())
+	if v.DefaultValue != nil {
+		b.WriteString(" = " + *v.DefaultValue)
+	}
+}
+
+func writeDeprecated(b *strings.Builder, deprecated bool, reason *string) {
+	if !deprecated {
+		return
+	}
+	b.WriteString(" @deprecated")
+	if reason != nil && *reason != defaultDeprecationReason {
+		b.WriteString("(reason: " + quote(*reason) + ")")
+	}
+}
+
+func writeDescription(b *strings.Builder, description string, indent string) {
+	if description == "" {
+		return
+	}
+	if !strings.Contains(description, "\n") || strings.IndexFunc(description, isBlockControl) >= 0 {
+		b.WriteString(indent + quote(description) + "\n")
+		return
+	}
+	b.WriteString(indent + `"""` + "\n")
+	for _, line := range strings.Split(description, "\n") {
+		if line != "" {
+			b.WriteString(indent + strings.Replace(line, `"""`, `\"""`, -1))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(indent + `"""` + "\n")
+}
+
+// isBlockControl reports whether r is a control character that can't be
+// written in a block string.
+func isBlockControl(r rune) bool {
+	return r < ' ' && r != '\t' && r != '\n' || r == '\u007f'
+}
+
+// quote returns s as a GraphQL string value. Unlike strconv.Quote, it only
+// uses the escape sequences GraphQL knows about.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < ' ' || r == '\u007f' {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -6,6 +6,10 @@ type OptionType string
 const (
 	// optionTypeOperationName is private because it's option is built-in and unique
 	optionTypeOperationName      OptionType = "operation_name"
+	optionTypeIndent             OptionType = "indent"
+	optionTypeScalars            OptionType = "scalars"
+	optionTypePossibleTypes      OptionType = "possible_types"
+	optionTypeCachePolicy        OptionType = "cache_policy"
 	OptionTypeOperationDirective OptionType = "operation_directive"
 )
 
@@ -13,7 +17,7 @@ const (
 // They are optional parts. By default GraphQL queries can request data without them
 type Option interface {
 	// Type returns the supported type of the renderer
-	// available types: operation_name and operation_directive
+	// available types: operation_name, operation_directive, indent, scalars, possible_types and cache_policy
 	Type() OptionType
 	// String returns the query component string
 	String() string
@@ -36,3 +40,24 @@ func (ono operationNameOption) String() string {
 func OperationName(name string) Option {
 	return operationNameOption{name}
 }
+
+// indentOption represents the formatting mode of the query string
+type indentOption struct {
+	indent string
+}
+
+func (ino indentOption) Type() OptionType {
+	return optionTypeIndent
+}
+
+func (ino indentOption) String() string {
+	return ino.indent
+}
+
+// Indent creates the option that renders the query in canonical form over
+// multiple lines, with selection sets indented by indent, e.g. "  " or "\t".
+// It makes queries easier to read in logs and reviews; see NormalizeQuery
+// for the canonical form. An empty indent keeps the default minified output.
+func Indent(indent string) Option {
+	return indentOption{indent}
+}
@@ -0,0 +1,285 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/phoban01/go-graphql-client/internal/jsonutil"
+)
+
+// Pagination describes the Relay connection that Client.Paginate pages
+// through, and how.
+//
+// Specification: https://relay.dev/graphql/connections.htm.
+type Pagination struct {
+	// Path is the path of response keys from the query to the connection
+	// field, e.g. "repository.issues". The connection holds its nodes in a
+	// nodes field or in the node field of its edges, and a pageInfo field.
+	Path string
+	// Cursor is the name of the variable of the cursor argument of the
+	// connection field, e.g. "after", or "before" when paging backward.
+	// It's set to the end cursor of each page, or to its start cursor when
+	// paging backward. Unless the variables set it, it's a *String, null for
+	// the first page.
+	Cursor string
+	// Backward makes pagination go backward, from the start cursor of each
+	// page while pageInfo.hasPreviousPage is true, rather than forward, from
+	// the end cursor while pageInfo.hasNextPage is true. The nodes of each
+	// page are still yielded in their order in the page.
+	Backward bool
+	// PageSize, if positive, is the number of nodes requested per page, as
+	// the variable named by PageSizeVariable, which the query must use as
+	// the page size argument of the connection field, e.g.
+	// issues(first: $first). It's an Int, unless the variables set it, in
+	// which case it has the type of their value, an integer type or a
+	// pointer to one.
+	PageSize int
+	// PageSizeVariable is the name of the variable of the page size
+	// argument, "first" by default, or "last" when paging backward.
+	PageSizeVariable string
+	// MaxNodes, if positive, is the maximum number of nodes yielded. The
+	// page size is reduced for the last page to request no more nodes.
+	MaxNodes int
+}
+
+// Paginate returns an iterator over the nodes of the Relay connection of
+// query q described by p, which executes q as a query once per page, with
+// the cursor variable set to the cursor of the previous page. q is decoded
+// like with Query, and holds the last page once iteration stops; it's reset
+// to its value before each page, so that it may hold slices outside the
+// connection.
+//
+// The iterator has the type of iter.Seq2[interface{}, error], so that it's
+// ranged over since Go 1.23, and called with a yield function before.
+//
+// Each node is yielded with a nil error. Pagination stops when the pages
+// are exhausted, p.MaxNodes nodes are yielded, ctx is done or the loop
+// breaks. Errors, including those of the responses, are yielded with a nil
+// node and stop pagination.
+//
+// E.g., for a query struct
+//
+//	var q struct {
+//		Repository struct {
+//			Issues struct {
+//				Nodes    []struct{ Title String }
+//				PageInfo struct {
+//					EndCursor   String
+//					HasNextPage Boolean
+//				}
+//			} `graphql:"issues(first: $first, after: $after)"`
+//		} `graphql:"repository(owner: $owner, name: $name)"`
+//	}
+//
+// the titles of all the issues are
+//
+//	for node, err := range client.Paginate(ctx, &q, variables, Pagination{Path: "repository.issues", Cursor: "after", PageSize: 100}) {
+//		if err != nil {
+//			// Handle error.
+//		}
+//		fmt.Println(node.(struct{ Title String }).Title)
+//	}
+func (c *Client) Paginate(ctx context.Context, q interface{}, variables map[string]interface{}, p Pagination, options ...Option) func(yield func(interface{}, error) bool) {
+	return func(yield func(interface{}, error) bool) {
+		v := reflect.ValueOf(q)
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			yield(nil, Errors{newError(ErrGraphQLEncode, fmt.Errorf("query must be a non-nil pointer, got %T", q))})
+			return
+		}
+		if p.Path == "" || p.Cursor == "" {
+			yield(nil, Errors{newError(ErrGraphQLEncode, errors.New("pagination needs the path of the connection and the name of the cursor variable"))})
+			return
+		}
+		initial := reflect.ValueOf(v.Elem().Interface())
+
+		vars := make(map[string]interface{}, len(variables)+2)
+		for k, value := range variables {
+			vars[k] = value
+		}
+		if _, ok := vars[p.Cursor]; !ok {
+			vars[p.Cursor] = (*String)(nil)
+		}
+		sizeVariable := p.PageSizeVariable
+		if sizeVariable == "" {
+			sizeVariable = "first"
+			if p.Backward {
+				sizeVariable = "last"
+			}
+		}
+
+		yielded := 0
+		for page := 0; ; page++ {
+			if err := ctx.Err(); err != nil {
+				yield(nil, Errors{newError(ErrRequestError, err)})
+				return
+			}
+			if p.PageSize > 0 {
+				size := p.PageSize
+				if p.MaxNodes > 0 && p.MaxNodes-yielded < size {
+					size = p.MaxNodes - yielded
+				}
+				vars[sizeVariable] = pageSizeValue(vars[sizeVariable], size)
+			}
+			if page > 0 {
+				v.Elem().Set(initial)
+			}
+			if _, err := c.Query(ctx, q, vars, options...); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			conn, nodes, err := connectionNodes(v, p.Path)
+			if err != nil {
+				yield(nil, Errors{newError(ErrGraphQLDecode, err)})
+				return
+			}
+			for _, node := range nodes {
+				if !yield(node, nil) {
+					return
+				}
+				yielded++
+				if p.MaxNodes > 0 && yielded >= p.MaxNodes {
+					return
+				}
+			}
+
+			hasPage, cursor, err := nextPage(conn, p.Backward)
+			if err != nil {
+				yield(nil, Errors{newError(ErrGraphQLDecode, fmt.Errorf("%s: %w", p.Path, err))})
+				return
+			}
+			if !hasPage || cursor == "" {
+				return
+			}
+			vars[p.Cursor] = cursorValue(vars[p.Cursor], cursor)
+		}
+	}
+}
+
+// connectionNodes returns the connection at path in the query data structure
+// v, and its nodes. The connection is invalid if it's null.
+func connectionNodes(v reflect.Value, path string) (reflect.Value, []interface{}, error) {
+	conn := v
+	for _, key := range strings.Split(path, ".") {
+		f, ok := jsonutil.FieldByResponseKey(conn, key)
+		if !ok {
+			if isNilPtr(conn) {
+				// A null field on the path has no nodes.
+				return reflect.Value{}, nil, nil
+			}
+			return reflect.Value{}, nil, fmt.Errorf("no field for the response key %q of the connection path %q", key, path)
+		}
+		conn = f
+	}
+	if isNilPtr(conn) {
+		return reflect.Value{}, nil, nil
+	}
+
+	var nodes []interface{}
+	if list, ok := jsonutil.FieldByResponseKey(conn, "nodes"); ok {
+		list = reflect.Indirect(list)
+		for i := 0; i < list.Len(); i++ {
+			nodes = append(nodes, list.Index(i).Interface())
+		}
+		return conn, nodes, nil
+	}
+	if edges, ok := jsonutil.FieldByResponseKey(conn, "edges"); ok {
+		edges = reflect.Indirect(edges)
+		for i := 0; i < edges.Len(); i++ {
+			node, ok := jsonutil.FieldByResponseKey(edges.Index(i), "node")
+			if !ok {
+				if isNilPtr(edges.Index(i)) {
+					continue
+				}
+				return reflect.Value{}, nil, fmt.Errorf("%s: the edges have no node field", path)
+			}
+			nodes = append(nodes, node.Interface())
+		}
+		return conn, nodes, nil
+	}
+	return reflect.Value{}, nil, fmt.Errorf("%s: the connection has neither a nodes nor an edges field", path)
+}
+
+// nextPage returns whether the connection conn has a next page, or a
+// previous one if backward is true, and the cursor of the page it follows.
+func nextPage(conn reflect.Value, backward bool) (bool, string, error) {
+	if !conn.IsValid() {
+		return false, "", nil
+	}
+	pageInfo, ok := jsonutil.FieldByResponseKey(conn, "pageInfo")
+	if !ok {
+		return false, "", errors.New("the connection has no pageInfo field")
+	}
+	if isNilPtr(pageInfo) {
+		return false, "", nil
+	}
+	hasPageKey, cursorKey := "hasNextPage", "endCursor"
+	if backward {
+		hasPageKey, cursorKey = "hasPreviousPage", "startCursor"
+	}
+	hasPage, ok := jsonutil.FieldByResponseKey(pageInfo, hasPageKey)
+	if !ok {
+		return false, "", fmt.Errorf("pageInfo has no %s field", hasPageKey)
+	}
+	cursor, ok := jsonutil.FieldByResponseKey(pageInfo, cursorKey)
+	if !ok {
+		return false, "", fmt.Errorf("pageInfo has no %s field", cursorKey)
+	}
+	hasPage, cursor = reflect.Indirect(hasPage), reflect.Indirect(cursor)
+	if !hasPage.IsValid() || !cursor.IsValid() {
+		// Null page info.
+		return false, "", nil
+	}
+	if hasPage.Kind() != reflect.Bool || cursor.Kind() != reflect.String {
+		return false, "", fmt.Errorf("pageInfo.%s must be a boolean and pageInfo.%s a string, got %v and %v", hasPageKey, cursorKey, hasPage.Type(), cursor.Type())
+	}
+	return hasPage.Bool(), cursor.String(), nil
+}
+
+// cursorValue returns cursor as a value of the type of the cursor variable
+// value, a string type or a pointer to one, or as a *String.
+func cursorValue(value interface{}, cursor string) interface{} {
+	t := reflect.TypeOf(value)
+	switch {
+	case t != nil && t.Kind() == reflect.String:
+		return reflect.ValueOf(cursor).Convert(t).Interface()
+	case t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.String:
+		p := reflect.New(t.Elem())
+		p.Elem().SetString(cursor)
+		return p.Interface()
+	default:
+		return NewString(String(cursor))
+	}
+}
+
+// pageSizeValue returns size as a value of the type of the page size
+// variable value, an integer type or a pointer to one, or as an Int.
+func pageSizeValue(value interface{}, size int) interface{} {
+	t := reflect.TypeOf(value)
+	switch {
+	case t != nil && isIntKind(t.Kind()):
+		return reflect.ValueOf(size).Convert(t).Interface()
+	case t != nil && t.Kind() == reflect.Ptr && isIntKind(t.Elem().Kind()):
+		p := reflect.New(t.Elem())
+		p.Elem().Set(reflect.ValueOf(size).Convert(t.Elem()))
+		return p.Interface()
+	default:
+		return Int(size)
+	}
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func isNilPtr(v reflect.Value) bool {
+	return (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()
+}
@@ -6,16 +6,20 @@ import (
 	"fmt"
 	"io"
 	"reflect"
-	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/phoban01/go-graphql-client/ident"
+	"github.com/phoban01/go-graphql-client/internal/jsonutil"
+	"github.com/phoban01/go-graphql-client/internal/language"
 )
 
 type constructOptionsOutput struct {
 	operationName       string
 	operationDirectives []string
+	indent              string
+	scalars             *ScalarRegistry
+	possibleTypes       *PossibleTypeRegistry
 }
 
 func (coo constructOptionsOutput) OperationDirectivesString() string {
@@ -35,6 +39,14 @@ func constructOptions(options []Option) (*constructOptionsOutput, error) {
 			output.operationName = option.String()
 		case OptionTypeOperationDirective:
 			output.operationDirectives = append(output.operationDirectives, option.String())
+		case optionTypeIndent:
+			output.indent = option.String()
+		case optionTypeScalars:
+			output.scalars = option.(*ScalarRegistry)
+		case optionTypePossibleTypes:
+			output.possibleTypes = option.(*PossibleTypeRegistry)
+		case optionTypeCachePolicy:
+			// The cache policy doesn't change the query.
 		default:
 			return nil, fmt.Errorf("invalid query option type: %s", option.Type())
 		}
@@ -43,100 +55,140 @@ func constructOptions(options []Option) (*constructOptionsOutput, error) {
 	return output, nil
 }
 
-// ConstructQuery build GraphQL query string from struct and variables
-func ConstructQuery(v interface{}, variables map[string]interface{}, options ...Option) (string, error) {
-	query := query(v)
+// ConstructQuery build GraphQL query string from struct and variables.
+// variables is either a map[string]interface{} or a variables struct.
+func ConstructQuery(v interface{}, variables interface{}, options ...Option) (string, error) {
+	return constructOperation("query", v, variables, options)
+}
+
+// ConstructQuery build GraphQL mutation string from struct and variables.
+// variables is either a map[string]interface{} or a variables struct.
+func ConstructMutation(v interface{}, variables interface{}, options ...Option) (string, error) {
+	return constructOperation("mutation", v, variables, options)
+}
+
+// ConstructSubscription build GraphQL subscription string from struct and variables.
+// variables is either a map[string]interface{} or a variables struct.
+func ConstructSubscription(v interface{}, variables interface{}, options ...Option) (string, error) {
+	return constructOperation("subscription", v, variables, options)
+}
 
+// constructOperation builds the operation string of the given type.
+// The document is minified, unless the Indent option is given.
+func constructOperation(operation string, v interface{}, variables interface{}, options []Option) (string, error) {
 	optionsOutput, err := constructOptions(options)
 	if err != nil {
 		return "", err
 	}
 
-	if len(variables) > 0 {
-		return fmt.Sprintf("query %s(%s)%s%s", optionsOutput.operationName, queryArguments(variables), optionsOutput.OperationDirectivesString(), query), nil
-	}
-
-	if optionsOutput.operationName == "" && len(optionsOutput.operationDirectives) == 0 {
-		return query, nil
-	}
-
-	return fmt.Sprintf("query %s%s%s", optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query), nil
-}
-
-// ConstructQuery build GraphQL mutation string from struct and variables
-func ConstructMutation(v interface{}, variables map[string]interface{}, options ...Option) (string, error) {
-	query := query(v)
-	optionsOutput, err := constructOptions(options)
+	query := query(v, optionsOutput.scalars, optionsOutput.possibleTypes)
+	arguments, err := queryArguments(variables, optionsOutput.scalars)
 	if err != nil {
 		return "", err
 	}
-	if len(variables) > 0 {
-		return fmt.Sprintf("mutation %s(%s)%s%s", optionsOutput.operationName, queryArguments(variables), optionsOutput.OperationDirectivesString(), query), nil
+
+	var document string
+	switch {
+	case arguments != "":
+		document = fmt.Sprintf("%s %s(%s)%s%s", operation, optionsOutput.operationName, arguments, optionsOutput.OperationDirectivesString(), query)
+	case optionsOutput.operationName == "" && len(optionsOutput.operationDirectives) == 0:
+		if operation == "query" {
+			document = query
+		} else {
+			document = operation + query
+		}
+	default:
+		document = fmt.Sprintf("%s %s%s%s", operation, optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query)
 	}
 
-	if optionsOutput.operationName == "" && len(optionsOutput.operationDirectives) == 0 {
-		return "mutation" + query, nil
+	if optionsOutput.indent == "" {
+		return document, nil
 	}
+	return formatQuery(document, optionsOutput.indent)
+}
 
-	return fmt.Sprintf("mutation %s%s%s", optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query), nil
+// FormatQuery returns query in canonical form, printed over multiple lines
+// with selection sets indented by indent. See NormalizeQuery for the
+// canonical form. If indent is empty, it's equivalent to NormalizeQuery.
+func FormatQuery(query string, indent string) (string, error) {
+	return formatQuery(query, indent)
 }
 
-// ConstructSubscription build GraphQL subscription string from struct and variables
-func ConstructSubscription(v interface{}, variables map[string]interface{}, options ...Option) (string, error) {
-	query := query(v)
-	optionsOutput, err := constructOptions(options)
+// NormalizeQuery returns query in minified canonical form, suitable for
+// hashing or comparing documents.
+//
+// Whitespace, commas and comments are normalized, operations come first and
+// fragment definitions are sorted by name. Variable definitions, arguments
+// and input object fields are sorted by name, while selections and
+// directives are kept in order, since their order is significant.
+func NormalizeQuery(query string) (string, error) {
+	return formatQuery(query, "")
+}
+
+func formatQuery(query string, indent string) (string, error) {
+	doc, err := language.Parse(query)
 	if err != nil {
 		return "", err
 	}
-	if len(variables) > 0 {
-		return fmt.Sprintf("subscription %s(%s)%s%s", optionsOutput.operationName, queryArguments(variables), optionsOutput.OperationDirectivesString(), query), nil
-	}
-	if optionsOutput.operationName == "" && len(optionsOutput.operationDirectives) == 0 {
-		return "subscription" + query, nil
-	}
-	return fmt.Sprintf("subscription %s%s%s", optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query), nil
+	language.Sort(doc)
+	return language.Print(doc, indent), nil
 }
 
 // queryArguments constructs a minified arguments string for variables.
+// Default values and directives are separated by spaces for readability.
 //
 // E.g., map[string]interface{}{"a": Int(123), "b": NewBoolean(true)} -> "$a:Int!$b:Boolean".
-func queryArguments(variables map[string]interface{}) string {
-	// Sort keys in order to produce deterministic output for testing purposes.
-	// TODO: If tests can be made to work with non-deterministic output, then no need to sort.
-	keys := make([]string, 0, len(variables))
-	for k := range variables {
-		keys = append(keys, k)
+func queryArguments(variables interface{}, scalars *ScalarRegistry) (string, error) {
+	vars, err := variableList(variables, scalars)
+	if err != nil {
+		return "", err
 	}
-	sort.Strings(keys)
 
 	var buf bytes.Buffer
-	for _, k := range keys {
+	for _, v := range vars {
 		io.WriteString(&buf, "$")
-		io.WriteString(&buf, k)
+		io.WriteString(&buf, v.name)
 		io.WriteString(&buf, ":")
-		writeArgumentType(&buf, reflect.TypeOf(variables[k]), true)
+		writeArgumentType(&buf, v.typ, !v.nullable, scalars)
+		if v.defaultValue != "" {
+			io.WriteString(&buf, " = ")
+			io.WriteString(&buf, v.defaultValue)
+		}
+		for _, d := range v.directives {
+			io.WriteString(&buf, " ")
+			io.WriteString(&buf, d)
+		}
 		// Don't insert a comma here.
 		// Commas in GraphQL are insignificant, and we want minified output.
 		// See https://facebook.github.io/graphql/October2016/#sec-Insignificant-Commas.
 	}
-	return buf.String()
+	return buf.String(), nil
 }
 
 // writeArgumentType writes a minified GraphQL type for t to w.
 // value indicates whether t is a value (required) type or pointer (optional) type.
 // If value is true, then "!" is written at the end of t.
-func writeArgumentType(w io.Writer, t reflect.Type, value bool) {
+// Types registered in scalars are named after their scalar, and types that
+// implement GraphQLType are named by it.
+func writeArgumentType(w io.Writer, t reflect.Type, value bool, scalars *ScalarRegistry) {
 	if t.Kind() == reflect.Ptr {
 		// Pointer is an optional type, so no "!" at the end of the pointer's underlying type.
-		writeArgumentType(w, t.Elem(), false)
+		writeArgumentType(w, t.Elem(), false, scalars)
 		return
 	}
 
-	switch t.Kind() {
-	case reflect.Slice, reflect.Array:
+	scalar, isScalar := scalars.lookup(t)
+	switch {
+	case isScalar:
+		io.WriteString(w, scalar.Name)
+	case t.Implements(graphQLTypeType):
+		io.WriteString(w, reflect.Zero(t).Interface().(GraphQLType).GetGraphQLType())
+	case reflect.PtrTo(t).Implements(graphQLTypeType):
+		io.WriteString(w, reflect.New(t).Interface().(GraphQLType).GetGraphQLType())
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
 		// List. E.g., "[Int]".
 		io.WriteString(w, "[")
-		writeArgumentType(w, t.Elem(), true)
+		writeArgumentType(w, t.Elem(), true, scalars)
 		io.WriteString(w, "]")
 	default:
 		// Named type. E.g., "Int".
@@ -155,61 +207,71 @@ func writeArgumentType(w io.Writer, t reflect.Type, value bool) {
 
 // query uses writeQuery to recursively construct
 // a minified query string from the provided struct v.
+// Definitions of the named fragments used by v are appended at the end.
+//
+// The types registered in scalars are leaves, and the interface types
+// registered in possibleTypes are selected with their possible types.
 //
 // E.g., struct{Foo Int, BarBaz *Boolean} -> "{foo,barBaz}".
-func query(v interface{}) string {
+func query(v interface{}, scalars *ScalarRegistry, possibleTypes *PossibleTypeRegistry) string {
 	var buf bytes.Buffer
-	writeQuery(&buf, reflect.TypeOf(v), reflect.ValueOf(v), false)
+	fs := fragmentSet{scalars: scalars, possibleTypes: possibleTypes}
+	writeQuery(&buf, reflect.TypeOf(v), reflect.ValueOf(v), false, &fs)
+	writeFragments(&buf, &fs)
 	return buf.String()
 }
 
 // writeQuery writes a minified query for t to w.
 // If inline is true, the struct fields of t are inlined into parent struct.
-func writeQuery(w io.Writer, t reflect.Type, v reflect.Value, inline bool) {
+// Named fragments encountered along the way are spread by name and collected into fs.
+func writeQuery(w io.Writer, t reflect.Type, v reflect.Value, inline bool, fs *fragmentSet) {
+	if _, ok := fs.scalars.lookup(t); ok {
+		// Registered scalars are leaves.
+		return
+	}
 	switch t.Kind() {
 	case reflect.Ptr:
-		writeQuery(w, t.Elem(), ElemSafe(v), false)
+		writeQuery(w, t.Elem(), ElemSafe(v), false, fs)
 	case reflect.Struct:
+		if jsonutil.IsOptional(t) {
+			// Optional is selected as its value.
+			writeQuery(w, t.Field(0).Type, FieldSafe(v, 0), inline, fs)
+			return
+		}
+		if t == selectionType {
+			var s Selection
+			if v.IsValid() {
+				s = v.Interface().(Selection)
+			}
+			writeSelection(w, s, fs)
+			return
+		}
 		// If the type implements json.Unmarshaler, it's a scalar. Don't expand it.
 		if reflect.PtrTo(t).Implements(jsonUnmarshaler) {
 			return
 		}
-		if !inline {
-			io.WriteString(w, "{")
-		}
-		iter := 0
-		for i := 0; i < t.NumField(); i++ {
-			f := t.Field(i)
-			value, ok := f.Tag.Lookup("graphql")
-			// Skip this field if the tag value is hyphen
-			if value == "-" {
-				continue
+		if f, ok := asNamedFragment(t); ok {
+			fs.add(f.FragmentName(), t, v)
+			if !inline {
+				io.WriteString(w, "{")
 			}
-			if iter != 0 {
-				io.WriteString(w, ",")
+			io.WriteString(w, "...")
+			io.WriteString(w, f.FragmentName())
+			if !inline {
+				io.WriteString(w, "}")
 			}
-			iter++
-
-			inlineField := f.Anonymous && !ok
-			if !inlineField {
-				if ok {
-					io.WriteString(w, value)
-				} else {
-					io.WriteString(w, ident.ParseMixedCaps(f.Name).ToLowerCamelCase())
-				}
-			}
-			// Skip writeQuery if the GraphQL type associated with the filed is scalar
-			if isTrue(f.Tag.Get("scalar")) {
-				continue
-			}
-			writeQuery(w, f.Type, FieldSafe(v, i), inlineField)
+			return
+		}
+		if !inline {
+			io.WriteString(w, "{")
 		}
+		writeStructFields(w, t, v, fs)
 		if !inline {
 			io.WriteString(w, "}")
 		}
 	case reflect.Slice:
 		if t.Elem().Kind() != reflect.Array {
-			writeQuery(w, t.Elem(), IndexSafe(v, 0), false)
+			writeQuery(w, t.Elem(), IndexSafe(v, 0), false, fs)
 			return
 		}
 		// handle [][2]interface{} like an ordered map
@@ -225,15 +287,76 @@ func writeQuery(w io.Writer, t reflect.Type, v reflect.Value, inline bool) {
 			// to cast it away
 			key, val := pair.Index(0), reflect.ValueOf(pair.Index(1).Interface())
 			_, _ = io.WriteString(w, key.Interface().(string))
-			writeQuery(w, val.Type(), val, false)
+			writeQuery(w, val.Type(), val, false, fs)
 		}
 		_, _ = io.WriteString(w, "}")
+	case reflect.Interface:
+		// Interfaces are leaves, unless they have possible types.
+		if types, ok := fs.possibleTypes.lookup(t); ok {
+			writePossibleTypes(w, types, fs)
+		}
 	case reflect.Map:
-		err := fmt.Errorf("type %v is not supported, use [][2]interface{} instead", t)
+		err := fmt.Errorf("type %v is not supported, use [][2]interface{}, or select its values in the graphql tag of the field, instead", t)
 		panic(err.Error())
 	}
 }
 
+// writeStructFields writes the minified fields of struct t to w,
+// without the surrounding braces.
+func writeStructFields(w io.Writer, t reflect.Type, v reflect.Value, fs *fragmentSet) {
+	iter := 0
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		value, ok := f.Tag.Lookup("graphql")
+		// Skip this field if the tag value is hyphen
+		if value == "-" {
+			continue
+		}
+		if iter != 0 {
+			io.WriteString(w, ",")
+		}
+		iter++
+
+		inlineField := f.Anonymous && !ok
+		if !inlineField {
+			if ok {
+				io.WriteString(w, value)
+			} else {
+				io.WriteString(w, ident.ParseMixedCaps(f.Name).ToLowerCamelCase())
+			}
+		}
+		// Skip writeQuery if the GraphQL type associated with the filed is scalar
+		if isTrue(f.Tag.Get("scalar")) {
+			continue
+		}
+		// The selection set of a map comes from its tag, as the map values
+		// are decoded from the response keys of the selections.
+		if isMap(f.Type) && hasSelectionSet(value) {
+			continue
+		}
+		writeQuery(w, f.Type, FieldSafe(v, i), inlineField, fs)
+	}
+}
+
+// isMap reports whether t is a map, or a pointer to or a slice of maps.
+func isMap(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Map
+}
+
+// hasSelectionSet reports whether the graphql tag value selects a single
+// field with a selection set, e.g. "repository(name: $name){a: issue(number: 1){title}}".
+func hasSelectionSet(value string) bool {
+	doc, err := language.Parse("{" + value + "}")
+	if err != nil || len(doc.Operations[0].SelectionSet) != 1 {
+		return false
+	}
+	field, ok := doc.Operations[0].SelectionSet[0].(*language.Field)
+	return ok && field.SelectionSet != nil
+}
+
 func IndexSafe(v reflect.Value, i int) reflect.Value {
 	if v.IsValid() && i < v.Len() {
 		return v.Index(i)
@@ -1,8 +1,10 @@
 package graphql
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -339,6 +341,33 @@ func TestConstructQuery(t *testing.T) {
 			}{},
 			want: `{viewer{login,databaseId}}`,
 		},
+		{
+			inV: struct {
+				Viewer struct {
+					Login   Optional[String]
+					Bio     Optional[*String] `graphql:"bio @include(if: $withBio)"`
+					Company Optional[struct {
+						Name String
+					}]
+					Emails []Optional[String]
+				}
+			}{},
+			inVariables: map[string]interface{}{
+				"withBio": Boolean(true),
+			},
+			want: `query ($withBio:Boolean!){viewer{login,bio @include(if: $withBio),company{name},emails}}`,
+		},
+		{
+			inV: struct {
+				Repository struct {
+					Issues map[string]struct {
+						Title String
+					} `graphql:"issues { first: issue(number: 1) { title }, second: issue(number: 2) { title } }"`
+					Metadata map[string]interface{} `scalar:"true"`
+				} `graphql:"repository(owner: \"golang\", name: \"go\")"`
+			}{},
+			want: `{repository(owner: "golang", name: "go"){issues { first: issue(number: 1) { title }, second: issue(number: 2) { title } },metadata}}`,
+		},
 	}
 	for _, tc := range tests {
 		got, err := ConstructQuery(tc.inV, tc.inVariables, tc.options...)
@@ -350,6 +379,164 @@ func TestConstructQuery(t *testing.T) {
 	}
 }
 
+func TestConstructQuery_mapWithoutSelection(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("got no panic, want a panic for a map field without a selection set")
+		}
+	}()
+	_, _ = ConstructQuery(struct {
+		Issues map[string]struct{ Title String } `graphql:"issues"`
+	}{}, nil)
+}
+
+func TestConstructQuery_namedFragments(t *testing.T) {
+	tests := []struct {
+		inV         interface{}
+		inVariables map[string]interface{}
+		want        string
+	}{
+		{
+			inV: struct {
+				Viewer actorFields
+			}{},
+			want: `{viewer{...ActorFields}}fragment ActorFields on Actor{login,avatarUrl}`,
+		},
+		{
+			inV: struct {
+				Repository struct {
+					Owner actorFields
+					Issue struct {
+						actorFields
+						Title String
+					} `graphql:"issue(number: $number)"`
+				}
+			}{},
+			inVariables: map[string]interface{}{
+				"number": Int(1),
+			},
+			want: `query ($number:Int!){repository{owner{...ActorFields},issue(number: $number){...ActorFields,title}}}fragment ActorFields on Actor{login,avatarUrl}`,
+		},
+		{
+			inV: struct {
+				Node struct {
+					Typename     String `graphql:"__typename"`
+					*issueFields `graphql:"... on Issue"`
+				} `graphql:"node(id: $id)"`
+			}{},
+			inVariables: map[string]interface{}{
+				"id": ID("MDU6SXNzdWUyMzE1MjcyNzk="),
+			},
+			want: `query ($id:ID!){node(id: $id){__typename,... on Issue{...IssueFields}}}fragment IssueFields on Issue{title,author{...ActorFields}}fragment ActorFields on Actor{login,avatarUrl}`,
+		},
+	}
+	for _, tc := range tests {
+		got, err := ConstructQuery(tc.inV, tc.inVariables)
+		if err != nil {
+			t.Error(err)
+		} else if got != tc.want {
+			t.Errorf("\ngot:  %q\nwant: %q\n", got, tc.want)
+		}
+	}
+}
+
+func TestConstructQuery_conflictingFragmentNames(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("got no panic, want a panic for conflicting fragment names")
+		}
+	}()
+	_, _ = ConstructQuery(struct {
+		A actorFields
+		B struct {
+			Fragment otherFragmentNamedActorFields
+		}
+	}{}, nil)
+}
+
+type (
+	searchResult   interface{ isSearchResult() }
+	searchUser     struct{ Login String }
+	searchRepo     struct{ NameWithOwner String }
+	unregisteredIf interface{ isSearchResult() }
+)
+
+func (searchUser) isSearchResult()  {}
+func (*searchRepo) isSearchResult() {}
+func (issueFields) isSearchResult() {}
+
+var searchTypes = NewPossibleTypeRegistry().Register((*searchResult)(nil), map[string]interface{}{
+	"User":       searchUser{},
+	"Repository": &searchRepo{},
+	"Issue":      issueFields{},
+})
+
+func TestConstructQuery_possibleTypes(t *testing.T) {
+	tests := []struct {
+		inV  interface{}
+		want string
+	}{
+		{
+			inV: struct {
+				Search []searchResult `graphql:"search(query: \"go\")"`
+			}{},
+			want: `{search(query: "go"){__typename,...IssueFields,... on Repository{nameWithOwner},... on User{login}}}fragment IssueFields on Issue{title,author{...ActorFields}}fragment ActorFields on Actor{login,avatarUrl}`,
+		},
+		{
+			inV: struct {
+				Node *searchResult
+				Raw  unregisteredIf
+			}{},
+			want: `{node{__typename,...IssueFields,... on Repository{nameWithOwner},... on User{login}},raw}fragment IssueFields on Issue{title,author{...ActorFields}}fragment ActorFields on Actor{login,avatarUrl}`,
+		},
+	}
+	for _, tc := range tests {
+		got, err := ConstructQuery(tc.inV, nil, searchTypes)
+		if err != nil {
+			t.Error(err)
+		} else if got != tc.want {
+			t.Errorf("\ngot:  %q\nwant: %q\n", got, tc.want)
+		}
+	}
+
+	// Registries of other schemas may have other possible types, and
+	// interfaces without possible types are leaves.
+	users := NewPossibleTypeRegistry().Register((*searchResult)(nil), map[string]interface{}{"User": searchUser{}})
+	for _, tc := range []struct {
+		options []Option
+		want    string
+	}{
+		{[]Option{users}, `{node{__typename,... on User{login}}}`},
+		{nil, `{node}`},
+	} {
+		got, err := ConstructQuery(struct{ Node searchResult }{}, nil, tc.options...)
+		if err != nil {
+			t.Error(err)
+		} else if got != tc.want {
+			t.Errorf("\ngot:  %q\nwant: %q\n", got, tc.want)
+		}
+	}
+}
+
+func TestPossibleTypeRegistry_panics(t *testing.T) {
+	for _, tc := range []struct {
+		iface interface{}
+		types map[string]interface{}
+	}{
+		{searchUser{}, nil},
+		{(*searchResult)(nil), map[string]interface{}{"Repository": searchRepo{}}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("got no panic registering %T for %T", tc.types, tc.iface)
+				}
+			}()
+			NewPossibleTypeRegistry().Register(tc.iface, tc.types)
+		}()
+	}
+}
+
 type CreateUser struct {
 	Login string
 }
@@ -641,6 +828,111 @@ func TestConstructSubscription(t *testing.T) {
 	}
 }
 
+func TestConstructQuery_indent(t *testing.T) {
+	var q struct {
+		Repository struct {
+			Issue struct {
+				actorFields `graphql:"author"`
+				Title       String
+			} `graphql:"issue(number: $number)"`
+		} `graphql:"repository(owner: \"octocat\", name: $name)"`
+	}
+	variables := map[string]interface{}{
+		"name":   String("Hello-World"),
+		"number": Int(1),
+	}
+	want := `query GetIssue($name: String!, $number: Int!) {
+  repository(name: $name, owner: "octocat") {
+    issue(number: $number) {
+      author {
+        ...ActorFields
+      }
+      title
+    }
+  }
+}
+
+fragment ActorFields on Actor {
+  login
+  avatarUrl
+}`
+	got, err := ConstructQuery(&q, variables, OperationName("GetIssue"), Indent("  "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s\n", got, want)
+	}
+
+	// The order of options doesn't matter.
+	got, err = ConstructQuery(&q, variables, Indent("  "), OperationName("GetIssue"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s\n", got, want)
+	}
+
+	got, err = ConstructMutation(struct{ Ping String }{}, nil, Indent("\t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "mutation {\n\tping\n}"; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   `{viewer{login,avatarUrl}}`,
+			want: `{viewer{login avatarUrl}}`,
+		},
+		{
+			in: `
+				# Fetch an issue.
+				query GetIssue($number: Int!, $name: String!) @b @a(ttl: 10) {
+					repository(owner: "octocat", name: $name) {
+						issue(number: $number) { ...IssueFields }
+					}
+				}
+				fragment IssueFields on Issue { title, author { ...ActorFields } }
+				fragment ActorFields on Actor { login }
+			`,
+			want: `query GetIssue($name:String!$number:Int!)@b@a(ttl:10){repository(name:$name owner:"octocat"){issue(number:$number){...IssueFields}}}fragment ActorFields on Actor{login}fragment IssueFields on Issue{title author{...ActorFields}}`,
+		},
+		{
+			in:   `query($first: Int = 10, $filter: Filter = {b: [1, 2], a: "\u0041\n"}) { nodes(first: $first, filter: $filter) { ... on Node @skip(if: false) { id } } }`,
+			want: `query($filter:Filter={a:"A\n"b:[1 2]}$first:Int=10){nodes(filter:$filter first:$first){...on Node@skip(if:false){id}}}`,
+		},
+		{
+			in:   "{field(text: \"\"\"\n    Hello,\n      \"world\"\n    \"\"\")}",
+			want: `{field(text:"Hello,\n  \"world\"")}`,
+		},
+	}
+	for _, tc := range tests {
+		got, err := NormalizeQuery(tc.in)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("\ngot:  %q\nwant: %q\n", got, tc.want)
+		}
+		// Normalization is idempotent.
+		if again, err := NormalizeQuery(got); err != nil || again != got {
+			t.Errorf("NormalizeQuery(%q) = %q, %v; want unchanged", got, again, err)
+		}
+	}
+
+	if _, err := NormalizeQuery(`{viewer{login}`); err == nil {
+		t.Error("got nil error for unterminated selection set")
+	}
+}
+
 func TestQueryArguments(t *testing.T) {
 	tests := []struct {
 		in   map[string]interface{}
@@ -683,15 +975,339 @@ func TestQueryArguments(t *testing.T) {
 			in:   map[string]interface{}{"ids": &[]ID{"someID", "anotherID"}},
 			want: `$ids:[ID!]`,
 		},
+		{
+			in:   map[string]interface{}{"direction": orderDirectionAsc, "directions": []*orderDirection{}},
+			want: `$direction:OrderDirection!$directions:[OrderDirection]!`,
+		},
 	}
 	for i, tc := range tests {
-		got := queryArguments(tc.in)
-		if got != tc.want {
+		got, err := queryArguments(tc.in, nil)
+		if err != nil {
+			t.Errorf("test case %d: %v", i, err)
+		} else if got != tc.want {
 			t.Errorf("test case %d:\n got: %q\nwant: %q", i, got, tc.want)
 		}
 	}
 }
 
+func TestQueryArguments_struct(t *testing.T) {
+	type pagination struct {
+		First *Int
+		After *String
+	}
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{
+			in: struct {
+				Owner  String `graphql:"repositoryOwner"`
+				Name   String `graphql:"repositoryName"`
+				Number Int
+			}{},
+			want: "$repositoryOwner:String!$repositoryName:String!$number:Int!",
+		},
+		{
+			in: &struct {
+				States []IssueState
+				Labels *[]String
+				ID     ID
+				Skip   String `graphql:"-"`
+				skip   String
+			}{},
+			want: "$states:[IssueState!]!$labels:[String!]$id:ID!",
+		},
+		{
+			in: struct {
+				pagination
+				Input AddReactionInput
+			}{},
+			want: "$first:Int$after:String$input:AddReactionInput!",
+		},
+		{
+			in:   struct{}{},
+			want: "",
+		},
+	}
+	for i, tc := range tests {
+		got, err := queryArguments(tc.in, nil)
+		if err != nil {
+			t.Errorf("test case %d: %v", i, err)
+		} else if got != tc.want {
+			t.Errorf("test case %d:\n got: %q\nwant: %q", i, got, tc.want)
+		}
+	}
+
+	if _, err := queryArguments([]String{"a"}, nil); err == nil {
+		t.Error("got error: nil, want: non-nil")
+	}
+}
+
+func TestQueryArguments_definitions(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{
+			in: map[string]interface{}{
+				"first":  Variable{Value: (*Int)(nil), DefaultValue: "10"},
+				"states": Variable{Value: []IssueState{IssueStateOpen}, DefaultValue: "[OPEN]", Directives: []string{"@deprecated"}},
+				"owner":  String("shurcooL-test"),
+			},
+			want: "$first:Int = 10$owner:String!$states:[IssueState!]! = [OPEN] @deprecated",
+		},
+		{
+			in: struct {
+				First *Int    `default:"10"`
+				Query *String `graphql:"q" default:"\"is:open\"" directives:"@lowercase @trim"`
+				After *String
+			}{},
+			want: "$first:Int = 10$q:String = \"is:open\" @lowercase @trim$after:String",
+		},
+	}
+	for i, tc := range tests {
+		got, err := queryArguments(tc.in, nil)
+		if err != nil {
+			t.Errorf("test case %d: %v", i, err)
+		} else if got != tc.want {
+			t.Errorf("test case %d:\n got: %q\nwant: %q", i, got, tc.want)
+		}
+	}
+}
+
+func TestVariablesPayload_defaultValues(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want map[string]interface{}
+	}{
+		{
+			in: map[string]interface{}{
+				"first": Variable{Value: (*Int)(nil), DefaultValue: "10"},
+				"last":  Variable{Value: NewInt(5), DefaultValue: "10"},
+				"after": Variable{Value: (*String)(nil)},
+			},
+			want: map[string]interface{}{
+				"last":  NewInt(5),
+				"after": (*String)(nil),
+			},
+		},
+		{
+			in: struct {
+				First *Int `default:"10"`
+				Last  *Int `default:"10"`
+				After *String
+			}{Last: NewInt(5)},
+			want: map[string]interface{}{
+				"last":  Int(5),
+				"after": nil,
+			},
+		},
+	}
+	for i, tc := range tests {
+		got, err := variablesPayload(tc.in, nil)
+		if err != nil {
+			t.Errorf("test case %d: %v", i, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("test case %d:\n got: %#v\nwant: %#v", i, got, tc.want)
+		}
+	}
+}
+
+func TestVariablesPayload(t *testing.T) {
+	type reviewInput struct {
+		Stars      Int
+		Commentary *String `graphql:"commentary"`
+		ClientID   String  `graphql:"-" json:"clientId"`
+		CreatedAt  time.Time
+	}
+	createdAt := time.Date(2021, 10, 18, 0, 0, 0, 0, time.UTC)
+	got, err := variablesPayload(struct {
+		Episode  ReactionContent `graphql:"ep"`
+		Review   reviewInput
+		Reviews  []*reviewInput
+		Optional *Int
+	}{
+		Episode: ReactionContentHooray,
+		Review: reviewInput{
+			Stars:      5,
+			Commentary: NewString("This is a great movie!"),
+			ClientID:   "ignored",
+			CreatedAt:  createdAt,
+		},
+		Reviews: []*reviewInput{{Stars: 1}, nil},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"ep": ReactionContentHooray,
+		"review": map[string]interface{}{
+			"stars":      Int(5),
+			"commentary": String("This is a great movie!"),
+			"createdAt":  createdAt,
+		},
+		"reviews": []interface{}{
+			map[string]interface{}{
+				"stars":      Int(1),
+				"commentary": nil,
+				"createdAt":  time.Time{},
+			},
+			nil,
+		},
+		"optional": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	variables := map[string]interface{}{"a": Int(1)}
+	got, err = variablesPayload(variables, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, variables) {
+		t.Errorf("got: %v, want: %v", got, variables)
+	}
+}
+
+func TestVariablesPayload_omitEmpty(t *testing.T) {
+	type updateInput struct {
+		Name        *String  `graphql:"name,omitempty"`
+		Description *String  `graphql:"description"`
+		Topics      []String `json:"topics,omitempty"`
+		Private     Boolean  `graphql:"private,omitempty"`
+	}
+	tests := []struct {
+		in   interface{}
+		want map[string]interface{}
+	}{
+		{
+			// Nil pointers are sent as null unless they're tagged omitempty.
+			in: struct {
+				Input updateInput `graphql:"input"`
+				After *String     `graphql:"after,omitempty"`
+			}{},
+			want: map[string]interface{}{
+				"input": map[string]interface{}{"description": nil},
+			},
+		},
+		{
+			in: struct {
+				Input updateInput `graphql:"input"`
+				After *String     `graphql:"after,omitempty"`
+			}{
+				Input: updateInput{Name: NewString("go"), Description: NewString(""), Topics: []String{"graphql"}, Private: true},
+				After: NewString("c"),
+			},
+			want: map[string]interface{}{
+				"input": map[string]interface{}{
+					"name":        String("go"),
+					"description": String(""),
+					"topics":      []interface{}{String("graphql")},
+					"private":     Boolean(true),
+				},
+				"after": String("c"),
+			},
+		},
+	}
+	for i, tc := range tests {
+		got, err := variablesPayload(tc.in, nil)
+		if err != nil {
+			t.Errorf("test case %d: %v", i, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("test case %d:\n got: %#v\nwant: %#v", i, got, tc.want)
+		}
+	}
+
+	// Omitted variables are still defined.
+	got, err := ConstructQuery(&struct{ Repository struct{ Name String } }{}, struct {
+		After *String `graphql:"after,omitempty"`
+	}{})
+	if want := `query ($after:String){repository{name}}`; err != nil || got != want {
+		t.Errorf("got %q, %v, want %q", got, err, want)
+	}
+
+	// Values tagged omitempty are defined as nullable, since they may be left
+	// out of the request payload.
+	got, err = ConstructQuery(&struct{ Repository struct{ Name String } }{}, struct {
+		Private Boolean `graphql:"private,omitempty"`
+		First   Int     `graphql:"first"`
+	}{})
+	if want := `query ($private:Boolean$first:Int!){repository{name}}`; err != nil || got != want {
+		t.Errorf("got %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestScalarRegistry(t *testing.T) {
+	type money struct {
+		Cents    int
+		Currency string
+	}
+	scalars := NewScalarRegistry().
+		Register(time.Time{}, Scalar{
+			Name: "DateTime",
+			Encode: func(v interface{}) (interface{}, error) {
+				return v.(time.Time).Format(time.RFC3339), nil
+			},
+		}).
+		Register(money{}, Scalar{Name: "Money"})
+
+	var q struct {
+		Order struct {
+			Total     money
+			CreatedAt *time.Time
+		} `graphql:"order(since: $since, until: $until)"`
+	}
+	variables := map[string]interface{}{
+		"since": time.Date(2021, 10, 18, 0, 0, 0, 0, time.UTC),
+		"until": (*time.Time)(nil),
+		"dates": []time.Time{time.Date(2021, 10, 19, 0, 0, 0, 0, time.UTC)},
+	}
+	got, err := ConstructQuery(&q, variables, scalars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `query ($dates:[DateTime!]!$since:DateTime!$until:DateTime){order(since: $since, until: $until){total,createdAt}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q", got, want)
+	}
+
+	payload, err := variablesPayload(variables, scalars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"dates":["2021-10-19T00:00:00Z"],"since":"2021-10-18T00:00:00Z","until":null}`; got != want {
+		t.Errorf("\ngot:  %s\nwant: %s", got, want)
+	}
+
+	payload, err = variablesPayload(struct {
+		Since time.Time
+		Total money
+	}{time.Date(2021, 10, 18, 0, 0, 0, 0, time.UTC), money{100, "EUR"}}, scalars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"since":"2021-10-18T00:00:00Z","total":{"cents":100,"currency":"EUR"}}`; got != want {
+		t.Errorf("\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestScalarRegistry_Register_panics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Register didn't panic on a scalar without name")
+		}
+	}()
+	NewScalarRegistry().Register(time.Time{}, Scalar{})
+}
+
 // Custom GraphQL types for testing.
 type (
 	// DateTime is an ISO-8601 encoded UTC date.
@@ -725,6 +1341,21 @@ const (
 	ReactionContentHeart      ReactionContent = "HEART"       // Represents the ❤️ emoji.
 )
 
+// orderDirection is the OrderDirection enum, named after its GraphQL type.
+type orderDirection string
+
+const (
+	orderDirectionAsc  orderDirection = "ASC"
+	orderDirectionDesc orderDirection = "DESC"
+)
+
+var _ Enum = orderDirectionAsc
+
+func (orderDirection) GetGraphQLType() string { return "OrderDirection" }
+func (orderDirection) EnumValues() []string {
+	return []string{string(orderDirectionAsc), string(orderDirectionDesc)}
+}
+
 // AddReactionInput is an autogenerated input type of AddReaction.
 type AddReactionInput struct {
 	// The Node ID of the subject to modify. (Required.)
@@ -735,3 +1366,29 @@ type AddReactionInput struct {
 	// A unique identifier for the client performing the mutation. (Optional.)
 	ClientMutationID *String `json:"clientMutationId,omitempty"`
 }
+
+// actorFields is a named fragment on the Actor type.
+type actorFields struct {
+	Login     String
+	AvatarURL URI `graphql:"avatarUrl"`
+}
+
+func (actorFields) FragmentName() string  { return "ActorFields" }
+func (actorFields) TypeCondition() string { return "Actor" }
+
+// issueFields is a named fragment on the Issue type that spreads another named fragment.
+type issueFields struct {
+	Title  String
+	Author actorFields
+}
+
+func (*issueFields) FragmentName() string  { return "IssueFields" }
+func (*issueFields) TypeCondition() string { return "Issue" }
+
+// otherFragmentNamedActorFields reuses the name of actorFields for a different type.
+type otherFragmentNamedActorFields struct {
+	Name String
+}
+
+func (otherFragmentNamedActorFields) FragmentName() string  { return "ActorFields" }
+func (otherFragmentNamedActorFields) TypeCondition() string { return "Actor" }
@@ -1,5 +1,13 @@
 package graphql
 
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/phoban01/go-graphql-client/internal/jsonutil"
+)
+
 // Note: These custom types are meant to be used in queries for now.
 // But the plan is to switch to using native Go types (string, int, bool, time.Time, etc.).
 // See https://github.com/shurcooL/githubv4/issues/9 for details.
@@ -49,3 +57,159 @@ func NewInt(v Int) *Int { return &v }
 
 // NewString is a helper to make a new *String.
 func NewString(v String) *String { return &v }
+
+// GraphQLType is implemented by Go types that name their GraphQL type, such
+// as the types of package scalars. The name is used in the definitions of
+// variables of the type, rather than the name of the Go type.
+//
+// E.g., map[string]interface{}{"since": scalars.DateTime{}} -> "$since:DateTime!".
+type GraphQLType interface {
+	// GetGraphQLType returns the name of the GraphQL type, e.g. "DateTime".
+	// It's called on the zero value of the Go type.
+	GetGraphQLType() string
+}
+
+var graphQLTypeType = reflect.TypeOf((*GraphQLType)(nil)).Elem()
+
+// Enum is implemented by the Go types of GraphQL enums, usually string types
+// with a constant for each value of the enum, as generated by graphql-gen.
+// As a GraphQLType, an Enum is named after its GraphQL type in variable
+// definitions. Responses fail to decode if they hold another value of the
+// enum, e.g. one added to the schema after the Go type was written.
+//
+// E.g., map[string]interface{}{"direction": OrderDirectionAsc} -> "$direction:OrderDirection!".
+type Enum = jsonutil.Enum
+
+// Scalar describes how the values of a Go type are sent and received as a
+// custom GraphQL scalar, see ScalarRegistry.
+type Scalar struct {
+	// Name is the GraphQL name of the scalar, e.g. "DateTime", used in the
+	// variable definitions of the type.
+	Name string
+	// Encode returns the value that v, a value of the Go type, is encoded
+	// to JSON as in variables, e.g. a string. If it's nil, v is encoded as
+	// is.
+	Encode func(v interface{}) (interface{}, error)
+	// Decode decodes the JSON value data into v, a pointer to a value of the
+	// Go type. If it's nil, values are decoded like the other leaf values.
+	Decode func(data []byte, v interface{}) error
+}
+
+// ScalarRegistry maps Go types to the custom GraphQL scalars they represent.
+// It's used by Client.WithScalars, and given as an option to ConstructQuery
+// and the other operation methods, to name the scalars in the variable
+// definitions, to encode the variables of their Go types, and to decode
+// them in responses, where they're leaf fields.
+//
+// E.g., for a registry where time.Time is registered as the DateTime scalar:
+//
+//	map[string]interface{}{"since": time.Time{}} -> "$since:DateTime!".
+type ScalarRegistry struct {
+	scalars  map[reflect.Type]Scalar
+	decoders map[reflect.Type]func(data []byte, v interface{}) error
+}
+
+// NewScalarRegistry returns an empty ScalarRegistry.
+func NewScalarRegistry() *ScalarRegistry {
+	return &ScalarRegistry{
+		scalars:  make(map[reflect.Type]Scalar),
+		decoders: make(map[reflect.Type]func(data []byte, v interface{}) error),
+	}
+}
+
+// Register registers the Go type of v as the custom scalar s, and returns
+// the registry. It panics if s has no name. A registry shouldn't be changed
+// once it's in use.
+func (r *ScalarRegistry) Register(v interface{}, s Scalar) *ScalarRegistry {
+	if s.Name == "" {
+		panic(fmt.Sprintf("scalar of type %T has no name", v))
+	}
+	t := reflect.TypeOf(v)
+	r.scalars[t] = s
+	if s.Decode != nil {
+		r.decoders[t] = s.Decode
+	}
+	return r
+}
+
+// lookup returns the scalar that t is registered as.
+func (r *ScalarRegistry) lookup(t reflect.Type) (Scalar, bool) {
+	if r == nil {
+		return Scalar{}, false
+	}
+	s, ok := r.scalars[t]
+	return s, ok
+}
+
+// hasEncoders reports whether any registered scalar has an Encode function.
+func (r *ScalarRegistry) hasEncoders() bool {
+	if r == nil {
+		return false
+	}
+	for _, s := range r.scalars {
+		if s.Encode != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// encodes reports whether values of t are encoded with a registered scalar,
+// either because t is registered or because it's a pointer to, or a list
+// of, such values.
+func (r *ScalarRegistry) encodes(t reflect.Type) bool {
+	for {
+		if s, ok := r.lookup(t); ok {
+			return s.Encode != nil
+		}
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+// decoderOptions returns the options of jsonutil decoding the registered
+// scalars.
+func (r *ScalarRegistry) decoderOptions() []jsonutil.Option {
+	if r == nil || len(r.decoders) == 0 {
+		return nil
+	}
+	return []jsonutil.Option{jsonutil.ScalarDecoders(r.decoders)}
+}
+
+func (r *ScalarRegistry) Type() OptionType {
+	return optionTypeScalars
+}
+
+func (r *ScalarRegistry) String() string {
+	return ""
+}
+
+// scalarsOption returns the last ScalarRegistry of options, if any.
+func scalarsOption(options []Option) *ScalarRegistry {
+	var scalars *ScalarRegistry
+	for _, option := range options {
+		if option.Type() == optionTypeScalars {
+			scalars = option.(*ScalarRegistry)
+		}
+	}
+	return scalars
+}
+
+// encodedScalar is a value of a registered scalar, encoded to JSON with the
+// Encode function of the scalar.
+type encodedScalar struct {
+	v      interface{}
+	encode func(v interface{}) (interface{}, error)
+}
+
+func (e encodedScalar) MarshalJSON() ([]byte, error) {
+	v, err := e.encode(e.v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,126 @@
+package scalars
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+// BigInt is the BigInt scalar, an integer of arbitrary size. It's encoded
+// as a string, e.g. "12345678901234567890", so that it keeps its precision
+// in JSON parsers that read numbers as floats, and decoded from a string or
+// a number.
+type BigInt struct{ *big.Int }
+
+// NewBigInt is a helper to make a new *BigInt.
+func NewBigInt(x *big.Int) *BigInt { return &BigInt{x} }
+
+func (BigInt) GetGraphQLType() string { return "BigInt" }
+
+func (x BigInt) MarshalJSON() ([]byte, error) {
+	if x.Int == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(x.Int.String())
+}
+
+func (x *BigInt) UnmarshalJSON(data []byte) error {
+	s, ok, err := unmarshalNumber(data, "BigInt")
+	if !ok || err != nil {
+		return err
+	}
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fmt.Errorf("invalid BigInt %q", s)
+	}
+	x.Int = i
+	return nil
+}
+
+// Decimal is the Decimal scalar, a decimal number of arbitrary precision,
+// held in its decimal representation, e.g. Decimal("12.34"). It's encoded
+// as a JSON number, and decoded from a number or a string.
+type Decimal string
+
+// NewDecimal is a helper to make a new *Decimal.
+func NewDecimal(v Decimal) *Decimal { return &v }
+
+func (Decimal) GetGraphQLType() string { return "Decimal" }
+
+// Rat returns the value of d, and reports whether d is a valid decimal.
+func (d Decimal) Rat() (*big.Rat, bool) {
+	if !decimalPattern.MatchString(string(d)) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(string(d))
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if d == "" {
+		return []byte("null"), nil
+	}
+	if !decimalPattern.MatchString(string(d)) {
+		return nil, fmt.Errorf("invalid Decimal %q", string(d))
+	}
+	return []byte(d), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s, ok, err := unmarshalNumber(data, "Decimal")
+	if !ok || err != nil {
+		return err
+	}
+	if !decimalPattern.MatchString(s) {
+		return fmt.Errorf("invalid Decimal %q", s)
+	}
+	*d = Decimal(s)
+	return nil
+}
+
+// decimalPattern matches decimal numbers, in the syntax of JSON numbers.
+var decimalPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// Long is the Long scalar, a 64-bit signed integer. It's encoded as a JSON
+// number, and decoded from a number or a string.
+type Long int64
+
+// NewLong is a helper to make a new *Long.
+func NewLong(v Long) *Long { return &v }
+
+func (Long) GetGraphQLType() string { return "Long" }
+
+func (l Long) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(l), 10), nil
+}
+
+func (l *Long) UnmarshalJSON(data []byte) error {
+	s, ok, err := unmarshalNumber(data, "Long")
+	if !ok || err != nil {
+		return err
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid Long %q: %w", s, err)
+	}
+	*l = Long(i)
+	return nil
+}
+
+// unmarshalNumber returns the JSON number data, or the content of the JSON
+// string data, of a value of the scalar name. It reports false, without
+// error, if data is null.
+func unmarshalNumber(data []byte, name string) (string, bool, error) {
+	if isNull(data) {
+		return "", false, nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		return unmarshalString(data, name)
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return "", false, fmt.Errorf("cannot decode %s into %s, want a number or a string", data, name)
+	}
+	return n.String(), true, nil
+}
@@ -0,0 +1,137 @@
+// Package scalars provides Go types for widely used custom GraphQL scalars:
+// DateTime, Date, Time, BigInt, Decimal, Long, URI, JSON and UUID.
+//
+// Each type encodes and decodes its values in the JSON format of the scalar,
+// and names its GraphQL type in the definitions of variables, as
+// graphql.GraphQLType:
+//
+//	var q struct {
+//		Events []struct {
+//			Name  graphql.String
+//			Start scalars.DateTime
+//		} `graphql:"events(since: $since)"`
+//	}
+//	variables := map[string]interface{}{
+//		"since": scalars.NewDateTime(time.Now()),
+//	}
+//	// query ($since:DateTime){events(since: $since){name,start}}
+//
+// Empty values, such as a zero DateTime or a BigInt without *big.Int, are
+// encoded as null, and null values leave the decoded value unchanged, i.e.
+// zero in query structs. A server whose scalars have other names or formats
+// can use graphql.ScalarRegistry instead.
+package scalars
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// URI is the URI scalar, a URI encoded as a string, e.g.
+// "https://example.com/a?b=c".
+type URI struct{ *url.URL }
+
+// NewURI is a helper to make a new *URI.
+func NewURI(u *url.URL) *URI { return &URI{u} }
+
+func (URI) GetGraphQLType() string { return "URI" }
+
+func (u URI) MarshalJSON() ([]byte, error) {
+	if u.URL == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(u.URL.String())
+}
+
+func (u *URI) UnmarshalJSON(data []byte) error {
+	s, ok, err := unmarshalString(data, "URI")
+	if !ok || err != nil {
+		return err
+	}
+	parsed, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid URI %q: %w", s, err)
+	}
+	u.URL = parsed
+	return nil
+}
+
+// JSON is the JSON scalar, an arbitrary JSON value, kept in its raw encoded
+// form like json.RawMessage. E.g. JSON(`{"a":1}`).
+type JSON json.RawMessage
+
+// NewJSON is a helper to make a new *JSON.
+func NewJSON(v JSON) *JSON { return &v }
+
+func (JSON) GetGraphQLType() string { return "JSON" }
+
+// Unmarshal decodes the JSON value into v, like json.Unmarshal.
+func (j JSON) Unmarshal(v interface{}) error {
+	return json.Unmarshal(j, v)
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return json.RawMessage(j).MarshalJSON()
+}
+
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	if isNull(data) {
+		return nil
+	}
+	*j = append((*j)[:0], data...)
+	return nil
+}
+
+// UUID is the UUID scalar, a UUID encoded as a string, e.g.
+// "f47ac10b-58cc-0372-8567-0e02b2c3d479". The zero UUID, uuid.Nil, is encoded
+// as null.
+type UUID struct{ uuid.UUID }
+
+// NewUUID is a helper to make a new *UUID.
+func NewUUID(id uuid.UUID) *UUID { return &UUID{id} }
+
+func (UUID) GetGraphQLType() string { return "UUID" }
+
+func (id UUID) MarshalJSON() ([]byte, error) {
+	if id.UUID == uuid.Nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(id.UUID.String())
+}
+
+func (id *UUID) UnmarshalJSON(data []byte) error {
+	s, ok, err := unmarshalString(data, "UUID")
+	if !ok || err != nil {
+		return err
+	}
+	parsed, err := uuid.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid UUID %q: %w", s, err)
+	}
+	id.UUID = parsed
+	return nil
+}
+
+// unmarshalString decodes the JSON string data of a value of the scalar
+// name. It reports false, without error, if data is null.
+func unmarshalString(data []byte, name string) (string, bool, error) {
+	if isNull(data) {
+		return "", false, nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return "", false, fmt.Errorf("cannot decode %s into %s, want a string", data, name)
+	}
+	return s, true, nil
+}
+
+// isNull reports whether the JSON value data is null.
+func isNull(data []byte) bool {
+	return string(data) == "null"
+}
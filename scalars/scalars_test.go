@@ -0,0 +1,163 @@
+package scalars_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/phoban01/go-graphql-client"
+	"github.com/phoban01/go-graphql-client/scalars"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	u, _ := url.Parse("https://example.com/a?b=c")
+	big, _ := new(big.Int).SetString("12345678901234567890", 10)
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{scalars.DateTime{time.Date(2021, 10, 18, 12, 30, 0, 500, time.UTC)}, `"2021-10-18T12:30:00.0000005Z"`},
+		{scalars.DateTime{}, `null`},
+		{scalars.Date{time.Date(2021, 10, 18, 12, 30, 0, 0, time.UTC)}, `"2021-10-18"`},
+		{scalars.Time{time.Date(0, 1, 1, 12, 30, 0, 0, time.FixedZone("", 2*60*60))}, `"12:30:00+02:00"`},
+		{scalars.BigInt{big}, `"12345678901234567890"`},
+		{scalars.BigInt{}, `null`},
+		{scalars.Decimal("-12.340"), `-12.340`},
+		{scalars.Long(-9007199254740993), `-9007199254740993`},
+		{scalars.URI{u}, `"https://example.com/a?b=c"`},
+		{scalars.JSON(`{"a": [1, true]}`), `{"a":[1,true]}`},
+		{scalars.JSON(nil), `null`},
+		{scalars.UUID{uuid.MustParse("f47ac10b-58cc-0372-8567-0e02b2c3d479")}, `"f47ac10b-58cc-0372-8567-0e02b2c3d479"`},
+		{scalars.UUID{}, `null`},
+	}
+	for _, tc := range tests {
+		got, err := json.Marshal(tc.in)
+		if err != nil {
+			t.Errorf("%T: %v", tc.in, err)
+		} else if string(got) != tc.want {
+			t.Errorf("%T: got %s, want %s", tc.in, got, tc.want)
+		}
+	}
+
+	if _, err := json.Marshal(scalars.Decimal("1,5")); err == nil {
+		t.Error("got no error for an invalid Decimal")
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	type query struct {
+		DateTime scalars.DateTime
+		Date     scalars.Date
+		Time     []scalars.Time
+		BigInt   scalars.BigInt
+		Decimals []scalars.Decimal
+		Long     scalars.Long
+		URI      *scalars.URI
+		JSON     scalars.JSON
+		UUID     scalars.UUID
+		Null     scalars.DateTime
+	}
+	var got query
+	err := graphql.UnmarshalGraphQL([]byte(`{
+		"dateTime": "2021-10-18T12:30:00.5+02:00",
+		"date": "2021-10-18",
+		"time": ["12:30:00Z", "12:30:00.5"],
+		"bigInt": 12345678901234567890,
+		"decimals": [12.340, "-1e-3"],
+		"long": "-9007199254740993",
+		"uri": "https://example.com/a?b=c",
+		"json": {"a": [1, true]},
+		"uuid": "f47ac10b-58cc-0372-8567-0e02b2c3d479",
+		"null": null
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2021, 10, 18, 10, 30, 0, 5e8, time.UTC); !got.DateTime.Equal(want) {
+		t.Errorf("got DateTime %v, want %v", got.DateTime, want)
+	}
+	if want := time.Date(2021, 10, 18, 0, 0, 0, 0, time.UTC); !got.Date.Equal(want) {
+		t.Errorf("got Date %v, want %v", got.Date, want)
+	}
+	if want := []time.Time{
+		time.Date(0, 1, 1, 12, 30, 0, 0, time.UTC),
+		time.Date(0, 1, 1, 12, 30, 0, 5e8, time.UTC),
+	}; len(got.Time) != 2 || !got.Time[0].Equal(want[0]) || !got.Time[1].Equal(want[1]) {
+		t.Errorf("got Time %v, want %v", got.Time, want)
+	}
+	if got, want := got.BigInt.String(), "12345678901234567890"; got != want {
+		t.Errorf("got BigInt %v, want %v", got, want)
+	}
+	if want := []scalars.Decimal{"12.340", "-1e-3"}; !reflect.DeepEqual(got.Decimals, want) {
+		t.Errorf("got Decimals %v, want %v", got.Decimals, want)
+	}
+	if r, ok := got.Decimals[1].Rat(); !ok || r.Cmp(big.NewRat(-1, 1000)) != 0 {
+		t.Errorf("got Rat %v, %v, want -1/1000", r, ok)
+	}
+	if got, want := got.Long, scalars.Long(-9007199254740993); got != want {
+		t.Errorf("got Long %v, want %v", got, want)
+	}
+	if got.URI == nil || got.URI.Host != "example.com" {
+		t.Errorf("got URI %v, want https://example.com/a?b=c", got.URI)
+	}
+	var v map[string]interface{}
+	if err := got.JSON.Unmarshal(&v); err != nil {
+		t.Error(err)
+	} else if want := map[string]interface{}{"a": []interface{}{1.0, true}}; !reflect.DeepEqual(v, want) {
+		t.Errorf("got JSON %v, want %v", v, want)
+	}
+	if got, want := got.UUID.String(), "f47ac10b-58cc-0372-8567-0e02b2c3d479"; got != want {
+		t.Errorf("got UUID %v, want %v", got, want)
+	}
+	if !got.Null.IsZero() {
+		t.Errorf("got non-zero Null %v", got.Null)
+	}
+}
+
+func TestUnmarshalJSON_invalid(t *testing.T) {
+	tests := []struct {
+		data string
+		v    json.Unmarshaler
+		want string
+	}{
+		{`"2021-10-18"`, new(scalars.DateTime), `invalid DateTime "2021-10-18"`},
+		{`20211018`, new(scalars.Date), `cannot decode 20211018 into Date, want a string`},
+		{`"1.5"`, new(scalars.BigInt), `invalid BigInt "1.5"`},
+		{`"0x10"`, new(scalars.Decimal), `invalid Decimal "0x10"`},
+		{`true`, new(scalars.Long), `cannot decode true into Long, want a number or a string`},
+		{`"x"`, new(scalars.UUID), `invalid UUID "x": invalid UUID length: 1`},
+	}
+	for _, tc := range tests {
+		if err := tc.v.UnmarshalJSON([]byte(tc.data)); err == nil || err.Error() != tc.want {
+			t.Errorf("%T: got error %v, want %s", tc.v, err, tc.want)
+		}
+	}
+}
+
+func TestConstructQuery(t *testing.T) {
+	var q struct {
+		Events []struct {
+			Start    scalars.DateTime
+			Duration scalars.Long
+			Data     scalars.JSON
+		} `graphql:"events(since: $since, ids: $ids, page: $page, filter: $filter)"`
+	}
+	variables := map[string]interface{}{
+		"since":  scalars.NewDateTime(time.Now()),
+		"ids":    []scalars.UUID{},
+		"page":   scalars.BigInt{},
+		"filter": scalars.JSON(`{}`),
+	}
+	got, err := graphql.ConstructQuery(&q, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `query ($filter:JSON!$ids:[UUID!]!$page:BigInt!$since:DateTime){events(since: $since, ids: $ids, page: $page, filter: $filter){start,duration,data}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q", got, want)
+	}
+}
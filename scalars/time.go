@@ -0,0 +1,94 @@
+package scalars
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Layouts of the time scalars.
+const (
+	dateTimeLayout = time.RFC3339Nano
+	dateLayout     = "2006-01-02"
+	timeLayout     = "15:04:05.999999999Z07:00"
+	// localTimeLayout is the layout of times without offset, which are
+	// decoded in UTC.
+	localTimeLayout = "15:04:05.999999999"
+)
+
+// DateTime is the DateTime scalar, a date and time encoded in RFC 3339
+// format, e.g. "2021-10-18T12:30:00Z".
+type DateTime struct{ time.Time }
+
+// NewDateTime is a helper to make a new *DateTime.
+func NewDateTime(t time.Time) *DateTime { return &DateTime{t} }
+
+func (DateTime) GetGraphQLType() string { return "DateTime" }
+
+func (t DateTime) MarshalJSON() ([]byte, error) {
+	return marshalTime(t.Time, dateTimeLayout)
+}
+
+func (t *DateTime) UnmarshalJSON(data []byte) error {
+	return unmarshalTime(data, "DateTime", &t.Time, time.RFC3339)
+}
+
+// Date is the Date scalar, a calendar date encoded in RFC 3339 full-date
+// format, e.g. "2021-10-18". Decoded dates are at midnight UTC.
+type Date struct{ time.Time }
+
+// NewDate is a helper to make a new *Date.
+func NewDate(t time.Time) *Date { return &Date{t} }
+
+func (Date) GetGraphQLType() string { return "Date" }
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return marshalTime(d.Time, dateLayout)
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	return unmarshalTime(data, "Date", &d.Time, dateLayout)
+}
+
+// Time is the Time scalar, a time of day encoded in RFC 3339 full-time
+// format, e.g. "12:30:00Z" or "12:30:00.5+02:00". Times without offset are
+// decoded in UTC. The date of the time.Time is ignored when encoding.
+type Time struct{ time.Time }
+
+// NewTime is a helper to make a new *Time.
+func NewTime(t time.Time) *Time { return &Time{t} }
+
+func (Time) GetGraphQLType() string { return "Time" }
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return marshalTime(t.Time, timeLayout)
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	return unmarshalTime(data, "Time", &t.Time, timeLayout, localTimeLayout)
+}
+
+// marshalTime encodes t as a JSON string in layout, or null if t is zero.
+func marshalTime(t time.Time, layout string) ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Format(layout))
+}
+
+// unmarshalTime decodes the JSON string data of a value of the scalar name
+// into t, parsing it in the first of layouts that matches. Null leaves t
+// unchanged.
+func unmarshalTime(data []byte, name string, t *time.Time, layouts ...string) error {
+	s, ok, err := unmarshalString(data, name)
+	if !ok || err != nil {
+		return err
+	}
+	for _, layout := range layouts {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			*t = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid %s %q", name, s)
+}
@@ -0,0 +1,156 @@
+// Package schema validates GraphQL queries against a schema, loaded from an
+// introspection result or from SDL.
+//
+// It catches mistakes in graphql struct tags, such as mistyped field names or
+// missing arguments, before the query is sent:
+//
+//	s, err := schema.LoadFile("schema.graphql")
+//	if err != nil {
+//		// Handle error.
+//	}
+//	if err := s.ValidateQuery(&q, variables); err != nil {
+//		// err lists each problem with the Go path of the offending field,
+//		// e.g. "Repository.Issue.Titel: field "titel" is not defined on type "Issue"".
+//	}
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	graphqlserver "github.com/graph-gophers/graphql-go"
+	"github.com/phoban01/go-graphql-client"
+	"github.com/phoban01/go-graphql-client/internal/language"
+	"github.com/phoban01/go-graphql-client/introspection"
+)
+
+// Schema is a GraphQL schema that queries can be validated against.
+type Schema struct {
+	*introspection.Schema
+}
+
+// New returns the schema described by an introspection result.
+func New(s *introspection.Schema) *Schema {
+	return &Schema{Schema: s}
+}
+
+// Load parses a schema from data, which is either an introspection result
+// in JSON or a schema in the GraphQL schema definition language (SDL).
+func Load(data []byte) (*Schema, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		s, err := introspection.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("schema: %w", err)
+		}
+		return New(s), nil
+	}
+	parsed, err := graphqlserver.ParseSchema(string(data), nil)
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	js, err := parsed.ToJSON()
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	s, err := introspection.Parse(js)
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	return New(s), nil
+}
+
+// LoadFile reads a schema from the named file, see Load.
+func LoadFile(name string) (*Schema, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Load(data)
+}
+
+// ValidateQuery validates the query built from q and variables, as
+// rendered by graphql.ConstructQuery. The returned error, if any, is of
+// type Errors, and the paths of its errors are Go field paths within q.
+func (s *Schema) ValidateQuery(q interface{}, variables interface{}, options ...graphql.Option) error {
+	query, err := graphql.ConstructQuery(q, variables, options...)
+	if err != nil {
+		return err
+	}
+	return s.validate(query, reflect.TypeOf(q))
+}
+
+// ValidateMutation validates the mutation built from m and variables, as
+// rendered by graphql.ConstructMutation. See ValidateQuery.
+func (s *Schema) ValidateMutation(m interface{}, variables interface{}, options ...graphql.Option) error {
+	query, err := graphql.ConstructMutation(m, variables, options...)
+	if err != nil {
+		return err
+	}
+	return s.validate(query, reflect.TypeOf(m))
+}
+
+// ValidateSubscription validates the subscription built from v and
+// variables, as rendered by graphql.ConstructSubscription. See ValidateQuery.
+func (s *Schema) ValidateSubscription(v interface{}, variables interface{}, options ...graphql.Option) error {
+	query, err := graphql.ConstructSubscription(v, variables, options...)
+	if err != nil {
+		return err
+	}
+	return s.validate(query, reflect.TypeOf(v))
+}
+
+// ValidateDocument validates a GraphQL document. The returned error, if
+// any, is either a syntax error or of type Errors, and the paths of its
+// errors are response paths, e.g. "repository.issue.title".
+func (s *Schema) ValidateDocument(query string) error {
+	return s.validate(query, nil)
+}
+
+// ValidateDefinitions validates the operations and fragment definitions of
+// a GraphQL document. Unlike ValidateDocument, it accepts fragments that
+// aren't used by the operations of the document, and validates them on
+// their own, as in the documents read by code generators.
+func (s *Schema) ValidateDefinitions(query string) error {
+	doc, err := language.Parse(query)
+	if err != nil {
+		return err
+	}
+	v := &validator{schema: s.Schema, doc: doc, unusedFragments: true}
+	v.document(nil)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// ValidateSelections validates a GraphQL document whose variable
+// definitions aren't known, such as the selections of a query struct whose
+// variables are only given at run time. Variables that the operations use
+// without defining them are assumed to have the type their usages expect.
+func (s *Schema) ValidateSelections(query string) error {
+	doc, err := language.Parse(query)
+	if err != nil {
+		return err
+	}
+	v := &validator{schema: s.Schema, doc: doc, implicitVariables: true}
+	v.document(nil)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+func (s *Schema) validate(query string, t reflect.Type) error {
+	doc, err := language.Parse(query)
+	if err != nil {
+		return err
+	}
+	v := &validator{schema: s.Schema, doc: doc}
+	v.document(t)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/phoban01/go-graphql-client"
+	"github.com/phoban01/go-graphql-client/schema"
+)
+
+const sdl = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+type Query {
+	repository(owner: String!, name: String!): Repository
+	node(id: ID!): Node
+	viewer: User!
+}
+
+interface Node {
+	id: ID!
+}
+
+type User implements Node {
+	id: ID!
+	login: String!
+	avatarUrl(size: Int = 40): String!
+}
+
+type Repository implements Node {
+	id: ID!
+	name: String!
+	owner: User!
+	issue(number: Int!): Issue
+	issues(first: Int, states: [IssueState!], orderBy: IssueOrder): [Issue!]!
+}
+
+type Issue implements Node {
+	id: ID!
+	title: String!
+	author: User
+}
+
+enum IssueState {
+	OPEN
+	CLOSED
+}
+
+input IssueOrder {
+	field: IssueOrderField!
+	direction: Direction!
+}
+
+enum IssueOrderField {
+	CREATED_AT
+}
+
+enum Direction {
+	ASC
+	DESC
+}
+
+type Mutation {
+	addStar(input: AddStarInput!): AddStarPayload
+}
+
+input AddStarInput {
+	starrableId: ID!
+}
+
+type AddStarPayload {
+	starrable: Node
+}
+`
+
+type userFields struct {
+	Login     graphql.String
+	AvatarURL graphql.String `graphql:"avatarUrl(size: 72)"`
+}
+
+func (userFields) FragmentName() string  { return "UserFields" }
+func (userFields) TypeCondition() string { return "User" }
+
+func TestSchema_ValidateQuery(t *testing.T) {
+	s, err := schema.Load([]byte(sdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var q struct {
+		Viewer     userFields
+		Repository struct {
+			Owner  userFields
+			Issues []struct {
+				Title  graphql.String
+				Author *struct {
+					userFields
+				}
+			} `graphql:"issues(first: $first, states: [OPEN], orderBy: {field: CREATED_AT, direction: DESC})"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+		Node struct {
+			Typename string `graphql:"__typename"`
+			Issue    struct {
+				Title graphql.String
+			} `graphql:"... on Issue"`
+		} `graphql:"node(id: $id)"`
+	}
+	variables := map[string]interface{}{
+		"owner": graphql.String("octocat"),
+		"name":  graphql.String("Hello-World"),
+		"first": (*graphql.Int)(nil),
+		"id":    graphql.ID("MDU6SXNzdWUx"),
+	}
+	if err := s.ValidateQuery(&q, variables); err != nil {
+		t.Errorf("got error: %v", err)
+	}
+}
+
+func TestSchema_ValidateQuery_errors(t *testing.T) {
+	s, err := schema.Load([]byte(sdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var q struct {
+		Viewer struct {
+			Login struct {
+				Length graphql.Int
+			}
+		}
+		Repository struct {
+			Owner graphql.String
+			Issue struct {
+				Titel graphql.String
+			} `graphql:"issue(number: $number)"`
+			Issues []struct {
+				ID graphql.ID
+			} `graphql:"issues(limit: 10, states: [MERGED])"`
+		} `graphql:"repository(name: $name)"`
+	}
+	variables := struct {
+		Name   graphql.String
+		Number graphql.String
+	}{}
+	err = s.ValidateQuery(&q, variables)
+	errs, ok := err.(schema.Errors)
+	if !ok {
+		t.Fatalf("got error %v of type %T, want schema.Errors", err, err)
+	}
+	want := []string{
+		`Viewer.Login: field "login" of type "String!" must not have a selection set`,
+		`Repository: missing required argument "owner" of type "String!" on field "repository"`,
+		`Repository.Owner: field "owner" of type "User!" must have a selection of subfields`,
+		`Repository.Issue: variable "$number" of type "String!" can't be used where "Int!" is expected`,
+		`Repository.Issue.Titel: field "titel" is not defined on type "Issue"`,
+		`Repository.Issues: unknown argument "limit" on field "issues"`,
+		`Repository.Issues: value MERGED is not defined by enum "IssueState"`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%v", len(errs), len(want), err)
+	}
+	for i := range want {
+		if got := errs[i].Error(); got != want[i] {
+			t.Errorf("error %d:\ngot:  %s\nwant: %s", i, got, want[i])
+		}
+	}
+}
+
+func TestSchema_ValidateMutation(t *testing.T) {
+	s, err := schema.Load([]byte(sdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type AddStarInput struct {
+		StarrableID graphql.ID `graphql:"starrableId"`
+	}
+	var m struct {
+		AddStar struct {
+			Starrable struct {
+				ID graphql.ID
+			}
+		} `graphql:"addStar(input: $input)"`
+	}
+	if err := s.ValidateMutation(&m, map[string]interface{}{"input": AddStarInput{}}); err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	if err := s.ValidateMutation(&m, map[string]interface{}{"input": &AddStarInput{}}); err == nil {
+		t.Error("got nil error for a nullable variable used as a non-null argument")
+	}
+	if err := s.ValidateSubscription(&m, map[string]interface{}{"input": AddStarInput{}}); err == nil {
+		t.Error("got nil error for a subscription on a schema without subscriptions")
+	}
+}
+
+func TestSchema_ValidateDocument(t *testing.T) {
+	s, err := schema.Load([]byte(sdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.ValidateDocument(`
+		query($id: ID!, $unused: Int) {
+			node(id: $id) {
+				... on Repository { ...Missing }
+				... on Foo { id }
+			}
+			viewer { ...UserFields }
+		}
+		fragment UserFields on User { login, email }
+		fragment Unused on User { id }
+	`)
+	want := `node.... on Repository: fragment "Missing" is not defined
+node.... on Foo: fragment type condition "Foo" is not defined
+viewer.email: field "email" is not defined on type "User"
+variable "$unused" is never used
+fragment "Unused" is never used`
+	if err == nil {
+		t.Fatal("got nil error")
+	}
+	if got := err.Error(); got != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if e := err.(schema.Errors)[0]; e.Line != 4 || e.Column != 25 {
+		t.Errorf("got position %d:%d, want %d:%d", e.Line, e.Column, 4, 25)
+	}
+}
+
+func TestLoad_introspection(t *testing.T) {
+	s, err := schema.Load([]byte(sdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"data": map[string]interface{}{"__schema": s.Schema},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = schema.Load(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateDocument(`{viewer{login}}`); err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	if err := s.ValidateDocument(`{viewer{name}}`); err == nil {
+		t.Error("got nil error for unknown field")
+	}
+}
+
+func TestSchema_ValidateDefinitions(t *testing.T) {
+	s, err := schema.Load([]byte(sdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateDefinitions(`fragment UserFields on User { login }`); err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	err = s.ValidateDefinitions(`fragment UserFields on User { login, email }`)
+	if want := `UserFields.email: field "email" is not defined on type "User"`; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestSchema_ValidateSelections(t *testing.T) {
+	s, err := schema.Load([]byte(sdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateSelections(`{repository(owner: $owner, name: $name){issue(number: $number){title}}}`); err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	err = s.ValidateSelections(`{repository(owner: $owner){issue(number: 1){titel}}}`)
+	want := `repository: missing required argument "name" of type "String!" on field "repository"
+repository.issue.titel: field "titel" is not defined on type "Issue"`
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want:\n%s", err, want)
+	}
+}
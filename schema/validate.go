@@ -0,0 +1,529 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/phoban01/go-graphql-client"
+	"github.com/phoban01/go-graphql-client/internal/language"
+	"github.com/phoban01/go-graphql-client/introspection"
+)
+
+// Error is a validation error.
+type Error struct {
+	// Path is the path of the offending field, either as a Go field path
+	// (e.g. "Repository.Issue.Title") or as a response path
+	// (e.g. "repository.issue.title"). It's empty for errors
+	// about the operation itself, such as its variable definitions.
+	Path    string
+	Message string
+	// Line and Column are the location of the problem in the rendered
+	// query, starting at 1.
+	Line, Column int
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Errors is the list of errors found while validating a query.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+type validator struct {
+	schema *introspection.Schema
+	doc    *language.Document
+	errs   Errors
+	// unusedFragments reports whether fragments that aren't spread by any
+	// operation are validated on their own, rather than reported as unused.
+	unusedFragments bool
+	// implicitVariables reports whether the variable definitions of the
+	// operations are unknown, in which case variables are assumed to be
+	// defined with the type their usages expect.
+	implicitVariables bool
+
+	// State of the operation being validated.
+	variables map[string]*language.VariableDefinition
+	used      map[string]bool
+	visited   map[string]bool
+	spread    map[string]bool
+}
+
+func (v *validator) errorf(path []string, pos language.Position, format string, args ...interface{}) {
+	v.errs = append(v.errs, &Error{
+		Path:    strings.Join(path, "."),
+		Message: fmt.Sprintf(format, args...),
+		Line:    pos.Line,
+		Column:  pos.Column,
+	})
+}
+
+// document validates the operations of the document. t is the Go type of
+// the query struct the document was built from, if any.
+func (v *validator) document(t reflect.Type) {
+	v.spread = make(map[string]bool)
+	for _, op := range v.doc.Operations {
+		v.operation(op, t)
+	}
+	if t != nil {
+		return
+	}
+	for _, f := range v.doc.Fragments {
+		if v.spread[f.Name] {
+			continue
+		}
+		if !v.unusedFragments {
+			v.errorf(nil, f.Position, "fragment %q is never used", f.Name)
+			continue
+		}
+		v.variables = make(map[string]*language.VariableDefinition)
+		v.used = make(map[string]bool)
+		v.visited = make(map[string]bool)
+		v.selectionSet([]string{f.Name}, nil, []language.Selection{&language.FragmentSpread{Name: f.Name, Position: f.Position}}, nil)
+	}
+}
+
+func (v *validator) operation(op *language.OperationDefinition, t reflect.Type) {
+	var root *introspection.TypeName
+	switch op.Operation {
+	case "query":
+		root = v.schema.QueryType
+	case "mutation":
+		root = v.schema.MutationType
+	case "subscription":
+		root = v.schema.SubscriptionType
+	}
+	var rootType *introspection.Type
+	if root != nil {
+		rootType = v.schema.Type(root.Name)
+	}
+	if rootType == nil {
+		v.errorf(nil, op.Position, "schema doesn't support %s operations", op.Operation)
+		return
+	}
+
+	v.variables = make(map[string]*language.VariableDefinition)
+	v.used = make(map[string]bool)
+	v.visited = make(map[string]bool)
+	for _, def := range op.VariableDefinitions {
+		v.variables[def.Name] = def
+		switch named := v.schema.Type(namedType(def.Type)); {
+		case named == nil:
+			v.errorf(nil, def.Position, "variable \"$%s\" has unknown type %q", def.Name, namedType(def.Type))
+		case named.Kind != introspection.Scalar && named.Kind != introspection.Enum && named.Kind != introspection.InputObject:
+			v.errorf(nil, def.Position, "variable \"$%s\" can't be of non-input type %q", def.Name, def.Type)
+		}
+		v.directives(nil, def.Directives)
+	}
+	v.directives(nil, op.Directives)
+
+	var fields []goField
+	if t != nil {
+		fields = goFields(t)
+	}
+	v.selectionSet(nil, rootType, op.SelectionSet, fields)
+
+	for _, def := range op.VariableDefinitions {
+		if !v.used[def.Name] {
+			v.errorf(nil, def.Position, "variable \"$%s\" is never used", def.Name)
+		}
+	}
+}
+
+// selectionSet validates the selections of the parent type. fields are the
+// Go fields the selections were built from, or nil if unknown.
+func (v *validator) selectionSet(path []string, parent *introspection.Type, selections []language.Selection, fields []goField) {
+	if len(fields) != len(selections) {
+		fields = nil
+	}
+	for i, sel := range selections {
+		var field goField
+		if fields != nil {
+			field = fields[i]
+		}
+		switch sel := sel.(type) {
+		case *language.Field:
+			name := field.name
+			if fields == nil {
+				name = sel.ResponseKey()
+			}
+			v.field(appendPath(path, name), parent, sel, field.typ)
+		case *language.InlineFragment:
+			name := field.name
+			if fields == nil {
+				name = "... on " + sel.TypeCondition
+			}
+			p := appendPath(path, name)
+			v.directives(p, sel.Directives)
+			t := parent
+			if sel.TypeCondition != "" {
+				if t = v.fragmentType(p, sel.Position, sel.TypeCondition); t == nil {
+					continue
+				}
+			}
+			var childFields []goField
+			if field.typ != nil {
+				childFields = goFields(field.typ)
+			}
+			v.selectionSet(p, t, sel.SelectionSet, childFields)
+		case *language.FragmentSpread:
+			p := appendPath(path, field.name)
+			v.directives(p, sel.Directives)
+			v.spread[sel.Name] = true
+			def := v.doc.Fragment(sel.Name)
+			if def == nil {
+				v.errorf(p, sel.Position, "fragment %q is not defined", sel.Name)
+				continue
+			}
+			if v.visited[sel.Name] {
+				continue
+			}
+			v.visited[sel.Name] = true
+			v.directives(p, def.Directives)
+			t := v.fragmentType(p, def.Position, def.TypeCondition)
+			if t == nil {
+				continue
+			}
+			var childFields []goField
+			if field.typ != nil {
+				childFields = structFields(field.typ)
+			}
+			v.selectionSet(p, t, def.SelectionSet, childFields)
+		}
+	}
+}
+
+func (v *validator) fragmentType(path []string, pos language.Position, name string) *introspection.Type {
+	t := v.schema.Type(name)
+	if t == nil {
+		v.errorf(path, pos, "fragment type condition %q is not defined", name)
+		return nil
+	}
+	if !t.IsComposite() {
+		v.errorf(path, pos, "fragment can't condition on non-composite type %q", name)
+		return nil
+	}
+	return t
+}
+
+func (v *validator) field(path []string, parent *introspection.Type, sel *language.Field, goType reflect.Type) {
+	v.directives(path, sel.Directives)
+	if sel.Name == "__typename" {
+		if sel.SelectionSet != nil {
+			v.errorf(path, sel.Position, "field %q of type %q must not have a selection set", sel.Name, "String!")
+		}
+		return
+	}
+	if (sel.Name == "__schema" || sel.Name == "__type") && v.schema.QueryType != nil && parent.Name == v.schema.QueryType.Name {
+		// Introspection fields aren't part of the introspection result.
+		return
+	}
+	def := parent.Field(sel.Name)
+	if def == nil {
+		v.errorf(path, sel.Position, "field %q is not defined on type %q", sel.Name, parent.Name)
+		return
+	}
+	v.arguments(path, sel.Position, fmt.Sprintf("field %q", sel.Name), sel.Arguments, def.Args)
+
+	t := v.schema.Type(def.Type.NamedType())
+	if t == nil {
+		return
+	}
+	switch {
+	case t.IsLeaf() && sel.SelectionSet != nil:
+		v.errorf(path, sel.Position, "field %q of type %q must not have a selection set", sel.Name, def.Type)
+	case !t.IsLeaf() && len(sel.SelectionSet) == 0:
+		v.errorf(path, sel.Position, "field %q of type %q must have a selection of subfields", sel.Name, def.Type)
+	case !t.IsLeaf():
+		var fields []goField
+		if goType != nil {
+			fields = goFields(goType)
+		}
+		v.selectionSet(path, t, sel.SelectionSet, fields)
+	}
+}
+
+func (v *validator) directives(path []string, directives []*language.Directive) {
+	for _, d := range directives {
+		if len(v.schema.Directives) == 0 {
+			// The introspection result doesn't include directives.
+			return
+		}
+		def := v.schema.Directive(d.Name)
+		if def == nil {
+			v.errorf(path, d.Position, "directive \"@%s\" is not defined", d.Name)
+			continue
+		}
+		v.arguments(path, d.Position, fmt.Sprintf("directive \"@%s\"", d.Name), d.Arguments, def.Args)
+	}
+}
+
+// arguments validates the arguments of a field or directive, described by what.
+func (v *validator) arguments(path []string, pos language.Position, what string, args []*language.Argument, defs []*introspection.InputValue) {
+	for _, arg := range args {
+		def := inputValue(defs, arg.Name)
+		if def == nil {
+			v.errorf(path, arg.Position, "unknown argument %q on %s", arg.Name, what)
+			continue
+		}
+		v.value(path, arg.Value, def.Type, def.DefaultValue != nil)
+	}
+	for _, def := range defs {
+		if def.Type.Kind != introspection.NonNull || def.DefaultValue != nil {
+			continue
+		}
+		if !hasArgument(args, def.Name) {
+			v.errorf(path, pos, "missing required argument %q of type %q on %s", def.Name, def.Type, what)
+		}
+	}
+}
+
+// value validates the input value val of type t. hasDefault reports
+// whether the location of val has a default value.
+func (v *validator) value(path []string, val *language.Value, t *introspection.TypeRef, hasDefault bool) {
+	switch val.Kind {
+	case language.VariableValue:
+		v.used[val.Raw] = true
+		def, ok := v.variables[val.Raw]
+		if !ok && v.implicitVariables {
+			return
+		}
+		if !ok {
+			v.errorf(path, val.Position, "variable \"$%s\" is not defined", val.Raw)
+			return
+		}
+		if !variableUsageAllowed(def, t, hasDefault) {
+			v.errorf(path, val.Position, "variable \"$%s\" of type %q can't be used where %q is expected", val.Raw, def.Type, t)
+		}
+	case language.NullValue:
+		if t.Kind == introspection.NonNull {
+			v.errorf(path, val.Position, "null can't be used where %q is expected", t)
+		}
+	case language.ListValue:
+		item := t
+		if item.Kind == introspection.NonNull {
+			item = item.OfType
+		}
+		if item.Kind == introspection.List {
+			item = item.OfType
+		}
+		for _, iv := range val.List {
+			v.value(path, iv, item, false)
+		}
+	case language.ObjectValue:
+		named := v.schema.Type(t.NamedType())
+		if named == nil || named.Kind != introspection.InputObject {
+			v.errorf(path, val.Position, "object value can't be used where %q is expected", t)
+			return
+		}
+		for _, f := range val.Fields {
+			def := named.InputField(f.Name)
+			if def == nil {
+				v.errorf(path, val.Position, "field %q is not defined on input type %q", f.Name, named.Name)
+				continue
+			}
+			v.value(path, f.Value, def.Type, def.DefaultValue != nil)
+		}
+		for _, def := range named.InputFields {
+			if def.Type.Kind == introspection.NonNull && def.DefaultValue == nil && !hasObjectField(val.Fields, def.Name) {
+				v.errorf(path, val.Position, "missing required field %q of input type %q", def.Name, named.Name)
+			}
+		}
+	case language.EnumValue:
+		named := v.schema.Type(t.NamedType())
+		if named == nil || named.Kind != introspection.Enum {
+			return
+		}
+		for _, ev := range named.EnumValues {
+			if ev.Name == val.Raw {
+				return
+			}
+		}
+		v.errorf(path, val.Position, "value %s is not defined by enum %q", val.Raw, named.Name)
+	}
+}
+
+// variableUsageAllowed reports whether the variable def can be used where
+// a value of type location is expected.
+// See https://spec.graphql.org/June2018/#IsVariableUsageAllowed().
+func variableUsageAllowed(def *language.VariableDefinition, location *introspection.TypeRef, hasLocationDefault bool) bool {
+	if location.Kind == introspection.NonNull && !def.Type.NonNull {
+		hasDefault := def.DefaultValue != nil && def.DefaultValue.Kind != language.NullValue
+		if !hasDefault && !hasLocationDefault {
+			return false
+		}
+		return typesCompatible(def.Type, location.OfType)
+	}
+	return typesCompatible(def.Type, location)
+}
+
+func typesCompatible(t *language.Type, location *introspection.TypeRef) bool {
+	if location.Kind == introspection.NonNull {
+		if !t.NonNull {
+			return false
+		}
+		nullable := *t
+		nullable.NonNull = false
+		return typesCompatible(&nullable, location.OfType)
+	}
+	if t.NonNull {
+		nullable := *t
+		nullable.NonNull = false
+		return typesCompatible(&nullable, location)
+	}
+	if location.Kind == introspection.List {
+		return t.Elem != nil && typesCompatible(t.Elem, location.OfType)
+	}
+	return t.Elem == nil && t.Name == location.Name
+}
+
+func namedType(t *language.Type) string {
+	for t.Elem != nil {
+		t = t.Elem
+	}
+	return t.Name
+}
+
+func inputValue(defs []*introspection.InputValue, name string) *introspection.InputValue {
+	for _, def := range defs {
+		if def.Name == name {
+			return def
+		}
+	}
+	return nil
+}
+
+func hasArgument(args []*language.Argument, name string) bool {
+	for _, arg := range args {
+		if arg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasObjectField(fields []*language.ObjectField, name string) bool {
+	for _, f := range fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func appendPath(path []string, name string) []string {
+	if name == "" {
+		return path
+	}
+	p := make([]string, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, name)
+}
+
+// goField is the Go struct field a selection was built from.
+type goField struct {
+	name string
+	typ  reflect.Type
+}
+
+var (
+	selectionType     = reflect.TypeOf(graphql.Selection{})
+	namedFragmentType = reflect.TypeOf((*graphql.NamedFragment)(nil)).Elem()
+	jsonUnmarshaler   = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// goFields returns the Go fields that the selections of a field of type t
+// are built from, following the rules of graphql.ConstructQuery. It returns
+// nil if they can't be determined, e.g. for dynamic selections.
+func goFields(t reflect.Type) []goField {
+	t = elemType(t)
+	if t.Kind() != reflect.Struct || t == selectionType || reflect.PtrTo(t).Implements(jsonUnmarshaler) {
+		return nil
+	}
+	if isNamedFragment(t) {
+		// The field selects a spread of the fragment.
+		return []goField{{typ: t}}
+	}
+	return structFields(t)
+}
+
+// structFields returns the Go fields that the selections of struct t are
+// built from.
+func structFields(t reflect.Type) []goField {
+	t = elemType(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []goField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("graphql")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && !ok && f.Type.Kind() == reflect.Struct {
+			switch {
+			case isNamedFragment(f.Type):
+				fields = append(fields, goField{name: f.Name, typ: f.Type})
+			case f.Type == selectionType || reflect.PtrTo(f.Type).Implements(jsonUnmarshaler):
+				return nil
+			default:
+				inlined := structFields(f.Type)
+				if inlined == nil && f.Type.NumField() > 0 {
+					return nil
+				}
+				fields = append(fields, inlined...)
+			}
+			continue
+		}
+		fields = append(fields, goField{name: f.Name, typ: f.Type})
+	}
+	return fields
+}
+
+// elemType returns the type of the values t holds, through pointers, slices and arrays.
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isNamedFragment reports whether struct type t implements
+// graphql.NamedFragment, ignoring the methods promoted from an embedded
+// fragment of the same name.
+func isNamedFragment(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	if !t.Implements(namedFragmentType) && !reflect.PtrTo(t).Implements(namedFragmentType) {
+		return false
+	}
+	name := reflect.New(t).Interface().(graphql.NamedFragment).FragmentName()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.Anonymous {
+			continue
+		}
+		et := f.Type
+		for et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		if isNamedFragment(et) && reflect.New(et).Interface().(graphql.NamedFragment).FragmentName() == name {
+			return false
+		}
+	}
+	return true
+}
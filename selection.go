@@ -0,0 +1,113 @@
+package graphql
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/phoban01/go-graphql-client/internal/selection"
+)
+
+// Selection is a dynamically built selection set. It can be used wherever a
+// query struct is accepted, either as the whole query or as the type of a
+// struct field, and keeps its fields in the order they were added.
+//
+// E.g., the following selection:
+//
+//	var login String
+//	repo := NewSelection().Field("name", nil)
+//	sel := NewSelection().
+//		Field("login", &login).
+//		Field("repo: repository(name: $name)", repo)
+//
+// is written as "{login,repo: repository(name: $name){name}}".
+// The zero value is an empty selection set ready to use.
+type Selection selection.Set
+
+// NewSelection returns a new empty selection set.
+func NewSelection() *Selection {
+	return &Selection{}
+}
+
+// Field appends a field to the selection set and returns s.
+//
+// key uses the same syntax as the graphql struct tag, so it may contain an
+// alias and arguments, e.g. "repo: repository(owner: $owner, name: $name)".
+// v is where the field value is decoded into: a pointer to a scalar, a struct
+// or a slice, or a *Selection for a dynamic sub-selection. The selection set of
+// the field is derived from v as for struct fields. If v is nil, the field is
+// a leaf and its value is decoded into an interface{}, see Value.
+func (s *Selection) Field(key string, v interface{}) *Selection {
+	if v == nil {
+		v = new(interface{})
+	}
+	(*selection.Set)(s).Append(selection.Field{Key: key, Target: v})
+	return s
+}
+
+// On appends an inline fragment on the typeCondition type and returns s.
+// v is a pointer to a struct or a *Selection holding the fields of the fragment.
+//
+// E.g., s.On("Droid", &droid) is written as "... on Droid{primaryFunction}".
+func (s *Selection) On(typeCondition string, v interface{}) *Selection {
+	(*selection.Set)(s).Append(selection.Field{Key: "... on " + typeCondition, Target: v, Fragment: true})
+	return s
+}
+
+// Spread appends a spread of the named fragment v and returns s.
+// v is a pointer to a struct type that implements NamedFragment, otherwise
+// Spread panics.
+func (s *Selection) Spread(v interface{}) *Selection {
+	ok := false
+	if t := reflect.TypeOf(v); t != nil && t.Kind() == reflect.Ptr {
+		_, ok = asNamedFragment(t.Elem())
+	}
+	if !ok {
+		panic(fmt.Sprintf("Selection.Spread: %T isn't a pointer to a struct type that implements NamedFragment", v))
+	}
+	(*selection.Set)(s).Append(selection.Field{Target: v, Fragment: true})
+	return s
+}
+
+// Value returns the decoded value of the field with the given response key
+// (the alias if any, or the field name), or nil if there is no such field.
+// For fields added with a nil value, it returns the decoded interface{} value;
+// for fields with a *Selection it returns that *Selection, and otherwise it
+// returns the pointer given to Field.
+func (s *Selection) Value(key string) interface{} {
+	target := (*selection.Set)(s).Field(key)
+	if p, ok := target.(*interface{}); ok {
+		return *p
+	}
+	return target
+}
+
+var selectionType = reflect.TypeOf(Selection{})
+
+// writeSelection writes the minified selection set of s to w. It panics if
+// s is empty, since a selection set holds at least one selection.
+func writeSelection(w io.Writer, s Selection, fs *fragmentSet) {
+	fields := (*selection.Set)(&s).Fields()
+	if len(fields) == 0 {
+		panic("empty Selection, a selection set must hold at least one field or fragment")
+	}
+	io.WriteString(w, "{")
+	for i, f := range fields {
+		if i != 0 {
+			io.WriteString(w, ",")
+		}
+		io.WriteString(w, f.Key)
+		v := reflect.ValueOf(f.Target)
+		if !v.IsValid() {
+			continue
+		}
+		if f.Fragment && f.Key == "" {
+			// A spread is written by writeQuery as an inlined named fragment.
+			v = reflect.Indirect(v)
+			writeQuery(w, v.Type(), v, true, fs)
+			continue
+		}
+		writeQuery(w, v.Type(), v, false, fs)
+	}
+	io.WriteString(w, "}")
+}
@@ -0,0 +1,95 @@
+package graphql
+
+import (
+	"testing"
+)
+
+func TestConstructQuery_selection(t *testing.T) {
+	type droid struct {
+		PrimaryFunction String
+	}
+	tests := []struct {
+		inV         interface{}
+		inVariables map[string]interface{}
+		want        string
+	}{
+		{
+			inV: NewSelection().
+				Field("login", new(String)).
+				Field("repo: repository(owner: $owner, name: $name)", NewSelection().
+					Field("name", nil).
+					Field("issues(first: 10)", &struct {
+						TotalCount Int
+					}{})),
+			inVariables: map[string]interface{}{
+				"owner": String("shurcooL-test"),
+				"name":  String("test-repo"),
+			},
+			want: `query ($name:String!$owner:String!){login,repo: repository(owner: $owner, name: $name){name,issues(first: 10){totalCount}}}`,
+		},
+		{
+			inV: func() interface{} {
+				var q struct {
+					Hero struct {
+						Name    String
+						Dynamic Selection `graphql:"friends"`
+					} `graphql:"hero(episode: \"JEDI\")"`
+				}
+				q.Hero.Dynamic = *NewSelection().Field("name", nil)
+				return &q
+			}(),
+			want: `{hero(episode: "JEDI"){name,friends{name}}}`,
+		},
+		{
+			inV: func() interface{} {
+				var q struct {
+					Hero struct {
+						Name    String
+						Details *Selection `graphql:"... on Character"`
+					} `graphql:"hero"`
+				}
+				q.Hero.Details = NewSelection().
+					On("Droid", &droid{}).
+					Spread(&actorFields{}).
+					Field("friends", &[]*Selection{NewSelection().Field("name", nil)})
+				return q
+			}(),
+			want: `{hero{name,... on Character{... on Droid{primaryFunction},...ActorFields,friends{name}}}}fragment ActorFields on Actor{login,avatarUrl}`,
+		},
+	}
+	for _, tc := range tests {
+		got, err := ConstructQuery(tc.inV, tc.inVariables)
+		if err != nil {
+			t.Error(err)
+		} else if got != tc.want {
+			t.Errorf("\ngot:  %q\nwant: %q\n", got, tc.want)
+		}
+	}
+}
+
+func TestConstructQuery_emptySelection(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("got no panic, want a panic for an empty selection set")
+		}
+	}()
+	_, _ = ConstructQuery(&struct {
+		Hero struct {
+			Name    String
+			Dynamic Selection `graphql:"friends"`
+		} `graphql:"hero(episode: \"JEDI\")"`
+	}{}, nil)
+}
+
+func TestSelection_Spread_panics(t *testing.T) {
+	for _, v := range []interface{}{nil, actorFields{}, &struct{ Login String }{}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("got no panic for %T, want a panic for a value that isn't a pointer to a named fragment", v)
+				}
+			}()
+			NewSelection().Spread(v)
+		}()
+	}
+}
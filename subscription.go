@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/phoban01/go-graphql-client/internal/jsonutil"
 	"nhooyr.io/websocket"
 	"nhooyr.io/websocket/wsjson"
 )
@@ -104,6 +105,7 @@ type SubscriptionClient struct {
 	onError          func(sc *SubscriptionClient, err error) error
 	errorChan        chan error
 	disabledLogTypes []OperationMessageType
+	codec            Codec
 }
 
 func NewSubscriptionClient(url string) *SubscriptionClient {
@@ -115,6 +117,7 @@ func NewSubscriptionClient(url string) *SubscriptionClient {
 		createConn:    newWebsocketConn,
 		retryTimeout:  time.Minute,
 		errorChan:     make(chan error),
+		codec:         jsonutil.StdCodec,
 	}
 }
 
@@ -133,6 +136,11 @@ func (sc *SubscriptionClient) GetTimeout() time.Duration {
 	return sc.timeout
 }
 
+// GetCodec returns the codec of the messages of the subscription client
+func (sc *SubscriptionClient) GetCodec() Codec {
+	return sc.codec
+}
+
 // WithWebSocket replaces customized websocket client constructor
 // In default, subscription client uses https://github.com/nhooyr/websocket
 func (sc *SubscriptionClient) WithWebSocket(fn func(sc *SubscriptionClient) (WebsocketConn, error)) *SubscriptionClient {
@@ -183,6 +191,18 @@ func (sc *SubscriptionClient) WithReadLimit(limit int64) *SubscriptionClient {
 	return sc
 }
 
+// WithCodec sets the codec encoding the messages sent to the server, and
+// decoding the messages it sends, rather than encoding/json. A nil codec
+// restores encoding/json. Custom websocket clients, see WithWebSocket, get
+// the codec with GetCodec.
+func (sc *SubscriptionClient) WithCodec(codec Codec) *SubscriptionClient {
+	if codec == nil {
+		codec = jsonutil.StdCodec
+	}
+	sc.codec = codec
+	return sc
+}
+
 // OnConnected event is triggered when there is any connection error. This is bottom exception handler level
 // If this function is empty, or returns nil, the error is ignored
 // If returns error, the websocket connection will be terminated
@@ -267,7 +287,7 @@ func (sc *SubscriptionClient) sendConnectionInit() (err error) {
 	var bParams []byte = nil
 	if sc.connectionParams != nil {
 
-		bParams, err = json.Marshal(sc.connectionParams)
+		bParams, err = sc.codec.Marshal(sc.connectionParams)
 		if err != nil {
 			return
 		}
@@ -286,14 +306,15 @@ func (sc *SubscriptionClient) sendConnectionInit() (err error) {
 // Subscribe sends start message to server and open a channel to receive data.
 // The handler callback function will receive raw message data or error. If the call return error, onError event will be triggered
 // The function returns subscription ID and error. You can use subscription ID to unsubscribe the subscription
-func (sc *SubscriptionClient) Subscribe(v interface{}, variables map[string]interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
+// variables is either a map[string]interface{} or a variables struct, see ConstructSubscription
+func (sc *SubscriptionClient) Subscribe(v interface{}, variables interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
 	return sc.do(v, variables, handler, options...)
 }
 
 // NamedSubscribe sends start message to server and open a channel to receive data, with operation name
 //
 // Deprecated: this is the shortcut of Subscribe method, with NewOperationName option
-func (sc *SubscriptionClient) NamedSubscribe(name string, v interface{}, variables map[string]interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
+func (sc *SubscriptionClient) NamedSubscribe(name string, v interface{}, variables interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
 	return sc.do(v, variables, handler, append(options, OperationName(name))...)
 }
 
@@ -302,13 +323,17 @@ func (sc *SubscriptionClient) SubscribeRaw(query string, variables map[string]in
 	return sc.doRaw(query, variables, handler)
 }
 
-func (sc *SubscriptionClient) do(v interface{}, variables map[string]interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
+func (sc *SubscriptionClient) do(v interface{}, variables interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
 	query, err := ConstructSubscription(v, variables, options...)
 	if err != nil {
 		return "", err
 	}
+	payload, err := variablesPayload(variables, scalarsOption(options))
+	if err != nil {
+		return "", err
+	}
 
-	return sc.doRaw(query, variables, handler)
+	return sc.doRaw(query, payload, handler)
 }
 
 func (sc *SubscriptionClient) doRaw(query string, variables map[string]interface{}, handler func(message *json.RawMessage, err error) error) (string, error) {
@@ -348,7 +373,7 @@ func (sc *SubscriptionClient) startSubscription(id string, sub *subscription) er
 		Variables: sub.variables,
 	}
 
-	payload, err := json.Marshal(in)
+	payload, err := sc.codec.Marshal(in)
 	if err != nil {
 		return err
 	}
@@ -454,7 +479,7 @@ func (sc *SubscriptionClient) Run() error {
 					Errors Errors
 				}
 
-				err = json.Unmarshal(message.Payload, &out)
+				err = sc.codec.Unmarshal(message.Payload, &out)
 				if err != nil {
 					go sub.handler(nil, err)
 					continue
@@ -586,6 +611,7 @@ func (sc *SubscriptionClient) Close() (err error) {
 type WebsocketHandler struct {
 	ctx     context.Context
 	timeout time.Duration
+	codec   Codec
 	*websocket.Conn
 }
 
@@ -593,13 +619,35 @@ func (wh *WebsocketHandler) WriteJSON(v interface{}) error {
 	ctx, cancel := context.WithTimeout(wh.ctx, wh.timeout)
 	defer cancel()
 
-	return wsjson.Write(ctx, wh.Conn, v)
+	if wh.codec == nil {
+		return wsjson.Write(ctx, wh.Conn, v)
+	}
+	data, err := wh.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return wh.Conn.Write(ctx, websocket.MessageText, data)
 }
 
 func (wh *WebsocketHandler) ReadJSON(v interface{}) error {
 	ctx, cancel := context.WithTimeout(wh.ctx, wh.timeout)
 	defer cancel()
-	return wsjson.Read(ctx, wh.Conn, v)
+	if wh.codec == nil {
+		return wsjson.Read(ctx, wh.Conn, v)
+	}
+	typ, data, err := wh.Conn.Read(ctx)
+	if err != nil {
+		return err
+	}
+	if typ != websocket.MessageText {
+		wh.Conn.Close(websocket.StatusUnsupportedData, "expected text message")
+		return fmt.Errorf("expected text message for JSON but got: %v", typ)
+	}
+	if err := wh.codec.Unmarshal(data, v); err != nil {
+		wh.Conn.Close(websocket.StatusInvalidFramePayloadData, "failed to unmarshal JSON")
+		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return nil
 }
 
 func (wh *WebsocketHandler) Close() error {
@@ -622,6 +670,7 @@ func newWebsocketConn(sc *SubscriptionClient) (WebsocketConn, error) {
 		ctx:     sc.GetContext(),
 		Conn:    c,
 		timeout: sc.GetTimeout(),
+		codec:   sc.GetCodec(),
 	}, nil
 }
 
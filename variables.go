@@ -0,0 +1,366 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/phoban01/go-graphql-client/ident"
+)
+
+// Variable wraps the value of an operation variable in a variables map,
+// adding the optional parts of its definition in the operation header.
+//
+// E.g., map[string]interface{}{"first": Variable{Value: (*Int)(nil), DefaultValue: "10"}} -> "$first:Int = 10".
+type Variable struct {
+	// Value is the variable value. Its type determines the GraphQL type of the variable.
+	Value interface{}
+	// DefaultValue is a GraphQL literal used when the variable isn't provided,
+	// e.g. `10`, `"foo"` or `[ASC]`. A variable with a default value is left out
+	// of the request payload when Value is nil, so that the default applies.
+	DefaultValue string
+	// Directives are the directives of the variable definition, e.g. `@deprecated`.
+	Directives []string
+}
+
+// operationVariable is a single operation variable, with the Go type that
+// determines its GraphQL type and the value sent in the request payload.
+type operationVariable struct {
+	name         string
+	typ          reflect.Type
+	value        interface{}
+	defaultValue string
+	directives   []string
+	// omitEmpty is set if the variable is empty and tagged omitempty.
+	omitEmpty bool
+	// nullable is set if the variable is tagged omitempty, so that it's
+	// defined as nullable whatever its type, since it may be left out.
+	nullable bool
+}
+
+// omitted reports whether the variable is left out of the request payload,
+// so that the server applies its default value, or since it's empty and
+// tagged omitempty.
+func (v operationVariable) omitted() bool {
+	return v.omitEmpty || (v.defaultValue != "" && isNil(v.value))
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// variableList returns the operation variables described by variables, which
+// is either a map[string]interface{} or a struct (or pointer to struct).
+//
+// Map entries are sorted by key, and their GraphQL types are derived from the
+// dynamic types of the values, which may be wrapped in Variable. Struct fields are kept in declaration order;
+// they are named by their graphql tag, or by the lowerCamelCase field name,
+// and their GraphQL types are derived from the field types. The default value
+// and directives of a field variable are set with the default and directives
+// tags. Struct values are encoded with graphql tags, see graphqlValue.
+//
+// Values of the types registered in scalars are encoded with the Encode
+// function of their scalar.
+func variableList(variables interface{}, scalars *ScalarRegistry) ([]operationVariable, error) {
+	if variables == nil {
+		return nil, nil
+	}
+	if m, ok := variables.(map[string]interface{}); ok {
+		// Sort keys in order to produce deterministic output for testing purposes.
+		// TODO: If tests can be made to work with non-deterministic output, then no need to sort.
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		vars := make([]operationVariable, 0, len(m))
+		for _, k := range keys {
+			v := operationVariable{name: k, typ: reflect.TypeOf(m[k]), value: m[k]}
+			if def, ok := m[k].(Variable); ok {
+				v.typ = reflect.TypeOf(def.Value)
+				v.value = def.Value
+				v.defaultValue = def.DefaultValue
+				v.directives = def.Directives
+			}
+			v.value = scalarValue(reflect.ValueOf(v.value), scalars)
+			vars = append(vars, v)
+		}
+		return vars, nil
+	}
+
+	v := reflect.ValueOf(variables)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("variables must be a map[string]interface{} or a struct, got %T", variables)
+	}
+	if !v.CanAddr() {
+		// Make the fields addressable, so that json.Marshaler
+		// implementations with pointer receivers are found.
+		pv := reflect.New(v.Type())
+		pv.Elem().Set(v)
+		v = pv.Elem()
+	}
+	return appendStructVariables(nil, v, scalars), nil
+}
+
+// appendStructVariables appends the fields of struct v to vars.
+// Embedded structs without graphql tag are inlined.
+func appendStructVariables(vars []operationVariable, v reflect.Value, scalars *ScalarRegistry) []operationVariable {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := f.Tag.Lookup("graphql")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && !ok && f.Type.Kind() == reflect.Struct {
+			vars = appendStructVariables(vars, v.Field(i), scalars)
+			continue
+		}
+		if f.PkgPath != "" {
+			// Skip unexported field.
+			continue
+		}
+		name, omitEmpty := inputFieldName(f)
+		var directives []string
+		if d := f.Tag.Get("directives"); d != "" {
+			directives = []string{d}
+		}
+		vars = append(vars, operationVariable{
+			name:         name,
+			typ:          f.Type,
+			value:        graphqlValue(v.Field(i), scalars),
+			defaultValue: f.Tag.Get("default"),
+			directives:   directives,
+			omitEmpty:    omitEmpty && isEmptyValue(v.Field(i)),
+			nullable:     omitEmpty,
+		})
+	}
+	return vars
+}
+
+// variablesPayload returns the "variables" object of the request payload.
+// A map without Variable values is returned as is, while a struct is encoded
+// into a map keyed by variable names. Values of the types registered in
+// scalars are encoded with their scalar.
+func variablesPayload(variables interface{}, scalars *ScalarRegistry) (map[string]interface{}, error) {
+	if m, ok := variables.(map[string]interface{}); ok || variables == nil {
+		if !hasVariableDefinitions(m) && !scalars.hasEncoders() {
+			return m, nil
+		}
+	}
+	vars, err := variableList(variables, scalars)
+	if err != nil {
+		return nil, err
+	}
+	if len(vars) == 0 {
+		return nil, nil
+	}
+	payload := make(map[string]interface{}, len(vars))
+	for _, v := range vars {
+		if v.omitted() {
+			continue
+		}
+		payload[v.name] = v.value
+	}
+	return payload, nil
+}
+
+func hasVariableDefinitions(m map[string]interface{}) bool {
+	for _, v := range m {
+		if _, ok := v.(Variable); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// graphqlValue converts v into a value suitable for JSON encoding, where
+// structs are encoded as objects keyed by their graphql tags (or
+// lowerCamelCase field names) rather than json tags.
+// Types that implement json.Marshaler are left unchanged, and the types
+// registered in scalars are encoded with their scalar.
+func graphqlValue(v reflect.Value, scalars *ScalarRegistry) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if s, ok := scalars.lookup(v.Type()); ok && s.Encode != nil {
+		return encodeScalar(v, s)
+	}
+	if v.Type().Implements(jsonMarshaler) || (v.CanAddr() && v.Addr().Type().Implements(jsonMarshaler)) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return graphqlValue(v.Elem(), scalars)
+	case reflect.Struct:
+		obj := make(map[string]interface{})
+		writeGraphQLObject(obj, v, scalars)
+		return obj
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// Let encoding/json encode []byte as a base64 string.
+			return v.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = graphqlValue(v.Index(i), scalars)
+		}
+		return list
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		obj := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			obj[iter.Key().String()] = graphqlValue(iter.Value(), scalars)
+		}
+		return obj
+	default:
+		return v.Interface()
+	}
+}
+
+// writeGraphQLObject writes the exported fields of struct v into obj.
+// Embedded structs without graphql tag are inlined.
+func writeGraphQLObject(obj map[string]interface{}, v reflect.Value, scalars *ScalarRegistry) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := f.Tag.Lookup("graphql")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && !ok && f.Type.Kind() == reflect.Struct {
+			writeGraphQLObject(obj, v.Field(i), scalars)
+			continue
+		}
+		if f.PkgPath != "" {
+			// Skip unexported field.
+			continue
+		}
+		name, omitEmpty := inputFieldName(f)
+		if omitEmpty && isEmptyValue(v.Field(i)) {
+			continue
+		}
+		obj[name] = graphqlValue(v.Field(i), scalars)
+	}
+}
+
+// inputFieldName returns the name of the input field or variable f, its
+// graphql tag or its lowerCamelCase name, and reports whether it's left out
+// when empty, with the omitempty option of its graphql or json tag, e.g.
+// `graphql:"name,omitempty"`. Since a null input field may clear a value on
+// the server, while an omitted one doesn't, nil pointers are sent as null
+// unless omitempty is set.
+func inputFieldName(f reflect.StructField) (string, bool) {
+	name, options, _ := strings.Cut(f.Tag.Get("graphql"), ",")
+	if name == "" {
+		name = ident.ParseMixedCaps(f.Name).ToLowerCamelCase()
+	}
+	_, jsonOptions, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return name, hasOption(options, "omitempty") || hasOption(jsonOptions, "omitempty")
+}
+
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue reports whether v is empty, as with the omitempty option of
+// encoding/json: false, 0, a nil pointer or interface, and an empty array,
+// map, slice or string.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// scalarValue returns the value of a map variable v, where values of the
+// types registered in scalars, and pointers and lists of them, are encoded
+// with their scalar. Other values are left unchanged.
+func scalarValue(v reflect.Value, scalars *ScalarRegistry) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if !scalars.hasEncoders() {
+		return v.Interface()
+	}
+	if s, ok := scalars.lookup(v.Type()); ok && s.Encode != nil {
+		return encodeScalar(v, s)
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() && scalars.encodes(v.Type().Elem()) {
+			return scalarValue(v.Elem(), scalars)
+		}
+	case reflect.Slice, reflect.Array:
+		if (v.Kind() == reflect.Slice && v.IsNil()) || !scalars.encodes(v.Type().Elem()) {
+			break
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = scalarValue(v.Index(i), scalars)
+		}
+		return list
+	}
+	return v.Interface()
+}
+
+// encodeScalar returns v, a value of the scalar s, encoded with s.
+// Nil pointers are encoded as null.
+func encodeScalar(v reflect.Value, s Scalar) interface{} {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	return encodedScalar{v: v.Interface(), encode: s.Encode}
+}